@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net"
 	"strconv"
 	"time"
@@ -16,6 +17,8 @@ type Error string
 
 func (err Error) Error() string { return string(err) }
 
+var errConnClosed = errors.New("ledis: connection closed")
+
 type Conn struct {
 	client *Client
 
@@ -64,6 +67,51 @@ func (c *Conn) Do(cmd string, args ...interface{}) (interface{}, error) {
 	}
 }
 
+// Send writes the command to the output buffer, used with Flush and Receive
+// for pipelining.
+func (c *Conn) Send(cmd string, args ...interface{}) error {
+	if err := c.connect(); err != nil {
+		return err
+	}
+
+	if err := c.writeCommand(cmd, args); err != nil {
+		c.finalize()
+		return err
+	}
+	return nil
+}
+
+// Flush writes the buffered commands to the server.
+func (c *Conn) Flush() error {
+	if c.c == nil {
+		return errConnClosed
+	}
+
+	if err := c.bw.Flush(); err != nil {
+		c.finalize()
+		return err
+	}
+	return nil
+}
+
+// Receive reads a single reply of the sent commands in order.
+func (c *Conn) Receive() (interface{}, error) {
+	if c.c == nil {
+		return nil, errConnClosed
+	}
+
+	if reply, err := c.readReply(); err != nil {
+		c.finalize()
+		return nil, err
+	} else {
+		if e, ok := reply.(Error); ok {
+			return reply, e
+		} else {
+			return reply, nil
+		}
+	}
+}
+
 func (c *Conn) finalize() {
 	if c.c != nil {
 		c.c.Close()
@@ -239,6 +287,21 @@ func parseInt(p []byte) (interface{}, error) {
 	return n, nil
 }
 
+// parseDouble parses a RESP3 double reply.
+func parseDouble(p []byte) (interface{}, error) {
+	switch string(p) {
+	case "inf":
+		return math.Inf(1), nil
+	case "-inf":
+		return math.Inf(-1), nil
+	}
+	f, err := strconv.ParseFloat(string(p), 64)
+	if err != nil {
+		return nil, errors.New("ledis: malformed double")
+	}
+	return f, nil
+}
+
 var (
 	okReply   interface{} = "OK"
 	pongReply interface{} = "PONG"
@@ -269,38 +332,93 @@ func (c *Conn) readReply() (interface{}, error) {
 	case ':':
 		return parseInt(line[1:])
 	case '$':
+		return c.readBulk(line[1:])
+	case '*', '~', '>':
+		// RESP3 set and push are returned as arrays.
 		n, err := parseLen(line[1:])
 		if n < 0 || err != nil {
 			return nil, err
 		}
-		p := make([]byte, n)
-		_, err = io.ReadFull(c.br, p)
-		if err != nil {
-			return nil, err
-		}
-		if line, err := c.readLine(); err != nil {
+		return c.readArray(n)
+	case '%':
+		// RESP3 map is returned as an array of key and value pairs.
+		n, err := parseLen(line[1:])
+		if n < 0 || err != nil {
 			return nil, err
-		} else if len(line) != 0 {
-			return nil, errors.New("ledis: bad bulk string format")
 		}
-		return p, nil
-	case '*':
+		return c.readArray(2 * n)
+	case '|':
+		// RESP3 attributes are skipped.
 		n, err := parseLen(line[1:])
 		if n < 0 || err != nil {
 			return nil, err
 		}
-		r := make([]interface{}, n)
-		for i := range r {
-			r[i], err = c.readReply()
-			if err != nil {
-				return nil, err
-			}
+		if _, err = c.readArray(2 * n); err != nil {
+			return nil, err
 		}
-		return r, nil
+		return c.readReply()
+	case '_':
+		return nil, nil
+	case '#':
+		if len(line) != 2 || (line[1] != 't' && line[1] != 'f') {
+			return nil, errors.New("ledis: malformed boolean")
+		}
+		return line[1] == 't', nil
+	case ',':
+		return parseDouble(line[1:])
+	case '(':
+		// RESP3 big number is returned as a string.
+		return line[1:], nil
+	case '!':
+		p, err := c.readBulk(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		return Error(string(p.([]byte))), nil
+	case '=':
+		// RESP3 verbatim string is returned without the format prefix.
+		p, err := c.readBulk(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if b := p.([]byte); len(b) >= 4 && b[3] == ':' {
+			return b[4:], nil
+		}
+		return nil, errors.New("ledis: malformed verbatim string")
 	}
 	return nil, errors.New("ledis: unexpected response line")
 }
 
+func (c *Conn) readBulk(p []byte) (interface{}, error) {
+	n, err := parseLen(p)
+	if n < 0 || err != nil {
+		return nil, err
+	}
+	b := make([]byte, n)
+	_, err = io.ReadFull(c.br, b)
+	if err != nil {
+		return nil, err
+	}
+	if line, err := c.readLine(); err != nil {
+		return nil, err
+	} else if len(line) != 0 {
+		return nil, errors.New("ledis: bad bulk string format")
+	}
+	return b, nil
+}
+
+func (c *Conn) readArray(n int) (interface{}, error) {
+	r := make([]interface{}, n)
+	for i := range r {
+		var err error
+		r[i], err = c.readReply()
+		if err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
 func (c *Client) newConn() *Conn {
 	co := new(Conn)
 	co.client = c
@@ -0,0 +1,51 @@
+package ledis
+
+import (
+	"bufio"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadRESP3Reply(t *testing.T) {
+	data := strings.Join([]string{
+		"_",
+		"#t",
+		",1.5",
+		",-inf",
+		"%2", "+a", ":1", "$1", "b", "#f",
+		"~1", ":1",
+		">2", "$7", "message", "$2", "hi",
+		"|1", "+ttl", ":3", ":4",
+		"(12345678901234567890",
+		"=8", "txt:abcd",
+		"!5", "ERR x",
+		"",
+	}, "\r\n")
+
+	c := new(Conn)
+	c.br = bufio.NewReader(strings.NewReader(data))
+
+	expected := []interface{}{
+		nil,
+		true,
+		1.5,
+		math.Inf(-1),
+		[]interface{}{"a", int64(1), []byte("b"), false},
+		[]interface{}{int64(1)},
+		[]interface{}{[]byte("message"), []byte("hi")},
+		int64(4),
+		[]byte("12345678901234567890"),
+		[]byte("abcd"),
+		Error("ERR x"),
+	}
+
+	for i, e := range expected {
+		if v, err := c.readReply(); err != nil {
+			t.Fatal(i, err)
+		} else if !reflect.DeepEqual(v, e) {
+			t.Fatal(i, v, e)
+		}
+	}
+}
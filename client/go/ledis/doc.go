@@ -35,6 +35,21 @@
 //     //connection send command
 //     conn.Do("ping")
 //
+// Use Send, Flush and Receive to pipeline commands.
+//
+//     conn.Send("set", "a", "1")
+//     conn.Send("get", "a")
+//     conn.Flush()
+//     conn.Receive() // reply of set
+//     conn.Receive() // reply of get
+//
+// RESP3
+//
+// Send HELLO 3 to switch a connection to RESP3. RESP3 null is returned as nil, boolean as bool,
+// double as float64, map as an array of key and value pairs, set and push as arrays.
+//
+//     conn.Do("hello", 3)
+//
 // Reply Helper
 //
 // You can use reply helper to convert a reply to a specific type.
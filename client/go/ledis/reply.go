@@ -104,6 +104,8 @@ func Uint64(reply interface{}, err error) (uint64, error) {
 // the reply to an int as follows:
 //
 //  Reply type    Result
+//  double        reply, nil
+//  integer       float64(reply), nil
 //  bulk string   parsed reply, nil
 //  nil           0, ErrNil
 //  other         0, error
@@ -112,6 +114,10 @@ func Float64(reply interface{}, err error) (float64, error) {
 		return 0, err
 	}
 	switch reply := reply.(type) {
+	case float64:
+		return reply, nil
+	case int64:
+		return float64(reply), nil
 	case []byte:
 		n, err := strconv.ParseFloat(string(reply), 64)
 		return n, err
@@ -180,6 +186,7 @@ func Bytes(reply interface{}, err error) ([]byte, error) {
 // reply to boolean as follows:
 //
 //  Reply type      Result
+//  boolean         reply, nil
 //  integer         value != 0, nil
 //  bulk string     strconv.ParseBool(reply)
 //  nil             false, ErrNil
@@ -189,6 +196,8 @@ func Bool(reply interface{}, err error) (bool, error) {
 		return false, err
 	}
 	switch reply := reply.(type) {
+	case bool:
+		return reply, nil
 	case int64:
 		return reply != 0, nil
 	case []byte:
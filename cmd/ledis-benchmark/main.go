@@ -13,6 +13,7 @@ var ip = flag.String("ip", "127.0.0.1", "redis/ledis/ssdb server ip")
 var port = flag.Int("port", 6380, "redis/ledis/ssdb server port")
 var number = flag.Int("n", 1000, "request number")
 var clients = flag.Int("c", 50, "number of clients")
+var pipeline = flag.Int("P", 1, "pipeline <num> requests, 1 means no pipeline")
 
 var wg sync.WaitGroup
 
@@ -26,6 +27,11 @@ func waitBench(cmd string, args ...interface{}) {
 	c := client.Get()
 	defer c.Close()
 
+	if *pipeline > 1 {
+		waitPipelineBench(c, cmd, args...)
+		return
+	}
+
 	for i := 0; i < loop; i++ {
 		_, err := c.Do(cmd, args...)
 		if err != nil {
@@ -35,6 +41,35 @@ func waitBench(cmd string, args ...interface{}) {
 	}
 }
 
+//sends pipeline requests at once, then reads their replies
+func waitPipelineBench(c *ledis.Conn, cmd string, args ...interface{}) {
+	for i := 0; i < loop; i += *pipeline {
+		n := *pipeline
+		if loop-i < n {
+			n = loop - i
+		}
+
+		for j := 0; j < n; j++ {
+			if err := c.Send(cmd, args...); err != nil {
+				fmt.Printf("send %s error %s", cmd, err.Error())
+				return
+			}
+		}
+
+		if err := c.Flush(); err != nil {
+			fmt.Printf("flush %s error %s", cmd, err.Error())
+			return
+		}
+
+		for j := 0; j < n; j++ {
+			if _, err := c.Receive(); err != nil {
+				fmt.Printf("receive %s error %s", cmd, err.Error())
+				return
+			}
+		}
+	}
+}
+
 func bench(cmd string, f func()) {
 	wg.Add(*clients)
 
@@ -230,6 +265,10 @@ func main() {
 		return
 	}
 
+	if *pipeline <= 0 {
+		panic("invalid pipeline number")
+	}
+
 	loop = *number / *clients
 
 	addr := fmt.Sprintf("%s:%d", *ip, *port)
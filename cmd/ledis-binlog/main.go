@@ -56,6 +56,11 @@ func main() {
 	}
 
 	rb := bufio.NewReaderSize(f, 4096)
+	if err = ledis.ReadBinLogFileHeader(rb); err != nil {
+		println("read binlog header error: ", err.Error())
+		return
+	}
+
 	err = ledis.ReadEventFromReader(rb, printEvent)
 	if err != nil {
 		println("read event error: ", err.Error())
@@ -63,14 +68,14 @@ func main() {
 	}
 }
 
-func printEvent(createTime uint32, event []byte) error {
+func printEvent(logID uint64, createTime uint32, event []byte) error {
 	if createTime < startTime || createTime > stopTime {
 		return nil
 	}
 
 	t := time.Unix(int64(createTime), 0)
 
-	fmt.Printf("%s ", t.Format(TimeFormat))
+	fmt.Printf("%d %s ", logID, t.Format(TimeFormat))
 
 	s, err := ledis.FormatBinLogEvent(event)
 	if err != nil {
@@ -80,8 +80,31 @@ var helpCommands = [][]string{
 	{"BPERSIST", "key", "Bitmap"},
 	{"SLAVEOF", "host port", "Replication"},
 	{"FULLSYNC", "-", "Replication"},
-	{"SYNC", "index offset", "Replication"},
+	{"SYNC", "logid", "Replication"},
+	{"BINLOG", "LIST|INFO|PURGE TO index|PURGE BEFORE datetime", "Replication"},
+	{"REPLICAOF", "[host port]", "Replication"},
+	{"ROLE", "-", "Replication"},
+	{"REPLICAS", "LIST|KILL addr", "Replication"},
 	{"PING", "-", "Server"},
 	{"ECHO", "message", "Server"},
 	{"SELECT", "index", "Server"},
+	{"INFO", "[section]", "Server"},
+	{"BACKUP", "dir", "Server"},
+	{"BGSAVE", "-", "Server"},
+	{"COMPACT", "[ALL|KV|HASH|LIST|ZSET|BIT]", "Server"},
+	{"STORE", "CAPS", "Server"},
+	{"SLOWLOG", "GET [n]|LEN|RESET", "Server"},
+	{"CONFIG", "GET pattern|SET name value|REWRITE", "Server"},
+	{"CLIENT", "LIST|KILL addr|SETNAME name|GETNAME", "Server"},
+	{"SHUTDOWN", "[NOSAVE|SAVE]", "Server"},
+	{"HELLO", "[protover [SETNAME name]]", "Server"},
+	{"SUBSCRIBE", "channel [channel ...]", "PubSub"},
+	{"PSUBSCRIBE", "pattern [pattern ...]", "PubSub"},
+	{"UNSUBSCRIBE", "[channel ...]", "PubSub"},
+	{"PUNSUBSCRIBE", "[pattern ...]", "PubSub"},
+	{"PUBLISH", "channel message", "PubSub"},
+	{"PUBSUB", "CHANNELS [pattern]|NUMSUB [channel ...]|NUMPAT", "PubSub"},
+	{"EVAL", "script numkeys [key ...] [arg ...]", "Script"},
+	{"EVALSHA", "sha1 numkeys [key ...] [arg ...]", "Script"},
+	{"SCRIPT", "LOAD script|EXISTS sha1 [sha1 ...]|FLUSH|KILL", "Script"},
 }
@@ -97,6 +97,10 @@ func printReply(cmd string, reply interface{}) {
 		fmt.Printf("%q", reply)
 	case nil:
 		fmt.Printf("(nil)")
+	case bool:
+		fmt.Printf("(boolean) %t", reply)
+	case float64:
+		fmt.Printf("(double) %v", reply)
 	case ledis.Error:
 		fmt.Printf("%s", string(reply))
 	case []interface{}:
@@ -14,8 +14,6 @@ var port = flag.Int("port", 6380, "ledis server port")
 var sock = flag.String("sock", "", "ledis unix socket domain")
 var dumpFile = flag.String("o", "./ledis.dump", "dump file to save")
 
-var fullSyncCmd = []byte("*1\r\n$8\r\nfullsync\r\n") //fullsync
-
 func main() {
 	flag.Parse()
 
@@ -46,7 +44,7 @@ func main() {
 
 	println("dump begin")
 
-	if _, err = c.Write(fullSyncCmd); err != nil {
+	if _, err = c.Write(server.FullSyncCmd); err != nil {
 		println(err.Error())
 		return
 	}
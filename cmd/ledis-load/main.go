@@ -5,10 +5,22 @@ import (
 	"fmt"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/ledis"
+	"io/ioutil"
+	"math"
+	"path"
+	"strings"
+	"time"
 )
 
+var TimeFormat = "2006-01-02 15:04:05"
+
 var configPath = flag.String("config", "", "ledisdb config file")
 var dumpPath = flag.String("dump_file", "", "ledisdb dump file")
+var binlogPath = flag.String("binlog_dir", "", "replay binlog files in this dir after the dump position, for point-in-time recovery")
+var stopDateTime = flag.String("stop-datetime", "",
+	"Stop replaying the binary log at the first batch having a timestamp later than the datetime argument.")
+var stopLogID = flag.Uint64("stop-logid", 0,
+	"Stop replaying the binary log at the first batch having a log id greater than the argument, 0 means no limit.")
 
 func main() {
 	flag.Parse()
@@ -34,13 +46,38 @@ func main() {
 		return
 	}
 
+	if len(*binlogPath) > 0 && path.Clean(*binlogPath) == path.Join(cfg.DataDir, "bin_log") {
+		println("binlog dir can not be the data dir's binlog")
+		return
+	}
+
+	var stopTime uint32 = math.MaxUint32
+	if len(*stopDateTime) > 0 {
+		var t time.Time
+		if t, err = time.ParseInLocation(TimeFormat, *stopDateTime, time.Local); err != nil {
+			println("parse stop-datetime error: ", err.Error())
+			return
+		}
+
+		stopTime = uint32(t.Unix())
+	}
+
+	var stopID uint64 = math.MaxUint64
+	if *stopLogID > 0 {
+		stopID = *stopLogID
+	}
+
 	ldb, err := ledis.Open(cfg)
 	if err != nil {
 		println("ledis open error ", err.Error())
 		return
 	}
 
-	err = loadDump(cfg, ldb)
+	var head *ledis.MasterInfo
+	if head, err = loadDump(cfg, ldb); err == nil && len(*binlogPath) > 0 {
+		err = replayBinLog(ldb, head.LogID, stopID, stopTime)
+	}
+
 	ldb.Close()
 
 	if err != nil {
@@ -51,23 +88,61 @@ func main() {
 	println("Load OK")
 }
 
-func loadDump(cfg *config.Config, ldb *ledis.Ledis) error {
+func loadDump(cfg *config.Config, ldb *ledis.Ledis) (*ledis.MasterInfo, error) {
 	var err error
 	if err = ldb.FlushAll(); err != nil {
-		return err
+		return nil, err
 	}
 
 	var head *ledis.MasterInfo
 	head, err = ldb.LoadDumpFile(*dumpPath)
 
 	if err != nil {
-		return err
+		return nil, err
 	}
 
 	//master enable binlog, here output this like mysql
-	if head.LogFileIndex != 0 && head.LogPos != 0 {
-		format := "MASTER_LOG_FILE='binlog.%07d', MASTER_LOG_POS=%d;\n"
-		fmt.Printf(format, head.LogFileIndex, head.LogPos)
+	if head.LogID != 0 {
+		format := "MASTER_LOG_ID=%d;\n"
+		fmt.Printf(format, head.LogID)
+	}
+
+	return head, nil
+}
+
+func replayBinLog(ldb *ledis.Ledis, afterID uint64, stopID uint64, stopTime uint32) error {
+	data, err := ioutil.ReadFile(path.Join(*binlogPath, "ledis-bin.index"))
+	if err != nil {
+		return err
+	}
+
+	lastID := afterID
+
+	for _, name := range strings.Split(string(data), "\n") {
+		name = strings.Trim(name, "\r\n ")
+		if len(name) == 0 {
+			continue
+		}
+
+		//each file goes on after the last replayed batch, a missing log id is an error
+		id, stopped, err := ldb.ReplicateFromBinLogRange(path.Join(*binlogPath, name), lastID, stopID, stopTime)
+		if err != nil {
+			return err
+		}
+
+		if id != 0 {
+			lastID = id
+		}
+
+		if stopped {
+			break
+		}
+	}
+
+	if lastID == afterID {
+		println("no binlog replayed")
+	} else {
+		fmt.Printf("replay binlog to MASTER_LOG_ID=%d;\n", lastID)
 	}
 
 	return nil
@@ -0,0 +1,257 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"github.com/siddontang/go-snappy/snappy"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"github.com/siddontang/ledisdb/server"
+	"github.com/siddontang/ledisdb/store"
+	"net"
+	"os"
+	"path"
+)
+
+var configPath = flag.String("config", "", "ledisdb config file")
+var fromDB = flag.String("from", "", "store to migrate from, default is db_name in config")
+var toDB = flag.String("to", "", "store to migrate to")
+var batchNum = flag.Int("batch", 1024, "key value number in one write batch")
+var masterAddr = flag.String("addr", "",
+	"live mode, migrate from the running ledis server at addr with binlog enabled, using a full sync and then catching up its binlog")
+
+func main() {
+	flag.Parse()
+
+	if len(*configPath) == 0 {
+		println("need ledis config file")
+		return
+	}
+
+	cfg, err := config.NewConfigWithFile(*configPath)
+	if err != nil {
+		println(err.Error())
+		return
+	}
+
+	if len(cfg.DataDir) == 0 {
+		println("must set data dir")
+		return
+	}
+
+	if len(*toDB) == 0 {
+		println("need store to migrate to")
+		return
+	}
+
+	if len(*masterAddr) > 0 {
+		err = migrateLive(cfg)
+	} else {
+		err = migrate(cfg)
+	}
+
+	if err != nil {
+		println(err.Error())
+		return
+	}
+
+	println("Migrate OK")
+}
+
+func openStore(cfg *config.Config, name string) (*store.DB, error) {
+	c := new(config.Config)
+	*c = *cfg
+	c.DBName = name
+
+	return store.Open(c)
+}
+
+func checkEmpty(db *store.DB, name string) error {
+	it := db.NewIterator()
+	it.SeekToFirst()
+	empty := !it.Valid()
+	it.Close()
+
+	if !empty {
+		return fmt.Errorf("store %s is not empty", name)
+	}
+	return nil
+}
+
+//migrate copies all raw key values from one store to another in the data dir,
+//the server must be stopped
+func migrate(cfg *config.Config) error {
+	from := *fromDB
+	if len(from) == 0 {
+		from = cfg.DBName
+	}
+
+	if from == *toDB {
+		return fmt.Errorf("can not migrate store %s to itself", from)
+	}
+
+	src, err := openStore(cfg, from)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := openStore(cfg, *toDB)
+	if err != nil {
+		return err
+	}
+	defer dst.Close()
+
+	if err = checkEmpty(dst, *toDB); err != nil {
+		return err
+	}
+
+	wb := dst.NewWriteBatch()
+
+	it := src.NewIterator()
+	defer it.Close()
+
+	var n int64
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		//Key and Value are copies, some batches keep the slices until commit
+		wb.Put(it.Key(), it.Value())
+		n++
+
+		if n%int64(*batchNum) == 0 {
+			if err = wb.Commit(); err != nil {
+				return err
+			}
+			//commit does not clear the batch
+			wb.Rollback()
+		}
+	}
+
+	if err = wb.Commit(); err != nil {
+		return err
+	}
+
+	if err = dst.Sync(); err != nil {
+		return err
+	}
+
+	fmt.Printf("migrate %d key values from %s to %s, set db_name = \"%s\" in config to use it\n", n, from, *toDB, *toDB)
+	return nil
+}
+
+//migrateLive does a full sync from the running server into the store, and then
+//replicates its binlog until no new batch, at last saves the master info in data dir,
+//so a server started with the new store and slaveof the running server goes on
+//replicating and can take over later
+func migrateLive(cfg *config.Config) error {
+	c := new(config.Config)
+	*c = *cfg
+	c.DBName = *toDB
+
+	//only need the store
+	c.BinLog.MaxFileNum = 0
+	c.BinLog.MaxFileSize = 0
+
+	ldb, err := ledis.Open(c)
+	if err != nil {
+		return err
+	}
+	defer ldb.Close()
+
+	if err = checkEmpty(ldb.DataDB(), *toDB); err != nil {
+		return err
+	}
+
+	conn, err := net.Dial("tcp", *masterAddr)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	rb := bufio.NewReaderSize(conn, 4096)
+
+	info := new(server.MasterInfo)
+	info.Addr = *masterAddr
+
+	if info.LogID, err = fullSync(conn, rb, ldb, c.DataDir); err != nil {
+		return err
+	}
+
+	if info.LogID == 0 {
+		return fmt.Errorf("binlog of %s is not enabled, can not catch up", *masterAddr)
+	}
+
+	fmt.Printf("full sync to MASTER_LOG_ID=%d;\n", info.LogID)
+
+	for {
+		lastID := info.LogID
+		if info.LogID, err = syncBinLog(conn, rb, ldb, lastID); err != nil {
+			return err
+		}
+
+		if info.LogID == lastID {
+			break
+		}
+	}
+
+	fmt.Printf("catch up to MASTER_LOG_ID=%d;\n", info.LogID)
+
+	return info.Save(path.Join(c.DataDir, "master.info"))
+}
+
+func fullSync(conn net.Conn, rb *bufio.Reader, ldb *ledis.Ledis, dataDir string) (uint64, error) {
+	if _, err := conn.Write(server.FullSyncCmd); err != nil {
+		return 0, err
+	}
+
+	dumpPath := path.Join(dataDir, "migrate.dump")
+	f, err := os.OpenFile(dumpPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, os.ModePerm)
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(dumpPath)
+
+	err = server.ReadBulkTo(rb, f)
+	f.Close()
+	if err != nil {
+		return 0, err
+	}
+
+	head, err := ldb.LoadDumpFile(dumpPath)
+	if err != nil {
+		return 0, err
+	}
+
+	return head.LogID, nil
+}
+
+func syncBinLog(conn net.Conn, rb *bufio.Reader, ldb *ledis.Ledis, logID uint64) (uint64, error) {
+	if _, err := conn.Write(server.SyncCmd(logID)); err != nil {
+		return 0, err
+	}
+
+	var data bytes.Buffer
+	if err := server.ReadBulkTo(rb, &data); err != nil {
+		return 0, err
+	}
+
+	buf, err := snappy.Decode(nil, data.Bytes())
+	if err != nil {
+		return 0, err
+	}
+
+	firstID, lastID, batches, err := server.ParseSyncData(buf)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(batches) == 0 {
+		if firstID == 0 || logID+1 < firstID || logID > lastID {
+			return 0, fmt.Errorf("binlog after %d is not available, migrate again", logID)
+		}
+		return logID, nil
+	}
+
+	return ldb.ReplicateFromData(batches)
+}
@@ -57,6 +57,12 @@ func main() {
 	go func() {
 		<-sc
 
+		//close gracefully, exit at once if signaled again
+		go func() {
+			<-sc
+			os.Exit(1)
+		}()
+
 		app.Close()
 	}()
 
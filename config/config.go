@@ -2,8 +2,12 @@ package config
 
 import (
 	"encoding/json"
+	"fmt"
 	"github.com/BurntSushi/toml"
+	"github.com/siddontang/go-log/log"
 	"io/ioutil"
+	"runtime"
+	"strings"
 )
 
 type Size int
@@ -25,18 +29,79 @@ const (
 	DefaultBinLogFileNum  int = 10
 )
 
+const (
+	//never fsync, leave it to os
+	SyncModeNone string = "none"
+	//fsync every second
+	SyncModeEverySec string = "everysec"
+	//fsync before replying every write, concurrent writes share one fsync
+	SyncModeAlways string = "always"
+
+	DefaultSyncMode string = SyncModeNone
+)
+
+const (
+	//microseconds
+	DefaultSlowLogSlowerThan int64 = 10000
+	DefaultSlowLogMaxLen     int   = 128
+
+	//milliseconds
+	DefaultLuaTimeLimit int = 5000
+
+	//seconds
+	DefaultShutdownTimeout int = 10
+)
+
+const (
+	DefaultBloomFilterBits int = 10
+
+	DefaultLMDBMapSize int = 500 * 1024 * 1024
+)
+
 type LevelDBConfig struct {
 	Compression     bool `toml:"compression" json:"compression"`
 	BlockSize       int  `toml:"block_size" json:"block_size"`
 	WriteBufferSize int  `toml:"write_buffer_size" json:"write_buffer_size"`
 	CacheSize       int  `toml:"cache_size" json:"cache_size"`
 	MaxOpenFiles    int  `toml:"max_open_files" json:"max_open_files"`
+	BloomFilterBits int  `toml:"bloom_filter_bits" json:"bloom_filter_bits"`
+}
+
+type RocksDBConfig struct {
+	Compression     bool `toml:"compression" json:"compression"`
+	BlockSize       int  `toml:"block_size" json:"block_size"`
+	WriteBufferSize int  `toml:"write_buffer_size" json:"write_buffer_size"`
+	CacheSize       int  `toml:"cache_size" json:"cache_size"`
+	MaxOpenFiles    int  `toml:"max_open_files" json:"max_open_files"`
+	BloomFilterBits int  `toml:"bloom_filter_bits" json:"bloom_filter_bits"`
+
+	//level, universal or fifo
+	CompactionStyle string `toml:"compaction_style" json:"compaction_style"`
+
+	BackgroundThreads             int `toml:"background_threads" json:"background_threads"`
+	HighPriorityBackgroundThreads int `toml:"high_priority_background_threads" json:"high_priority_background_threads"`
+	MaxBackgroundCompactions      int `toml:"max_background_compactions" json:"max_background_compactions"`
+	MaxBackgroundFlushes          int `toml:"max_background_flushes" json:"max_background_flushes"`
+	MaxWriteBufferNum             int `toml:"max_write_buffer_num" json:"max_write_buffer_num"`
+	Level0SlowdownWritesTrigger   int `toml:"level0_slowdown_writes_trigger" json:"level0_slowdown_writes_trigger"`
+	Level0StopWritesTrigger       int `toml:"level0_stop_writes_trigger" json:"level0_stop_writes_trigger"`
+	TargetFileSizeBase            int `toml:"target_file_size_base" json:"target_file_size_base"`
+
+	DisableWAL bool `toml:"disable_wal" json:"disable_wal"`
+	//empty means in data dir
+	WALDir        string `toml:"wal_dir" json:"wal_dir"`
+	WALTTLSeconds int    `toml:"wal_ttl_seconds" json:"wal_ttl_seconds"`
 }
 
 type LMDBConfig struct {
 	MapSize int `toml:"map_size" json:"map_size"`
 }
 
+type BoltDBConfig struct {
+	//skip fsync after every commit, may lose recent data when crash
+	NoSync bool `toml:"nosync" json:"nosync"`
+}
+
 type BinLogConfig struct {
 	MaxFileSize int `toml:"max_file_size" json:"max_file_size"`
 	MaxFileNum  int `toml:"max_file_num" json:"max_file_num"`
@@ -53,13 +118,46 @@ type Config struct {
 
 	LevelDB LevelDBConfig `toml:"leveldb" json:"leveldb"`
 
+	RocksDB RocksDBConfig `toml:"rocksdb" json:"rocksdb"`
+
 	LMDB LMDBConfig `toml:"lmdb" json:"lmdb"`
 
+	BoltDB BoltDBConfig `toml:"boltdb" json:"boltdb"`
+
 	BinLog BinLogConfig `toml:"binlog" json:"binlog"`
 
 	SlaveOf string `toml:"slaveof" json:"slaveof"`
 
+	//reject write commands if it is a slave
+	SlaveReadOnly bool `toml:"slave_read_only" json:"slave_read_only"`
+
+	SyncMode string `toml:"sync_mode" json:"sync_mode"`
+
 	AccessLog string `toml:"access_log" json:"access_log"`
+
+	//microseconds, log commands slower than it, < 0 to disable
+	SlowLogSlowerThan int64 `toml:"slowlog_log_slower_than" json:"slowlog_log_slower_than"`
+
+	SlowLogMaxLen int `toml:"slowlog_max_len" json:"slowlog_max_len"`
+
+	//max number of connected clients, 0 means no limit
+	MaxClients int `toml:"maxclients" json:"maxclients"`
+
+	//seconds, close the client connection idle longer than it, 0 to disable
+	Timeout int `toml:"timeout" json:"timeout"`
+
+	//keyspace notifications, "flags" for all dbs and "index:flags" for one, empty to disable
+	NotifyKeyspaceEvents string `toml:"notify_keyspace_events" json:"notify_keyspace_events"`
+
+	//milliseconds, a script running longer is stopped and its writes are discarded, 0 means no limit
+	LuaTimeLimit int `toml:"lua_time_limit" json:"lua_time_limit"`
+
+	//seconds, max time to wait running requests when shutdown, then client connections are closed,
+	//0 means the default
+	ShutdownTimeout int `toml:"shutdown_timeout" json:"shutdown_timeout"`
+
+	//file the config is loaded from, for CONFIG REWRITE
+	FileName string `toml:"-" json:"-"`
 }
 
 func NewConfigWithFile(fileName string) (*Config, error) {
@@ -68,18 +166,42 @@ func NewConfigWithFile(fileName string) (*Config, error) {
 		return nil, err
 	}
 
-	return NewConfigWithData(data)
+	cfg, err := NewConfigWithData(data)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg.FileName = fileName
+	return cfg, nil
 }
 
 func NewConfigWithData(data []byte) (*Config, error) {
 	cfg := NewConfigDefault()
 
-	_, err := toml.Decode(string(data), cfg)
+	var hasRocksDB bool
+
+	meta, err := toml.Decode(string(data), cfg)
 	if err != nil {
 		//try json
 		if err = json.Unmarshal(data, cfg); err != nil {
 			return nil, err
 		}
+
+		var sections map[string]json.RawMessage
+		if err = json.Unmarshal(data, &sections); err != nil {
+			return nil, err
+		}
+		_, hasRocksDB = sections["rocksdb"]
+	} else {
+		hasRocksDB = meta.IsDefined("rocksdb")
+	}
+
+	if !hasRocksDB {
+		//configs before rocksdb section set rocksdb in leveldb section
+		if cfg.DBName == "rocksdb" {
+			log.Warn("no rocksdb section in config, use leveldb section for rocksdb")
+		}
+		cfg.RocksDB.setLevelDB(&cfg.LevelDB)
 	}
 
 	return cfg, nil
@@ -101,13 +223,37 @@ func NewConfigDefault() *Config {
 
 	// disable replication
 	cfg.SlaveOf = ""
+	cfg.SlaveReadOnly = true
 
 	// disable access log
 	cfg.AccessLog = ""
 
+	cfg.SyncMode = DefaultSyncMode
+
+	cfg.SlowLogSlowerThan = DefaultSlowLogSlowerThan
+	cfg.SlowLogMaxLen = DefaultSlowLogMaxLen
+
+	cfg.LuaTimeLimit = DefaultLuaTimeLimit
+
+	cfg.ShutdownTimeout = DefaultShutdownTimeout
+
 	return cfg
 }
 
+func (cfg *Config) CheckSyncMode() error {
+	cfg.SyncMode = strings.ToLower(cfg.SyncMode)
+	if len(cfg.SyncMode) == 0 {
+		cfg.SyncMode = DefaultSyncMode
+	}
+
+	switch cfg.SyncMode {
+	case SyncModeNone, SyncModeEverySec, SyncModeAlways:
+		return nil
+	default:
+		return fmt.Errorf("invalid sync mode %s", cfg.SyncMode)
+	}
+}
+
 func (cfg *LevelDBConfig) Adjust() {
 	if cfg.CacheSize <= 0 {
 		cfg.CacheSize = 4 * 1024 * 1024
@@ -124,6 +270,94 @@ func (cfg *LevelDBConfig) Adjust() {
 	if cfg.MaxOpenFiles < 1024 {
 		cfg.MaxOpenFiles = 1024
 	}
+
+	if cfg.BloomFilterBits <= 0 {
+		cfg.BloomFilterBits = DefaultBloomFilterBits
+	}
+}
+
+func (cfg *RocksDBConfig) Adjust() {
+	if cfg.CacheSize <= 0 {
+		cfg.CacheSize = 4 * 1024 * 1024
+	}
+
+	if cfg.BlockSize <= 0 {
+		cfg.BlockSize = 4 * 1024
+	}
+
+	if cfg.WriteBufferSize <= 0 {
+		cfg.WriteBufferSize = 4 * 1024 * 1024
+	}
+
+	if cfg.MaxOpenFiles < 1024 {
+		cfg.MaxOpenFiles = 1024
+	}
+
+	if cfg.BloomFilterBits <= 0 {
+		cfg.BloomFilterBits = DefaultBloomFilterBits
+	}
+
+	cfg.CompactionStyle = strings.ToLower(cfg.CompactionStyle)
+	if len(cfg.CompactionStyle) == 0 {
+		cfg.CompactionStyle = "level"
+	}
+
+	if cfg.BackgroundThreads <= 0 {
+		cfg.BackgroundThreads = runtime.NumCPU() * 2
+	}
+
+	if cfg.HighPriorityBackgroundThreads <= 0 {
+		cfg.HighPriorityBackgroundThreads = 1
+	}
+
+	if cfg.MaxBackgroundCompactions <= 0 {
+		cfg.MaxBackgroundCompactions = runtime.NumCPU()*2 - 1
+	}
+
+	if cfg.MaxBackgroundFlushes <= 0 {
+		cfg.MaxBackgroundFlushes = 1
+	}
+
+	if cfg.MaxWriteBufferNum <= 0 {
+		cfg.MaxWriteBufferNum = 2
+	}
+
+	if cfg.Level0SlowdownWritesTrigger <= 0 {
+		cfg.Level0SlowdownWritesTrigger = 16
+	}
+
+	if cfg.Level0StopWritesTrigger < cfg.Level0SlowdownWritesTrigger {
+		cfg.Level0StopWritesTrigger = 4 * cfg.Level0SlowdownWritesTrigger
+	}
+
+	if cfg.TargetFileSizeBase <= 0 {
+		cfg.TargetFileSizeBase = 32 * 1024 * 1024
+	}
+}
+
+func (cfg *RocksDBConfig) setLevelDB(l *LevelDBConfig) {
+	cfg.Compression = l.Compression
+	cfg.BlockSize = l.BlockSize
+	cfg.WriteBufferSize = l.WriteBufferSize
+	cfg.CacheSize = l.CacheSize
+	cfg.MaxOpenFiles = l.MaxOpenFiles
+	cfg.BloomFilterBits = l.BloomFilterBits
+}
+
+func (cfg *RocksDBConfig) Validate() error {
+	switch cfg.CompactionStyle {
+	case "level", "universal", "fifo":
+	default:
+		return fmt.Errorf("invalid rocksdb compaction style %s", cfg.CompactionStyle)
+	}
+
+	return nil
+}
+
+func (cfg *LMDBConfig) Adjust() {
+	if cfg.MapSize <= 0 {
+		cfg.MapSize = DefaultLMDBMapSize
+	}
 }
 
 func (cfg *BinLogConfig) Adjust() {
@@ -1,7 +1,10 @@
 package config
 
 import (
+	"io/ioutil"
+	"os"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -11,19 +14,44 @@ func TestConfig(t *testing.T) {
 	dstCfg.HttpAddr = "127.0.0.1:11181"
 	dstCfg.DataDir = "/tmp/ledis_server"
 	dstCfg.DBName = "leveldb"
+	dstCfg.SyncMode = "none"
+	dstCfg.SlowLogSlowerThan = 10000
+	dstCfg.SlowLogMaxLen = 128
+	dstCfg.SlaveReadOnly = true
+	dstCfg.LuaTimeLimit = 5000
+	dstCfg.ShutdownTimeout = 10
 
 	dstCfg.LevelDB.Compression = false
 	dstCfg.LevelDB.BlockSize = 32768
 	dstCfg.LevelDB.WriteBufferSize = 67108864
 	dstCfg.LevelDB.CacheSize = 524288000
 	dstCfg.LevelDB.MaxOpenFiles = 1024
+	dstCfg.LevelDB.BloomFilterBits = 10
+
+	dstCfg.RocksDB.Compression = false
+	dstCfg.RocksDB.BlockSize = 32768
+	dstCfg.RocksDB.WriteBufferSize = 67108864
+	dstCfg.RocksDB.CacheSize = 524288000
+	dstCfg.RocksDB.MaxOpenFiles = 1024
+	dstCfg.RocksDB.BloomFilterBits = 10
+	dstCfg.RocksDB.CompactionStyle = "level"
+	dstCfg.RocksDB.HighPriorityBackgroundThreads = 1
+	dstCfg.RocksDB.MaxBackgroundFlushes = 1
+	dstCfg.RocksDB.MaxWriteBufferNum = 2
+	dstCfg.RocksDB.Level0SlowdownWritesTrigger = 16
+	dstCfg.RocksDB.Level0StopWritesTrigger = 64
+	dstCfg.RocksDB.TargetFileSizeBase = 33554432
+
 	dstCfg.LMDB.MapSize = 524288000
+	dstCfg.BoltDB.NoSync = false
 
 	cfg, err := NewConfigWithFile("./config.toml")
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	dstCfg.FileName = "./config.toml"
+
 	if !reflect.DeepEqual(dstCfg, cfg) {
 		t.Fatal("parse toml error")
 	}
@@ -33,7 +61,130 @@ func TestConfig(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	dstCfg.FileName = "./config.json"
+
 	if !reflect.DeepEqual(dstCfg, cfg) {
 		t.Fatal("parse json error")
 	}
 }
+
+func TestRocksDBConfig(t *testing.T) {
+	cfg := new(RocksDBConfig)
+	cfg.Adjust()
+
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	} else if cfg.CompactionStyle != "level" {
+		t.Fatal(cfg.CompactionStyle)
+	} else if cfg.BloomFilterBits != DefaultBloomFilterBits {
+		t.Fatal(cfg.BloomFilterBits)
+	}
+
+	cfg.CompactionStyle = "Universal"
+	cfg.Adjust()
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg.CompactionStyle = "unknown"
+	if err := cfg.Validate(); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestRocksDBConfigFallback(t *testing.T) {
+	data := `
+db_name = "rocksdb"
+
+[leveldb]
+block_size = 32768
+cache_size = 1024
+`
+
+	cfg, err := NewConfigWithData([]byte(data))
+	if err != nil {
+		t.Fatal(err)
+	} else if cfg.RocksDB.BlockSize != 32768 || cfg.RocksDB.CacheSize != 1024 {
+		t.Fatal(cfg.RocksDB)
+	}
+
+	data = `{"db_name": "rocksdb", "leveldb": {"block_size": 32768}}`
+	if cfg, err = NewConfigWithData([]byte(data)); err != nil {
+		t.Fatal(err)
+	} else if cfg.RocksDB.BlockSize != 32768 {
+		t.Fatal(cfg.RocksDB)
+	}
+
+	//rocksdb section is used if set
+	data = `{"leveldb": {"block_size": 32768}, "rocksdb": {"block_size": 4096}}`
+	if cfg, err = NewConfigWithData([]byte(data)); err != nil {
+		t.Fatal(err)
+	} else if cfg.RocksDB.BlockSize != 4096 {
+		t.Fatal(cfg.RocksDB)
+	}
+}
+
+func TestConfigRewrite(t *testing.T) {
+	data, err := ioutil.ReadFile("./config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//remove a setting, rewrite must add it back
+	data = []byte(strings.Replace(string(data), "map_size = 524288000\n", "", 1))
+
+	fileName := "/tmp/test_ledis_config.toml"
+	if err = ioutil.WriteFile(fileName, data, 0644); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(fileName)
+
+	cfg, err := NewConfigWithFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ay, err := cfg.Get("slowlog_*"); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 || ay[0] != "slowlog_log_slower_than" || ay[1] != "10000" {
+		t.Fatal(ay)
+	}
+
+	if err = cfg.Set("access_log", "access.log"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cfg.Set("binlog.max_file_num", "20"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = cfg.Set("binlog.max_file_num", "abc"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if err = cfg.Set("unknown", "1"); err == nil {
+		t.Fatal("must error")
+	}
+
+	cfg.LMDB.MapSize = 1024
+
+	if err = cfg.Rewrite(); err != nil {
+		t.Fatal(err)
+	}
+
+	newCfg, err := NewConfigWithFile(fileName)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !reflect.DeepEqual(cfg, newCfg) {
+		t.Fatal("rewrite error")
+	}
+
+	//comments are kept
+	if data, err = ioutil.ReadFile(fileName); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(string(data), "# Server listen address") {
+		t.Fatal("comment lost")
+	}
+}
@@ -0,0 +1,258 @@
+package config
+
+import (
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"io/ioutil"
+	"os"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+//field is a config value, named by its toml key, section.key for values in a section
+type field struct {
+	section string
+	key     string
+	value   reflect.Value
+}
+
+func (f field) name() string {
+	if len(f.section) > 0 {
+		return fmt.Sprintf("%s.%s", f.section, f.key)
+	}
+	return f.key
+}
+
+func (f field) String() string {
+	switch f.value.Kind() {
+	case reflect.String:
+		return f.value.String()
+	case reflect.Bool:
+		return strconv.FormatBool(f.value.Bool())
+	default:
+		return strconv.FormatInt(f.value.Int(), 10)
+	}
+}
+
+//toml returns the value in toml format
+func (f field) toml() string {
+	if f.value.Kind() == reflect.String {
+		return strconv.Quote(f.value.String())
+	}
+	return f.String()
+}
+
+func tomlKey(f reflect.StructField) string {
+	key := f.Tag.Get("toml")
+	if key == "-" {
+		return ""
+	} else if len(key) == 0 {
+		return f.Name
+	}
+	return key
+}
+
+//fields returns all values of config in order
+func (cfg *Config) fields() []field {
+	var fs []field
+
+	v := reflect.ValueOf(cfg).Elem()
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		key := tomlKey(t.Field(i))
+		if len(key) == 0 {
+			continue
+		}
+
+		if v.Field(i).Kind() != reflect.Struct {
+			fs = append(fs, field{"", key, v.Field(i)})
+			continue
+		}
+
+		sv := v.Field(i)
+		st := sv.Type()
+		for j := 0; j < st.NumField(); j++ {
+			if sk := tomlKey(st.Field(j)); len(sk) > 0 {
+				fs = append(fs, field{key, sk, sv.Field(j)})
+			}
+		}
+	}
+
+	return fs
+}
+
+func (cfg *Config) field(name string) (field, bool) {
+	for _, f := range cfg.fields() {
+		if f.name() == name {
+			return f, true
+		}
+	}
+	return field{}, false
+}
+
+//Get returns name and value pairs of all settings matching the glob pattern,
+//settings in a section are named as section.key, e.g. binlog.max_file_num
+func (cfg *Config) Get(pattern string) ([]string, error) {
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, err
+	}
+
+	var ay []string
+	for _, f := range cfg.fields() {
+		if ok, _ := path.Match(pattern, f.name()); ok {
+			ay = append(ay, f.name(), f.String())
+		}
+	}
+
+	return ay, nil
+}
+
+//Set parses value and saves it to the setting name, only the value is checked,
+//the caller must make sure the setting is used
+func (cfg *Config) Set(name string, value string) error {
+	f, ok := cfg.field(name)
+	if !ok {
+		return fmt.Errorf("unknown config %s", name)
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(value)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid bool value %s for %s", value, name)
+		}
+		f.value.SetBool(b)
+	default:
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || f.value.OverflowInt(n) {
+			return fmt.Errorf("invalid integer value %s for %s", value, name)
+		}
+		f.value.SetInt(n)
+	}
+
+	return nil
+}
+
+var (
+	sectionRegexp = regexp.MustCompile(`^\s*\[\s*([\w.]+)\s*\]`)
+	keyRegexp     = regexp.MustCompile(`^(\s*)([\w]+)\s*=`)
+)
+
+//Rewrite saves the current settings to the toml file the config is loaded from,
+//changes the existing lines of settings, keeps comments and others,
+//and adds settings not in file only if different from the default.
+func (cfg *Config) Rewrite() error {
+	if len(cfg.FileName) == 0 {
+		return fmt.Errorf("config is not loaded from a file")
+	}
+
+	data, err := ioutil.ReadFile(cfg.FileName)
+	if err != nil {
+		return err
+	}
+
+	if _, err = toml.Decode(string(data), NewConfigDefault()); err != nil {
+		return fmt.Errorf("only toml config file can be rewritten, %s", err.Error())
+	}
+
+	fs := make(map[string]field)
+	for _, f := range cfg.fields() {
+		fs[f.name()] = f
+	}
+
+	written := make(map[string]bool)
+
+	var lines []string
+	section := ""
+	for _, line := range strings.Split(string(data), "\n") {
+		if m := sectionRegexp.FindStringSubmatch(line); m != nil {
+			section = m[1]
+		} else if m := keyRegexp.FindStringSubmatch(line); m != nil {
+			name := m[2]
+			if len(section) > 0 {
+				name = fmt.Sprintf("%s.%s", section, m[2])
+			}
+
+			if f, ok := fs[name]; ok {
+				line = fmt.Sprintf("%s%s = %s", m[1], m[2], f.toml())
+				written[name] = true
+			}
+		}
+
+		lines = append(lines, line)
+	}
+
+	lines = addMissingFields(lines, cfg.fields(), NewConfigDefault(), written)
+
+	tmpName := fmt.Sprintf("%s.tmp", cfg.FileName)
+	if err = ioutil.WriteFile(tmpName, []byte(strings.Join(lines, "\n")), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmpName, cfg.FileName)
+}
+
+//addMissingFields adds settings not written and different from the default,
+//top settings before the first section, others at the end of their sections
+func addMissingFields(lines []string, fs []field, def *Config, written map[string]bool) []string {
+	missing := make(map[string][]string)
+	var sections []string
+	for _, f := range fs {
+		if written[f.name()] {
+			continue
+		}
+
+		if d, _ := def.field(f.name()); d.String() == f.String() {
+			continue
+		}
+
+		if _, ok := missing[f.section]; !ok && len(f.section) > 0 {
+			sections = append(sections, f.section)
+		}
+		missing[f.section] = append(missing[f.section], fmt.Sprintf("%s = %s", f.key, f.toml()))
+	}
+
+	if len(missing) == 0 {
+		return lines
+	}
+
+	var out []string
+	section := ""
+	flush := func() {
+		if ms, ok := missing[section]; ok {
+			//insert before the trailing blank lines of section
+			i := len(out)
+			for i > 0 && len(strings.TrimSpace(out[i-1])) == 0 {
+				i--
+			}
+
+			tail := append([]string{}, out[i:]...)
+			out = append(append(out[0:i], ms...), tail...)
+			delete(missing, section)
+		}
+	}
+
+	for _, line := range lines {
+		if m := sectionRegexp.FindStringSubmatch(line); m != nil {
+			flush()
+			section = m[1]
+		}
+		out = append(out, line)
+	}
+	flush()
+
+	//sections not in file
+	for _, s := range sections {
+		if ms, ok := missing[s]; ok {
+			out = append(out, "", fmt.Sprintf("[%s]", s))
+			out = append(out, ms...)
+		}
+	}
+
+	return out
+}
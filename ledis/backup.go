@@ -0,0 +1,105 @@
+package ledis
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/siddontang/ledisdb/store/driver"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	BackupInfoName = "backup.info"
+	BackupDumpName = "ledis.dump"
+)
+
+//backup dir layout
+//
+//  backup.info: BackupInfo in json
+//  ledis.dump: dump file, if backup is not native
+//  {db_name}_data: store data, if backup is native, copy it to data dir to restore
+type BackupInfo struct {
+	MasterInfo
+
+	DBName string `json:"db_name"`
+	Native bool   `json:"native"`
+	Time   int64  `json:"time"`
+}
+
+type countWriter struct {
+	w io.Writer
+	n *int64
+}
+
+func (w *countWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	atomic.AddInt64(w.n, int64(n))
+	return n, err
+}
+
+//Backup saves a consistent copy of all data in dir, using the native
+//backup of store if supported, or a dump file instead.
+//
+//written counts the dump bytes written, can be used to report progress
+func (l *Ledis) Backup(dir string, written *int64) (*BackupInfo, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	info := new(BackupInfo)
+	info.DBName = l.cfg.DBName
+	info.Time = time.Now().Unix()
+
+	l.Lock()
+
+	if l.binlog != nil {
+		info.LogID = l.binlog.LastLogID()
+	}
+
+	s, err := l.ldb.BackupSnapshot()
+
+	l.Unlock()
+
+	if err == nil {
+		//copy the snapshot without lock, writes go on meanwhile
+		err = s.Save(path.Join(dir, fmt.Sprintf("%s_data", l.cfg.DBName)))
+		s.Close()
+		info.Native = true
+	} else if err == driver.ErrBackupSupport {
+		//dump takes the lock itself, and releases it before streaming if it can
+		var m *MasterInfo
+		if m, err = l.dumpFile(path.Join(dir, BackupDumpName), written); err == nil {
+			info.MasterInfo = *m
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	data, _ := json.Marshal(info)
+	if err = ioutil.WriteFile(path.Join(dir, BackupInfoName), data, 0644); err != nil {
+		return nil, err
+	}
+
+	return info, nil
+}
+
+func (l *Ledis) dumpFile(filePath string, written *int64) (*MasterInfo, error) {
+	f, err := os.Create(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var w io.Writer = f
+	if written != nil {
+		w = &countWriter{f, written}
+	}
+
+	return l.dump(w)
+}
@@ -0,0 +1,39 @@
+package ledis
+
+import (
+	"github.com/siddontang/ledisdb/config"
+	"os"
+	"path"
+	"testing"
+)
+
+func TestBackupNative(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_backup_native"
+	cfg.DBName = "boltdb"
+	cfg.BinLog.MaxFileNum = 10
+	cfg.BinLog.MaxFileSize = 1024 * 1024
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+
+	dir := path.Join(cfg.DataDir, "backup")
+	info, err := l.Backup(dir, nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if !info.Native || info.LogID != l.binlog.LastLogID() {
+		t.Fatal(info)
+	}
+
+	if _, err = os.Stat(path.Join(dir, "boltdb_data", "ledis_bolt.db")); err != nil {
+		t.Fatal(err)
+	}
+}
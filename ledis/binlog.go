@@ -2,15 +2,20 @@ package ledis
 
 import (
 	"bufio"
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"github.com/siddontang/go-log/log"
 	"github.com/siddontang/ledisdb/config"
+	"hash/crc32"
+	"io"
 	"io/ioutil"
 	"os"
 	"path"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -22,11 +27,40 @@ ledis-bin.00003
 
 log file format
 
-timestamp(bigendian uint32, seconds)|PayloadLen(bigendian uint32)|PayloadData
+Magic("LEDISBIN")|Version(bigendian uint32)|events
+
+event format
+
+LogID(bigendian uint64)|timestamp(bigendian uint32, seconds)|PayloadLen(bigendian uint32)|Checksum(bigendian uint32)|PayloadData
+
+log id increases by one for every logged batch, all events in a batch share the same log id
+
+checksum is the crc32 (IEEE) of PayloadData
 
 */
 
+const eventHeaderSize = 20
+
+const (
+	binLogMagic   = "LEDISBIN"
+	binLogVersion = 1
+
+	fileHeaderSize = len(binLogMagic) + 4
+)
+
+var errBinLogFormat = errors.New("unknown binlog file format")
+
+//max cached read positions for syncing slaves
+const maxSyncPosNum = 1024
+
+type logPos struct {
+	index int64
+	pos   int64
+}
+
 type BinLog struct {
+	sync.Mutex
+
 	path string
 
 	cfg *config.BinLogConfig
@@ -35,15 +69,32 @@ type BinLog struct {
 
 	logWb *bufio.Writer
 
-	indexName    string
-	logNames     []string
+	//size of the header and all complete events in current log file
+	logFileSize int64
+
+	indexName string
+	logNames  []string
+	//first log id of each log file in logNames, 0 if the file has no event
+	logFirstIDs  []uint64
 	lastLogIndex int64
+
+	lastLogID uint64
+
+	//bytes of all events written since open
+	writtenBytes int64
+
+	//where to read the event after a log id, used by syncing slaves
+	syncPos map[uint64]logPos
+	//log ids in syncPos, oldest saved first
+	syncPosIDs []uint64
 }
 
 func NewBinLog(cfg *config.Config) (*BinLog, error) {
 	l := new(BinLog)
 
-	l.cfg = &cfg.BinLog
+	//a copy, the limits can be changed by SetLimits while the config is read by others
+	l.cfg = new(config.BinLogConfig)
+	*l.cfg = cfg.BinLog
 	l.cfg.Adjust()
 
 	l.path = path.Join(cfg.DataDir, "bin_log")
@@ -53,6 +104,8 @@ func NewBinLog(cfg *config.Config) (*BinLog, error) {
 	}
 
 	l.logNames = make([]string, 0, 16)
+	l.logFirstIDs = make([]uint64, 0, 16)
+	l.syncPos = make(map[uint64]logPos)
 
 	if err := l.loadIndex(); err != nil {
 		return nil, err
@@ -97,19 +150,31 @@ func (l *BinLog) loadIndex() error {
 			return err
 		}
 
-		lines := strings.Split(string(indexData), "\n")
-		for _, line := range lines {
+		names := make([]string, 0, 16)
+		for _, line := range strings.Split(string(indexData), "\n") {
 			line = strings.Trim(line, "\r\n ")
-			if len(line) == 0 {
-				continue
+			if len(line) > 0 {
+				names = append(names, line)
 			}
+		}
 
-			if _, err := os.Stat(path.Join(l.path, line)); err != nil {
-				log.Error("load index line %s error %s", line, err.Error())
+		for _, name := range names {
+			firstID, err := readFirstLogID(path.Join(l.path, name))
+			if err == errBinLogFormat {
+				//written before log files had a header, keep them aside and start a new binlog
+				if err = l.moveOldLogs(names); err != nil {
+					return err
+				}
+				l.logNames = l.logNames[0:0]
+				l.logFirstIDs = l.logFirstIDs[0:0]
+				break
+			} else if err != nil {
+				log.Error("load index line %s error %s", name, err.Error())
 				return err
-			} else {
-				l.logNames = append(l.logNames, line)
 			}
+
+			l.logNames = append(l.logNames, name)
+			l.logFirstIDs = append(l.logFirstIDs, firstID)
 		}
 	}
 	if l.cfg.MaxFileNum > 0 && len(l.logNames) > l.cfg.MaxFileNum {
@@ -121,22 +186,263 @@ func (l *BinLog) loadIndex() error {
 
 	var err error
 	if len(l.logNames) == 0 {
-		l.lastLogIndex = 1
+		if l.lastLogIndex == 0 {
+			l.lastLogIndex = 1
+		}
 	} else {
 		lastName := l.logNames[len(l.logNames)-1]
 
-		if l.lastLogIndex, err = strconv.ParseInt(path.Ext(lastName)[1:], 10, 64); err != nil {
+		if l.lastLogIndex, err = l.parseLogFileIndex(lastName); err != nil {
 			log.Error("invalid logfile name %s", err.Error())
 			return err
 		}
 
-		//like mysql, if server restart, a new binlog will create
-		l.lastLogIndex++
+		//continue writing the last binlog, so replicas positioned at its end
+		//need not jump to a new file after a restart
+		if err = l.openLastLogFile(); err != nil {
+			return err
+		}
+
+		//current log file may have no event, find last log id in former files
+		for i := len(l.logNames) - 2; i >= 0 && l.lastLogID == 0; i-- {
+			if l.lastLogID, err = readLastLogID(path.Join(l.path, l.logNames[i])); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+//moveOldLogs renames the log files and index of an old format to *.old,
+//the new binlog goes on after the last old log file index
+func (l *BinLog) moveOldLogs(names []string) error {
+	log.Warn("binlog in %s has an old format, move it to *.old and start a new one", l.path)
+
+	for _, name := range names {
+		p := path.Join(l.path, name)
+		if err := os.Rename(p, p+".old"); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	if err := os.Rename(l.indexName, l.indexName+".old"); err != nil {
+		return err
+	}
+
+	if index, err := l.parseLogFileIndex(names[len(names)-1]); err == nil {
+		l.lastLogIndex = index + 1
 	}
 
 	return nil
 }
 
+func readFirstLogID(logPath string) (uint64, error) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	if err = readFileHeader(f); err == io.EOF {
+		//empty file
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+
+	var head [eventHeaderSize]byte
+	if _, err = io.ReadFull(f, head[:]); err != nil {
+		//no event
+		return 0, nil
+	}
+
+	return binary.BigEndian.Uint64(head[0:]), nil
+}
+
+//ReadBinLogFileHeader checks the magic and version at the beginning of a log file,
+//the reader is positioned at the first event after it
+func ReadBinLogFileHeader(r io.Reader) error {
+	if err := readFileHeader(r); err == io.EOF {
+		return errBinLogFormat
+	} else {
+		return err
+	}
+}
+
+//readFileHeader returns io.EOF if the file is empty or only has a torn header
+//written before a crash
+func readFileHeader(r io.Reader) error {
+	var head [fileHeaderSize]byte
+	n, err := io.ReadFull(r, head[:])
+	if err == io.ErrUnexpectedEOF {
+		if !bytes.Equal(head[0:n], fileHeader()[0:n]) {
+			return errBinLogFormat
+		}
+		return io.EOF
+	} else if err != nil {
+		return err
+	}
+
+	if string(head[0:len(binLogMagic)]) != binLogMagic {
+		return errBinLogFormat
+	}
+
+	if v := binary.BigEndian.Uint32(head[len(binLogMagic):]); v != binLogVersion {
+		return fmt.Errorf("unsupported binlog version %d", v)
+	}
+
+	return nil
+}
+
+func fileHeader() []byte {
+	head := make([]byte, fileHeaderSize)
+	copy(head, binLogMagic)
+	binary.BigEndian.PutUint32(head[len(binLogMagic):], binLogVersion)
+	return head
+}
+
+func readLastLogID(logPath string) (uint64, error) {
+	f, err := os.Open(logPath)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	_, lastID, err := checkLogFile(f)
+	if err != nil {
+		log.Error("binlog %s error %s", logPath, err.Error())
+	}
+	return lastID, err
+}
+
+func (l *BinLog) openLastLogFile() error {
+	logPath := path.Join(l.path, l.getLogFile())
+
+	f, err := os.OpenFile(logPath, os.O_RDWR, 0666)
+	if err != nil {
+		log.Error("open last logfile error %s", err.Error())
+		return err
+	}
+
+	st, _ := f.Stat()
+
+	var offset int64
+	if offset, l.lastLogID, err = checkLogFile(f); err != nil {
+		log.Error("binlog %s error %s, refuse to open it", logPath, err.Error())
+		f.Close()
+		return err
+	}
+
+	if offset < st.Size() {
+		//the last event may be torn after a crash, drop it
+		log.Warn("binlog %s has an invalid tail, truncate from %d to %d", logPath, st.Size(), offset)
+		if err = f.Truncate(offset); err != nil {
+			f.Close()
+			return err
+		}
+	}
+
+	if _, err = f.Seek(offset, os.SEEK_SET); err != nil {
+		f.Close()
+		return err
+	}
+
+	if offset == 0 {
+		//empty file or torn header
+		if _, err = f.Write(fileHeader()); err != nil {
+			f.Close()
+			return err
+		}
+		offset = int64(fileHeaderSize)
+	}
+
+	l.logFile = f
+	l.logWb = bufio.NewWriterSize(l.logFile, 1024)
+	l.logFileSize = offset
+
+	l.checkLogFileSize()
+
+	return nil
+}
+
+//checkLogFile returns the offset just after the last complete event
+//whose checksum is valid, and the log id of that event.
+//
+//offset is 0 if the file has no complete header, a file of unknown format is an error
+func checkLogFile(f *os.File) (offset int64, lastID uint64, err error) {
+	if _, err = f.Seek(0, os.SEEK_SET); err != nil {
+		return
+	}
+
+	rb := bufio.NewReaderSize(f, 4096)
+
+	if err = readFileHeader(rb); err == io.EOF {
+		return 0, 0, nil
+	} else if err != nil {
+		return
+	}
+
+	offset = int64(fileHeaderSize)
+
+	var head [eventHeaderSize]byte
+	var data []byte
+
+	for {
+		if _, err := io.ReadFull(rb, head[:]); err != nil {
+			return offset, lastID, nil
+		}
+
+		dataLen := binary.BigEndian.Uint32(head[12:])
+		checksum := binary.BigEndian.Uint32(head[16:])
+
+		if uint32(cap(data)) < dataLen {
+			data = make([]byte, dataLen)
+		}
+		data = data[0:dataLen]
+
+		if _, err := io.ReadFull(rb, data); err != nil {
+			return offset, lastID, nil
+		}
+
+		if crc32.ChecksumIEEE(data) != checksum {
+			return offset, lastID, nil
+		}
+
+		offset += eventHeaderSize + int64(dataLen)
+		lastID = binary.BigEndian.Uint64(head[0:])
+	}
+}
+
+//validLogFileSize returns the size of the header and all complete events of a log file,
+//an invalid event is only allowed at the end, torn by a crash or being written
+func validLogFileSize(f *os.File) (int64, error) {
+	offset, _, err := checkLogFile(f)
+	if err != nil {
+		return 0, err
+	}
+
+	st, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	if offset > 0 && offset < st.Size() {
+		var head [eventHeaderSize]byte
+		if _, err = f.ReadAt(head[:], offset); err == nil {
+			dataLen := binary.BigEndian.Uint32(head[12:])
+			if offset+eventHeaderSize+int64(dataLen) < st.Size() {
+				//more events follow the invalid one
+				return 0, errBinLogChecksum
+			}
+		}
+
+		log.Warn("binlog %s has an invalid tail from %d, ignore it", f.Name(), offset)
+	}
+
+	return offset, nil
+}
+
 func (l *BinLog) getLogFile() string {
 	return l.FormatLogFileName(l.lastLogIndex)
 }
@@ -146,16 +452,19 @@ func (l *BinLog) openNewLogFile() error {
 	lastName := l.getLogFile()
 
 	logPath := path.Join(l.path, lastName)
-	if l.logFile, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY, 0666); err != nil {
+	if l.logFile, err = os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0666); err != nil {
 		log.Error("open new logfile error %s", err.Error())
 		return err
 	}
 
-	if l.cfg.MaxFileNum > 0 && len(l.logNames) == l.cfg.MaxFileNum {
-		l.purge(1)
+	//max file num may be lowered at runtime, so purge all files over it
+	if l.cfg.MaxFileNum > 0 && len(l.logNames) >= l.cfg.MaxFileNum {
+		l.purge(len(l.logNames) - l.cfg.MaxFileNum + 1)
 	}
 
 	l.logNames = append(l.logNames, lastName)
+	l.logFirstIDs = append(l.logFirstIDs, 0)
+	l.logFileSize = int64(fileHeaderSize)
 
 	if l.logWb == nil {
 		l.logWb = bufio.NewWriterSize(l.logFile, 1024)
@@ -163,6 +472,11 @@ func (l *BinLog) openNewLogFile() error {
 		l.logWb.Reset(l.logFile)
 	}
 
+	//the header is flushed with the first batch
+	if _, err = l.logWb.Write(fileHeader()); err != nil {
+		return err
+	}
+
 	if err = l.flushIndex(); err != nil {
 		return err
 	}
@@ -175,10 +489,11 @@ func (l *BinLog) checkLogFileSize() bool {
 		return false
 	}
 
-	st, _ := l.logFile.Stat()
-	if st.Size() >= int64(l.cfg.MaxFileSize) {
+	if l.logFileSize >= int64(l.cfg.MaxFileSize) {
 		l.lastLogIndex++
 
+		//no more write to this file, make sure it is durable
+		l.logFile.Sync()
 		l.logFile.Close()
 		l.logFile = nil
 		return true
@@ -195,36 +510,194 @@ func (l *BinLog) purge(n int) {
 
 	copy(l.logNames[0:], l.logNames[n:])
 	l.logNames = l.logNames[0 : len(l.logNames)-n]
+
+	copy(l.logFirstIDs[0:], l.logFirstIDs[n:])
+	l.logFirstIDs = l.logFirstIDs[0 : len(l.logFirstIDs)-n]
+}
+
+func (l *BinLog) parseLogFileIndex(name string) (int64, error) {
+	ext := path.Ext(name)
+	if len(ext) == 0 {
+		return 0, fmt.Errorf("invalid logfile name %s", name)
+	}
+
+	return strconv.ParseInt(ext[1:], 10, 64)
 }
 
+//Close flushes and fsyncs the current log file before closing
 func (l *BinLog) Close() {
+	l.Lock()
+	defer l.Unlock()
+
 	if l.logFile != nil {
+		if err := l.logWb.Flush(); err != nil {
+			log.Error("flush log error %s", err.Error())
+		}
+
+		if err := l.logFile.Sync(); err != nil {
+			log.Error("sync log error %s", err.Error())
+		}
+
 		l.logFile.Close()
 		l.logFile = nil
 	}
 }
 
+//Sync flushes the current log file to disk
+func (l *BinLog) Sync() error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.logFile == nil {
+		return nil
+	}
+
+	return l.logFile.Sync()
+}
+
 func (l *BinLog) LogNames() []string {
-	return l.logNames
+	l.Lock()
+	defer l.Unlock()
+
+	names := make([]string, len(l.logNames))
+	copy(names, l.logNames)
+	return names
 }
 
 func (l *BinLog) LogFileName() string {
+	l.Lock()
+	defer l.Unlock()
+
 	return l.getLogFile()
 }
 
 func (l *BinLog) LogFilePos() int64 {
+	l.Lock()
+	defer l.Unlock()
+
 	if l.logFile == nil {
 		return 0
 	} else {
-		st, _ := l.logFile.Stat()
-		return st.Size()
+		return l.logFileSize
 	}
 }
 
 func (l *BinLog) LogFileIndex() int64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.lastLogIndex
+}
+
+//LastLogID returns the log id of the last logged batch, 0 if nothing logged
+func (l *BinLog) LastLogID() uint64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.lastLogID
+}
+
+//Limits returns the max size of a log file and the max number of log files
+func (l *BinLog) Limits() (maxFileSize int, maxFileNum int) {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.cfg.MaxFileSize, l.cfg.MaxFileNum
+}
+
+//SetLimits changes the limits at runtime, the values are adjusted like in config,
+//the oldest files over max number are purged when a new log file opens
+func (l *BinLog) SetLimits(maxFileSize int, maxFileNum int) {
+	l.Lock()
+	defer l.Unlock()
+
+	l.cfg.MaxFileSize = maxFileSize
+	l.cfg.MaxFileNum = maxFileNum
+	l.cfg.Adjust()
+}
+
+//WrittenBytes returns the bytes of all events written since open
+func (l *BinLog) WrittenBytes() int64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.writtenBytes
+}
+
+//FirstLogID returns the oldest log id still kept in log files
+func (l *BinLog) FirstLogID() uint64 {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, id := range l.logFirstIDs {
+		if id != 0 {
+			return id
+		}
+	}
+
+	return l.lastLogID + 1
+}
+
+//LogFileIndexOf returns the index of the log file keeping the batch after logID
+func (l *BinLog) LogFileIndexOf(logID uint64) int64 {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.logFileIndexOf(logID)
+}
+
+func (l *BinLog) logFileIndexOf(logID uint64) int64 {
+	for i := len(l.logNames) - 1; i >= 0; i-- {
+		if id := l.logFirstIDs[i]; id != 0 && id <= logID+1 {
+			index, _ := l.parseLogFileIndex(l.logNames[i])
+			return index
+		}
+	}
+
+	if len(l.logNames) > 0 {
+		index, _ := l.parseLogFileIndex(l.logNames[0])
+		return index
+	}
+
 	return l.lastLogIndex
 }
 
+//readPos returns where to read the batch after logID, and where the
+//complete events in current log file end
+func (l *BinLog) readPos(logID uint64) (from logPos, end logPos) {
+	l.Lock()
+	defer l.Unlock()
+
+	var ok bool
+	if from, ok = l.syncPos[logID]; !ok {
+		from = logPos{l.logFileIndexOf(logID), int64(fileHeaderSize)}
+	}
+
+	end = logPos{l.lastLogIndex, int64(fileHeaderSize)}
+	if l.logFile != nil {
+		end.pos = l.logFileSize
+	}
+
+	return
+}
+
+func (l *BinLog) saveReadPos(logID uint64, pos logPos) {
+	l.Lock()
+	defer l.Unlock()
+
+	if _, ok := l.syncPos[logID]; !ok {
+		if len(l.syncPosIDs) >= maxSyncPosNum {
+			//evict the oldest, slaves still syncing save newer positions
+			delete(l.syncPos, l.syncPosIDs[0])
+			copy(l.syncPosIDs, l.syncPosIDs[1:])
+			l.syncPosIDs = l.syncPosIDs[0 : len(l.syncPosIDs)-1]
+		}
+		l.syncPosIDs = append(l.syncPosIDs, logID)
+	}
+
+	l.syncPos[logID] = pos
+}
+
 func (l *BinLog) FormatLogFileName(index int64) string {
 	return fmt.Sprintf("ledis-bin.%07d", index)
 }
@@ -238,6 +711,13 @@ func (l *BinLog) LogPath() string {
 }
 
 func (l *BinLog) Purge(n int) error {
+	l.Lock()
+	defer l.Unlock()
+
+	return l.purgeLogs(n)
+}
+
+func (l *BinLog) purgeLogs(n int) error {
 	if len(l.logNames) == 0 {
 		return nil
 	}
@@ -255,7 +735,93 @@ func (l *BinLog) Purge(n int) error {
 	return l.flushIndex()
 }
 
+//PurgeTo removes all log files whose index is less than index
+func (l *BinLog) PurgeTo(index int64) error {
+	l.Lock()
+	defer l.Unlock()
+
+	n := 0
+	for ; n < len(l.logNames); n++ {
+		if i, err := l.parseLogFileIndex(l.logNames[n]); err != nil {
+			return err
+		} else if i >= index {
+			break
+		}
+	}
+
+	return l.purgeLogs(n)
+}
+
+//LogFileIndexSince returns the index of the oldest log file modified at or after t,
+//if all log files are older than t, returns the current log file index
+func (l *BinLog) LogFileIndexSince(t time.Time) (int64, error) {
+	l.Lock()
+	defer l.Unlock()
+
+	for _, name := range l.logNames {
+		st, err := os.Stat(path.Join(l.path, name))
+		if err != nil {
+			return 0, err
+		}
+
+		if !st.ModTime().Before(t) {
+			return l.parseLogFileIndex(name)
+		}
+	}
+
+	return l.lastLogIndex, nil
+}
+
 func (l *BinLog) Log(args ...[]byte) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(args) == 0 {
+		return nil
+	}
+
+	//we treat log many args as a batch, so use same log id and createTime
+	return l.writeBatch(l.lastLogID+1, uint32(time.Now().Unix()), args)
+}
+
+//logReplicated logs a batch replicated from master with its origin log id and create time,
+//a batch not newer than the last logged one is skipped, it is replicated again after a restart
+func (l *BinLog) logReplicated(logID uint64, createTime uint32, args [][]byte) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if len(args) == 0 || logID <= l.lastLogID {
+		return nil
+	}
+
+	return l.writeBatch(logID, createTime, args)
+}
+
+//reset removes all log files, the next replicated batch is logged after lastLogID,
+//used when the data is replaced by a full sync from master
+func (l *BinLog) reset(lastLogID uint64) error {
+	l.Lock()
+	defer l.Unlock()
+
+	if l.logFile != nil {
+		l.logWb.Flush()
+		l.logFile.Close()
+		l.logFile = nil
+	}
+
+	l.purge(len(l.logNames))
+
+	//new log files never reuse the names of removed ones
+	l.lastLogIndex++
+	l.lastLogID = lastLogID
+	l.syncPos = make(map[uint64]logPos)
+	l.syncPosIDs = l.syncPosIDs[0:0]
+
+	return l.flushIndex()
+}
+
+//must hold lock
+func (l *BinLog) writeBatch(logID uint64, createTime uint32, args [][]byte) error {
 	var err error
 
 	if l.logFile == nil {
@@ -264,12 +830,15 @@ func (l *BinLog) Log(args ...[]byte) error {
 		}
 	}
 
-	//we treat log many args as a batch, so use same createTime
-	createTime := uint32(time.Now().Unix())
+	size := int64(0)
 
 	for _, data := range args {
 		payLoadLen := uint32(len(data))
 
+		if err := binary.Write(l.logWb, binary.BigEndian, logID); err != nil {
+			return err
+		}
+
 		if err := binary.Write(l.logWb, binary.BigEndian, createTime); err != nil {
 			return err
 		}
@@ -278,9 +847,15 @@ func (l *BinLog) Log(args ...[]byte) error {
 			return err
 		}
 
+		if err := binary.Write(l.logWb, binary.BigEndian, crc32.ChecksumIEEE(data)); err != nil {
+			return err
+		}
+
 		if _, err := l.logWb.Write(data); err != nil {
 			return err
 		}
+
+		size += eventHeaderSize + int64(payLoadLen)
 	}
 
 	if err = l.logWb.Flush(); err != nil {
@@ -288,6 +863,14 @@ func (l *BinLog) Log(args ...[]byte) error {
 		return err
 	}
 
+	l.lastLogID = logID
+	l.logFileSize += size
+	l.writtenBytes += size
+
+	if last := len(l.logFirstIDs) - 1; l.logFirstIDs[last] == 0 {
+		l.logFirstIDs[last] = logID
+	}
+
 	l.checkLogFileSize()
 
 	return nil
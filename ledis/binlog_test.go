@@ -35,3 +35,167 @@ func TestBinLog(t *testing.T) {
 		t.Fatal(len(fs))
 	}
 }
+
+func TestBinLogRecover(t *testing.T) {
+	cfg := new(config.Config)
+
+	cfg.BinLog.MaxFileNum = 10
+	cfg.BinLog.MaxFileSize = 1024
+	cfg.DataDir = "/tmp/ledis_binlog_recover"
+
+	os.RemoveAll(cfg.DataDir)
+
+	b, err := NewBinLog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Log([]byte("a"), []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	index := b.LogFileIndex()
+	pos := b.LogFilePos()
+	logID := b.LastLogID()
+	b.Close()
+
+	//simulate a torn event after crash
+	f, err := os.OpenFile(b.FormatLogFilePath(index), os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 1, 0, 0})
+	f.Close()
+
+	if b, err = NewBinLog(cfg); err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	if b.LogFileIndex() != index {
+		t.Fatal(b.LogFileIndex(), index)
+	} else if b.LogFilePos() != pos {
+		t.Fatal(b.LogFilePos(), pos)
+	} else if b.LastLogID() != logID {
+		t.Fatal(b.LastLogID(), logID)
+	}
+
+	if err := b.Log([]byte("c")); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err = os.Open(b.FormatLogFilePath(index))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err = ReadBinLogFileHeader(f); err != nil {
+		t.Fatal(err)
+	}
+
+	var events []string
+	var ids []uint64
+	err = ReadEventFromReader(f, func(logID uint64, createTime uint32, event []byte) error {
+		events = append(events, string(event))
+		ids = append(ids, logID)
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	} else if len(events) != 3 || events[2] != "c" {
+		t.Fatal(events)
+	} else if ids[0] != 1 || ids[1] != 1 || ids[2] != 2 {
+		t.Fatal(ids)
+	}
+}
+
+func TestBinLogOldFormat(t *testing.T) {
+	cfg := new(config.Config)
+
+	cfg.DataDir = "/tmp/ledis_binlog_format"
+
+	os.RemoveAll(cfg.DataDir)
+
+	b, err := NewBinLog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := b.Log([]byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	index := b.LogFileIndex()
+	name := b.FormatLogFilePath(index)
+	b.Close()
+
+	//a log file written by an older version has no header
+	old := make([]byte, 8+1)
+	old[7] = 1
+	if err := ioutil.WriteFile(name, old, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if b, err = NewBinLog(cfg); err != nil {
+		t.Fatal(err)
+	}
+
+	if st, err := os.Stat(name + ".old"); err != nil {
+		t.Fatal(err)
+	} else if st.Size() != int64(len(old)) {
+		t.Fatal("old log file must be kept", st.Size())
+	}
+
+	if n := len(b.LogNames()); n != 0 {
+		t.Fatal(n)
+	} else if b.LastLogID() != 0 {
+		t.Fatal(b.LastLogID())
+	} else if b.LogFileIndex() != index+1 {
+		t.Fatal(b.LogFileIndex())
+	}
+
+	if err := b.Log([]byte("b")); err != nil {
+		t.Fatal(err)
+	}
+	b.Close()
+
+	//a newer version is refused
+	head := fileHeader()
+	head[len(head)-1] = binLogVersion + 1
+	if err := ioutil.WriteFile(b.FormatLogFilePath(index+1), head, 0666); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = NewBinLog(cfg); err == nil {
+		t.Fatal("must refuse unknown version")
+	}
+}
+
+func TestBinLogSyncPos(t *testing.T) {
+	cfg := new(config.Config)
+
+	cfg.DataDir = "/tmp/ledis_binlog_syncpos"
+
+	os.RemoveAll(cfg.DataDir)
+
+	b, err := NewBinLog(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer b.Close()
+
+	for i := 1; i <= maxSyncPosNum+1; i++ {
+		b.saveReadPos(uint64(i), logPos{1, int64(i)})
+	}
+
+	//the oldest position is evicted, the newer ones are kept
+	if len(b.syncPos) != maxSyncPosNum {
+		t.Fatal(len(b.syncPos))
+	} else if _, ok := b.syncPos[1]; ok {
+		t.Fatal("oldest position must be evicted")
+	} else if pos := b.syncPos[maxSyncPosNum+1]; pos.pos != maxSyncPosNum+1 {
+		t.Fatal(pos)
+	}
+}
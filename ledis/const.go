@@ -51,6 +51,7 @@ var (
 	errZSetMemberSize = errors.New("invalid zset member size")
 	errExpireValue    = errors.New("invalid expire value")
 	errListIndex      = errors.New("invalid list index")
+	errDataType       = errors.New("invalid data type")
 )
 
 const (
@@ -72,6 +73,8 @@ const (
 
 var (
 	ErrScoreMiss = errors.New("zset score miss")
+	ErrNestTx    = errors.New("nested transaction is not supported")
+	ErrTxDone    = errors.New("transaction has been committed or rolled back")
 )
 
 const (
@@ -54,6 +54,16 @@
 //  n, err := db.ZAdd(key, ScorePair{score1, member1}, ScorePair{score2, member2})
 //  ay, err := db.ZRangeByScore(key, minScore, maxScore, 0, -1)
 //
+// Transaction
+//
+// Tx has the same api of DB, reads in it see its own writes, and all writes are committed or rolled back at once.
+// Other writes wait until the transaction ends. Stores without transaction, e.g. leveldb, buffer the writes in memory.
+//
+//  tx, err := db.Begin()
+//  err = tx.Set(key, value)
+//  n, err := tx.HSet(key2, field, value)
+//  err = tx.Commit()
+//
 // Binlog
 //
 // ledis supports binlog, so you can sync binlog to another server for replication. If you want to open binlog support, set UseBinLog to true in config.
@@ -10,34 +10,25 @@ import (
 )
 
 //dump format
-// fileIndex(bigendian int64)|filePos(bigendian int64)
+// logID(bigendian uint64)
 // |keylen(bigendian int32)|key|valuelen(bigendian int32)|value......
 //
 //key and value are both compressed for fast transfer dump on network using snappy
 
 type MasterInfo struct {
-	LogFileIndex int64
-	LogPos       int64
+	//log id of the last batch included, 0 if binlog is not enabled
+	LogID uint64 `json:"log_id"`
 }
 
 func (m *MasterInfo) WriteTo(w io.Writer) error {
-	if err := binary.Write(w, binary.BigEndian, m.LogFileIndex); err != nil {
-		return err
-	}
-
-	if err := binary.Write(w, binary.BigEndian, m.LogPos); err != nil {
+	if err := binary.Write(w, binary.BigEndian, m.LogID); err != nil {
 		return err
 	}
 	return nil
 }
 
 func (m *MasterInfo) ReadFrom(r io.Reader) error {
-	err := binary.Read(r, binary.BigEndian, &m.LogFileIndex)
-	if err != nil {
-		return err
-	}
-
-	err = binary.Read(r, binary.BigEndian, &m.LogPos)
+	err := binary.Read(r, binary.BigEndian, &m.LogID)
 	if err != nil {
 		return err
 	}
@@ -56,23 +47,38 @@ func (l *Ledis) DumpFile(path string) error {
 }
 
 func (l *Ledis) Dump(w io.Writer) error {
+	_, err := l.dump(w)
+	return err
+}
+
+//dump reads the log id and opens the iterator under lock, the lock is held
+//until all data is written only if the store iterator does not read a snapshot
+func (l *Ledis) dump(w io.Writer) (*MasterInfo, error) {
 	var m *MasterInfo = new(MasterInfo)
+
 	l.Lock()
-	defer l.Unlock()
 
 	if l.binlog != nil {
-		m.LogFileIndex = l.binlog.LogFileIndex()
-		m.LogPos = l.binlog.LogFilePos()
+		m.LogID = l.binlog.LastLogID()
+	}
+
+	it := l.ldb.NewIterator()
+
+	if l.ldb.Capabilities().Snapshot {
+		l.Unlock()
+	} else {
+		defer l.Unlock()
 	}
 
+	defer it.Close()
+
 	var err error
 
 	wb := bufio.NewWriterSize(w, 4096)
 	if err = m.WriteTo(wb); err != nil {
-		return err
+		return nil, err
 	}
 
-	it := l.ldb.NewIterator()
 	it.SeekToFirst()
 
 	compressBuf := make([]byte, 4096)
@@ -84,37 +90,37 @@ func (l *Ledis) Dump(w io.Writer) error {
 		value = it.Value()
 
 		if key, err = snappy.Encode(compressBuf, key); err != nil {
-			return err
+			return nil, err
 		}
 
 		if err = binary.Write(wb, binary.BigEndian, uint16(len(key))); err != nil {
-			return err
+			return nil, err
 		}
 
 		if _, err = wb.Write(key); err != nil {
-			return err
+			return nil, err
 		}
 
 		if value, err = snappy.Encode(compressBuf, value); err != nil {
-			return err
+			return nil, err
 		}
 
 		if err = binary.Write(wb, binary.BigEndian, uint32(len(value))); err != nil {
-			return err
+			return nil, err
 		}
 
 		if _, err = wb.Write(value); err != nil {
-			return err
+			return nil, err
 		}
 	}
 
 	if err = wb.Flush(); err != nil {
-		return err
+		return nil, err
 	}
 
 	compressBuf = nil
 
-	return nil
+	return m, nil
 }
 
 func (l *Ledis) LoadDumpFile(path string) (*MasterInfo, error) {
@@ -127,10 +133,70 @@ func (l *Ledis) LoadDumpFile(path string) (*MasterInfo, error) {
 	return l.LoadDump(f)
 }
 
+//LoadDump loads the data of dump, which is logged if binlog is enabled
 func (l *Ledis) LoadDump(r io.Reader) (*MasterInfo, error) {
-	l.Lock()
-	defer l.Unlock()
+	l.lockWrite()
+	defer l.unlockWrite()
 
+	return l.loadDump(r, l.binlog != nil)
+}
+
+//ReplicateFromDumpFile replaces all data with the dump of master.
+//
+//if binlog is enabled, it is reset to continue after the log id of the dump
+//instead of logging the data, so the log ids are the same as master
+func (l *Ledis) ReplicateFromDumpFile(path string) (*MasterInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	l.lockWrite()
+	defer l.unlockWrite()
+
+	//the dump has all data of master store, so remove all of ours without logging
+	if err = l.clearStore(); err != nil {
+		return nil, err
+	}
+
+	var info *MasterInfo
+	if info, err = l.loadDump(f, false); err != nil {
+		return nil, err
+	}
+
+	if l.binlog != nil {
+		if err = l.binlog.reset(info.LogID); err != nil {
+			return nil, err
+		}
+	}
+
+	return info, nil
+}
+
+//must hold lock
+func (l *Ledis) clearStore() error {
+	it := l.ldb.NewIterator()
+	defer it.Close()
+
+	wb := l.ldb.NewWriteBatch()
+
+	n := 0
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		wb.Delete(it.Key())
+		if n++; n%1024 == 0 {
+			if err := wb.Commit(); err != nil {
+				return err
+			}
+			wb.Rollback()
+		}
+	}
+
+	return wb.Commit()
+}
+
+//must hold lock
+func (l *Ledis) loadDump(r io.Reader, logged bool) (*MasterInfo, error) {
 	info := new(MasterInfo)
 
 	rb := bufio.NewReaderSize(r, 4096)
@@ -182,8 +248,10 @@ func (l *Ledis) LoadDump(r io.Reader) (*MasterInfo, error) {
 			return nil, err
 		}
 
-		if l.binlog != nil {
-			err = l.binlog.Log(encodeBinLogPut(key, value))
+		if logged {
+			if err = l.binlog.Log(encodeBinLogPut(key, value)); err != nil {
+				return nil, err
+			}
 		}
 
 		keyBuf.Reset()
@@ -193,5 +261,9 @@ func (l *Ledis) LoadDump(r io.Reader) (*MasterInfo, error) {
 	deKeyBuf = nil
 	deValueBuf = nil
 
+	if err = l.waitSync(l.written()); err != nil {
+		return nil, err
+	}
+
 	return info, nil
 }
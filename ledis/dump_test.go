@@ -6,6 +6,7 @@ import (
 	"github.com/siddontang/ledisdb/store"
 	"os"
 	"testing"
+	"time"
 )
 
 func TestDump(t *testing.T) {
@@ -53,4 +54,44 @@ func TestDump(t *testing.T) {
 			t.Fatal("load dump error")
 		}
 	}
+
+	//writes need not wait a dump streaming from a snapshot
+	if master.ldb.Capabilities().Snapshot {
+		w := &blockWriter{make(chan struct{}), make(chan struct{})}
+		go master.Dump(w)
+
+		<-w.writing
+
+		done := make(chan error, 1)
+		go func() {
+			done <- db.Set([]byte("d"), []byte("4"))
+		}()
+
+		select {
+		case err = <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+		case <-time.After(1 * time.Second):
+			t.Fatal("write must not wait the dump")
+		}
+
+		close(w.release)
+	}
+}
+
+type blockWriter struct {
+	writing chan struct{}
+	release chan struct{}
+}
+
+func (w *blockWriter) Write(p []byte) (int, error) {
+	select {
+	case <-w.writing:
+	default:
+		close(w.writing)
+	}
+
+	<-w.release
+	return len(p), nil
 }
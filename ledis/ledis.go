@@ -6,6 +6,7 @@ import (
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -14,8 +15,14 @@ type DB struct {
 
 	db *store.DB
 
+	//where to read, the store, or a transaction in Tx
+	bucket ibucket
+
 	index uint8
 
+	//nil if not in a transaction
+	tx *Tx
+
 	kvTx   *tx
 	listTx *tx
 	hashTx *tx
@@ -33,8 +40,35 @@ type Ledis struct {
 
 	binlog *BinLog
 
+	//held by a Tx over a store transaction, other writes of store take it
+	//shared before the global lock, see lockWrite
+	storeTxLock sync.RWMutex
+
+	//nil if sync mode is none
+	syncer *groupSyncer
+
 	quit chan struct{}
 	jobs *sync.WaitGroup
+
+	//not use the write lock, which a transaction holds long
+	expireLock sync.Mutex
+	expireStat ExpireStat
+
+	//holds a Notifier
+	notifier atomic.Value
+	//notify flags of dbs
+	notifyFlags [MaxDBNumber]int32
+}
+
+//ExpireStat is the statistics of active expire cycles
+type ExpireStat struct {
+	//number of finished cycles
+	Cycles int64
+	//total and last duration of cycles
+	Duration     time.Duration
+	LastDuration time.Duration
+	//number of keys expired
+	Expired int64
 }
 
 func Open(cfg *config.Config) (*Ledis, error) {
@@ -43,6 +77,10 @@ func Open(cfg *config.Config) (*Ledis, error) {
 		cfg.DataDir = config.DefaultDataDir
 	}
 
+	if err := cfg.CheckSyncMode(); err != nil {
+		return nil, err
+	}
+
 	ldb, err := store.Open(cfg)
 	if err != nil {
 		return nil, err
@@ -50,6 +88,8 @@ func Open(cfg *config.Config) (*Ledis, error) {
 
 	l := new(Ledis)
 
+	l.cfg = cfg
+
 	l.quit = make(chan struct{})
 	l.jobs = new(sync.WaitGroup)
 
@@ -71,6 +111,14 @@ func Open(cfg *config.Config) (*Ledis, error) {
 
 	l.activeExpireCycle()
 
+	if cfg.SyncMode != config.SyncModeNone {
+		l.syncer = newGroupSyncer(l.syncData)
+	}
+
+	if cfg.SyncMode == config.SyncModeEverySec {
+		l.syncCycle()
+	}
+
 	return l, nil
 }
 
@@ -80,6 +128,7 @@ func newDB(l *Ledis, index uint8) *DB {
 	d.l = l
 
 	d.db = l.ldb
+	d.bucket = l.ldb
 
 	d.index = index
 
@@ -92,10 +141,20 @@ func newDB(l *Ledis, index uint8) *DB {
 	return d
 }
 
+func (db *DB) Index() int {
+	return int(db.index)
+}
+
 func (l *Ledis) Close() {
 	close(l.quit)
 	l.jobs.Wait()
 
+	if l.syncer != nil {
+		if err := l.syncer.syncAll(); err != nil {
+			log.Error("sync data error %s", err.Error())
+		}
+	}
+
 	l.ldb.Close()
 
 	if l.binlog != nil {
@@ -127,6 +186,11 @@ func (l *Ledis) DataDB() *store.DB {
 	return l.ldb
 }
 
+//nil if binlog is not enabled
+func (l *Ledis) BinLog() *BinLog {
+	return l.binlog
+}
+
 func (l *Ledis) activeExpireCycle() {
 	var executors []*elimination = make([]*elimination, len(l.dbs))
 	for i, db := range l.dbs {
@@ -142,9 +206,12 @@ func (l *Ledis) activeExpireCycle() {
 			select {
 			case <-tick.C:
 				go func() {
+					start := time.Now()
+					var expired int64
 					for _, eli := range executors {
-						eli.active()
+						expired += eli.active()
 					}
+					l.addExpireStat(time.Since(start), expired)
 					done <- struct{}{}
 				}()
 				<-done
@@ -158,3 +225,25 @@ func (l *Ledis) activeExpireCycle() {
 		l.jobs.Done()
 	}()
 }
+
+func (l *Ledis) addExpireStat(d time.Duration, expired int64) {
+	l.expireLock.Lock()
+	l.expireStat.Cycles++
+	l.expireStat.Duration += d
+	l.expireStat.LastDuration = d
+	l.expireStat.Expired += expired
+	l.expireLock.Unlock()
+}
+
+//ExpireStat returns the statistics of active expire cycles
+func (l *Ledis) ExpireStat() ExpireStat {
+	l.expireLock.Lock()
+	defer l.expireLock.Unlock()
+
+	return l.expireStat
+}
+
+//Compact compacts all data in store
+func (l *Ledis) Compact() error {
+	return l.ldb.CompactRange(nil, nil)
+}
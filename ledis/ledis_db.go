@@ -4,6 +4,17 @@ import (
 	"github.com/siddontang/ledisdb/store"
 )
 
+type ibucket interface {
+	Get(key []byte) ([]byte, error)
+
+	NewIterator() *store.Iterator
+
+	RangeIterator(min []byte, max []byte, rangeType uint8) *store.RangeLimitIterator
+	RevRangeIterator(min []byte, max []byte, rangeType uint8) *store.RangeLimitIterator
+	RangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *store.RangeLimitIterator
+	RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *store.RangeLimitIterator
+}
+
 func (db *DB) FlushAll() (drop int64, err error) {
 	all := [...](func() (int64, error)){
 		db.flush,
@@ -36,11 +47,12 @@ func (db *DB) newEliminator() *elimination {
 }
 
 func (db *DB) flushRegion(t *tx, minKey []byte, maxKey []byte) (drop int64, err error) {
-	it := db.db.RangeIterator(minKey, maxKey, store.RangeROpen)
+	it := db.bucket.RangeIterator(minKey, maxKey, store.RangeROpen)
 	for ; it.Valid(); it.Next() {
 		t.Delete(it.RawKey())
 		drop++
-		if drop&1023 == 0 {
+		//writes in a transaction are committed with it
+		if drop&1023 == 0 && db.tx == nil {
 			if err = t.Commit(); err != nil {
 				return
 			}
@@ -49,3 +61,48 @@ func (db *DB) flushRegion(t *tx, minKey []byte, maxKey []byte) (drop int64, err
 	it.Close()
 	return
 }
+
+//key type range [min, max) of each data type, including its meta types
+var dataTypeRanges = map[string][2]byte{
+	"kv":   {KVType, HashType},
+	"hash": {HashType, ListType},
+	"list": {ListType, ZSetType},
+	"zset": {ZSetType, BitType},
+	"bit":  {BitType, BitMetaType + 1},
+}
+
+func (db *DB) encodeRange() ([]byte, []byte) {
+	return []byte{db.index}, []byte{db.index + 1}
+}
+
+func (db *DB) encodeTypeRange(dataType string) ([]byte, []byte, error) {
+	r, ok := dataTypeRanges[dataType]
+	if !ok {
+		return nil, nil, errDataType
+	}
+
+	return []byte{db.index, r[0]}, []byte{db.index, r[1]}, nil
+}
+
+//Compact compacts all data of the db in store, so the deleted data
+//frees disk space at once
+func (db *DB) Compact() error {
+	min, max := db.encodeRange()
+	return db.db.CompactRange(min, max)
+}
+
+//CompactType compacts the data of type in the db, type is kv, hash, list, zset or bit
+func (db *DB) CompactType(dataType string) error {
+	min, max, err := db.encodeTypeRange(dataType)
+	if err != nil {
+		return err
+	}
+
+	return db.db.CompactRange(min, max)
+}
+
+//DiskSize returns the approximate disk size of the db in store
+func (db *DB) DiskSize() (int64, error) {
+	min, max := db.encodeRange()
+	return db.db.ApproximateSize(min, max)
+}
@@ -95,3 +95,41 @@ func TestFlush(t *testing.T) {
 		t.Fatal(zcnt)
 	}
 }
+
+func TestCompact(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_ledis_compact"
+	cfg.DBName = "memory"
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(1)
+
+	if n, err := db.DiskSize(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	db.Set([]byte("a"), []byte("1"))
+	db.HSet([]byte("b"), []byte("f"), []byte("2"))
+
+	if n, err := db.DiskSize(); err != nil {
+		t.Fatal(err)
+	} else if n == 0 {
+		t.Fatal("must not 0")
+	}
+
+	if err := db.CompactType("unknown"); err != errDataType {
+		t.Fatal(err)
+	}
+
+	//memory store not supports compaction
+	if err := db.CompactType("hash"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -0,0 +1,184 @@
+package ledis
+
+import (
+	"github.com/siddontang/ledisdb/store"
+)
+
+//Tx is a transaction of a db, it has the same api of DB, e.g. Set, HSet, ZAdd,
+//reads in it see its own writes, and all writes are committed or rolled back at once.
+//
+//Other writes of the db wait until the transaction ends, so transactions are serializable,
+//writes of other dbs wait too, as the store has one writer in a transaction, or if store
+//does not support transactions, writes are buffered with the global lock held.
+//Using the db itself in a transaction deadlocks. Tx is not thread safe.
+type Tx struct {
+	*DB
+
+	parent *DB
+
+	tx *store.Tx
+
+	//the global write lock is held, only when writes are buffered,
+	//else the store transaction lock is held
+	locked bool
+
+	//binlog of all commits in tx
+	logs [][]byte
+
+	//key events of all commits in tx, notified after the tx commits
+	events []*KeyEvent
+}
+
+//Begin starts a transaction, uses a real transaction of store if supported,
+//else buffers writes in memory over the store.
+func (db *DB) Begin() (*Tx, error) {
+	if db.tx != nil {
+		return nil, ErrNestTx
+	}
+
+	db.lockAll()
+
+	t := new(Tx)
+	t.parent = db
+
+	if db.l.ldb.Capabilities().Tx {
+		//other writes wait before the global lock, which is taken when commit
+		db.l.storeTxLock.Lock()
+
+		var err error
+		if t.tx, err = db.l.ldb.Begin(); err != nil {
+			db.l.storeTxLock.Unlock()
+			db.unlockAll()
+			return nil, err
+		}
+	} else {
+		//no one else writes now, a buffer over store is enough
+		db.l.Lock()
+		t.locked = true
+		t.tx = db.l.ldb.BeginBuffer()
+	}
+
+	d := new(DB)
+	d.l = db.l
+	d.db = db.db
+	d.bucket = t.tx
+	d.index = db.index
+	d.tx = t
+
+	t.DB = d
+
+	d.kvTx = t.newBatch()
+	d.listTx = t.newBatch()
+	d.hashTx = t.newBatch()
+	d.zsetTx = t.newBatch()
+	d.binTx = t.newBatch()
+
+	return t, nil
+}
+
+//lockAll stops all writes of db
+func (db *DB) lockAll() {
+	db.kvTx.Lock()
+	db.listTx.Lock()
+	db.hashTx.Lock()
+	db.zsetTx.Lock()
+	db.binTx.Lock()
+}
+
+func (db *DB) unlockAll() {
+	db.binTx.Unlock()
+	db.zsetTx.Unlock()
+	db.hashTx.Unlock()
+	db.listTx.Unlock()
+	db.kvTx.Unlock()
+}
+
+func (t *Tx) newBatch() *tx {
+	b := new(tx)
+
+	b.l = t.l
+	b.wb = t.tx.NewWriteBatch()
+
+	b.batch = make([][]byte, 0, 4)
+	b.binlog = t.l.binlog
+	b.ltx = t
+	return b
+}
+
+//commitBatch writes the batch to tx, it is seen by reads in tx at once
+func (t *Tx) commitBatch(b *tx) error {
+	if t.tx == nil {
+		return ErrTxDone
+	}
+
+	if err := b.wb.Commit(); err != nil {
+		return err
+	}
+
+	if b.binlog != nil {
+		t.logs = append(t.logs, b.batch...)
+	}
+
+	t.events = append(t.events, b.events...)
+	b.events = nil
+	return nil
+}
+
+func (t *Tx) Commit() error {
+	if t.tx == nil {
+		return ErrTxDone
+	}
+
+	//commit and log at once, so a dump or backup sees both or neither
+	if !t.locked {
+		t.l.Lock()
+	}
+
+	err := t.tx.Commit()
+
+	if err == nil && t.l.binlog != nil && len(t.logs) > 0 {
+		err = t.l.binlog.Log(t.logs...)
+	}
+
+	seq := t.l.written()
+
+	if !t.locked {
+		t.l.Unlock()
+	}
+
+	events := t.events
+	t.end()
+
+	if err != nil {
+		return err
+	}
+
+	t.l.notify(events)
+
+	return t.l.waitSync(seq)
+}
+
+func (t *Tx) Rollback() error {
+	if t.tx == nil {
+		return ErrTxDone
+	}
+
+	err := t.tx.Rollback()
+	t.end()
+
+	return err
+}
+
+func (t *Tx) end() {
+	t.tx = nil
+	t.logs = nil
+	t.events = nil
+
+	if t.locked {
+		t.l.Unlock()
+		t.locked = false
+	} else {
+		t.l.storeTxLock.Unlock()
+	}
+	t.parent.unlockAll()
+}
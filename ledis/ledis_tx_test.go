@@ -0,0 +1,225 @@
+package ledis
+
+import (
+	"github.com/siddontang/ledisdb/config"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+)
+
+func testTx(db *DB, t *testing.T) {
+	key := []byte("tx_key")
+	hkey := []byte("tx_hash")
+	zkey := []byte("tx_zset")
+
+	db.Set(key, []byte("0"))
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.Begin(); err != ErrNestTx {
+		t.Fatal(err)
+	}
+
+	if err := tx.Set(key, []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.HSet(hkey, []byte("a"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.ZAdd(zkey, ScorePair{1, []byte("a")}, ScorePair{2, []byte("b")}); err != nil {
+		t.Fatal(err)
+	}
+
+	//reads in tx see its writes
+	if v, err := tx.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "1" {
+		t.Fatal(string(v))
+	}
+
+	if n, err := tx.HLen(hkey); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if v, err := tx.ZRange(zkey, 0, -1); err != nil {
+		t.Fatal(err)
+	} else if len(v) != 2 {
+		t.Fatal(len(v))
+	}
+
+	//but not others
+	if v, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "0" {
+		t.Fatal(string(v))
+	}
+
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := tx.Commit(); err != ErrTxDone {
+		t.Fatal(err)
+	}
+
+	if n, err := db.HLen(hkey); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	tx, err = db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	//writes of others wait until tx ends
+	done := make(chan error, 1)
+	go func() {
+		done <- db.Set(key, []byte("2"))
+	}()
+
+	if _, err := tx.Incr(key); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := tx.ZAdd(zkey, ScorePair{1, []byte("a")}); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case <-done:
+		t.Fatal("write must wait tx")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := db.ZCard(zkey); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if v, err := db.Get(key); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "2" {
+		t.Fatal(string(v))
+	}
+
+	db.FlushAll()
+}
+
+func TestTx(t *testing.T) {
+	db := getTestDB()
+
+	b := db.l.binlog
+	lastID := b.LastLogID()
+
+	testTx(db, t)
+
+	//commits of tx are logged
+	if b.LastLogID() == lastID {
+		t.Fatal("tx must write binlog")
+	}
+}
+
+func TestStoreTx(t *testing.T) {
+	for _, name := range []string{"memory", "boltdb"} {
+		cfg := new(config.Config)
+		cfg.DataDir = "/tmp/test_ledis_tx"
+		cfg.DBName = name
+		cfg.BinLog.MaxFileNum = 10
+		cfg.BinLog.MaxFileSize = 1024 * 1024
+
+		os.RemoveAll(cfg.DataDir)
+
+		l, err := Open(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !l.ldb.Capabilities().Tx {
+			t.Fatal(name, "must support tx")
+		}
+
+		//FlushAll in testTx writes with an open iterator, which bolt can not remap
+		if name == "memory" {
+			db, _ := l.Select(0)
+			testTx(db, t)
+		}
+
+		testStoreTxLock(l, t)
+
+		l.Close()
+	}
+}
+
+func testStoreTxLock(l *Ledis, t *testing.T) {
+	db, _ := l.Select(0)
+
+	//a store transaction does not hold the global lock
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- l.Dump(ioutil.Discard)
+	}()
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("dump must not wait the transaction")
+	}
+
+	//a write of other db waits the transaction, which commits with the global lock
+	go func() {
+		db1, _ := l.Select(1)
+		done <- db1.Set([]byte("tx_other_db"), []byte("1"))
+	}()
+
+	if err = tx.Set([]byte("tx_key"), []byte("1")); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	logID := l.binlog.LastLogID()
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case err = <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatal("write of other db must go on after the transaction")
+	}
+
+	//the transaction is logged first
+	if id := l.binlog.LastLogID(); id != logID+2 {
+		t.Fatal(id, logID)
+	}
+}
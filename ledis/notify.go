@@ -0,0 +1,184 @@
+package ledis
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+//notify flags of a db, which events are notified and how
+const (
+	//notify in the keyspace channel of the key, the message is the event
+	NotifyKeyspace int = 1 << iota
+	//notify in the keyevent channel of the event, the message is the key
+	NotifyKeyevent
+
+	//event classes
+	NotifyGeneric
+	NotifyKV
+	NotifyList
+	NotifyHash
+	NotifyZSet
+	NotifyBit
+	NotifyExpired
+
+	NotifyAll = NotifyGeneric | NotifyKV | NotifyList | NotifyHash | NotifyZSet | NotifyBit | NotifyExpired
+)
+
+//notifyFlagChars maps flags to chars in order, 'A' is the alias of all event classes
+var notifyFlagChars = []struct {
+	flag int
+	c    byte
+}{
+	{NotifyKeyspace, 'K'},
+	{NotifyKeyevent, 'E'},
+	{NotifyGeneric, 'g'},
+	{NotifyKV, '$'},
+	{NotifyList, 'l'},
+	{NotifyHash, 'h'},
+	{NotifyZSet, 'z'},
+	{NotifyBit, 'b'},
+	{NotifyExpired, 'x'},
+}
+
+//ParseNotifyFlags parses flags like "KEA" or "Kg$", empty means no notification
+func ParseNotifyFlags(s string) (int, error) {
+	flags := 0
+
+LOOP:
+	for i := 0; i < len(s); i++ {
+		if s[i] == 'A' {
+			flags |= NotifyAll
+			continue
+		}
+
+		for _, f := range notifyFlagChars {
+			if f.c == s[i] {
+				flags |= f.flag
+				continue LOOP
+			}
+		}
+
+		return 0, fmt.Errorf("invalid notify flag %c", s[i])
+	}
+
+	return flags, nil
+}
+
+//NotifyFlagsString formats flags, all event classes are formatted as 'A'
+func NotifyFlagsString(flags int) string {
+	var b []byte
+	for _, f := range notifyFlagChars {
+		if f.flag == NotifyGeneric && flags&NotifyAll == NotifyAll {
+			b = append(b, 'A')
+			break
+		}
+
+		if flags&f.flag != 0 {
+			b = append(b, f.c)
+		}
+	}
+
+	return string(b)
+}
+
+//KeyEvent is a change of a key, sent to the notifier after it is committed
+type KeyEvent struct {
+	DB    int
+	Event string
+	Key   []byte
+
+	//NotifyKeyspace and NotifyKeyevent flags of db when it was changed
+	Flags int
+}
+
+//Notifier receives key events, it is called in the write lock, so must not block
+type Notifier func(e *KeyEvent)
+
+//SetNotifier sets the notifier of key events, nil to stop notification
+func (l *Ledis) SetNotifier(f Notifier) {
+	l.notifier.Store(f)
+}
+
+func (l *Ledis) notify(events []*KeyEvent) {
+	f, _ := l.notifier.Load().(Notifier)
+	if f == nil {
+		return
+	}
+
+	for _, e := range events {
+		f(e)
+	}
+}
+
+//SetNotifyFlags sets which events of the db are notified, flags is from ParseNotifyFlags
+func (l *Ledis) SetNotifyFlags(index int, flags int) error {
+	if index < 0 || index >= int(MaxDBNumber) {
+		return fmt.Errorf("invalid db index %d", index)
+	}
+
+	atomic.StoreInt32(&l.notifyFlags[index], int32(flags))
+	return nil
+}
+
+//NotifyFlags returns the notify flags of the db
+func (db *DB) NotifyFlags() int {
+	return int(atomic.LoadInt32(&db.l.notifyFlags[db.index]))
+}
+
+//notify records the event of key in class, it is sent after commit
+func (t *tx) notify(db *DB, class int, event string, key []byte) {
+	flags := db.NotifyFlags()
+	if flags&class == 0 || flags&(NotifyKeyspace|NotifyKeyevent) == 0 {
+		return
+	}
+
+	e := &KeyEvent{
+		DB:    int(db.index),
+		Event: event,
+		Key:   append([]byte(nil), key...),
+		Flags: flags & (NotifyKeyspace | NotifyKeyevent),
+	}
+	t.events = append(t.events, e)
+}
+
+//ParseNotifyConfig parses the notify flags for all databases, items are separated by spaces,
+//"flags" for all databases, or "index:flags" for one, e.g. "Ex 1:KEA"
+func ParseNotifyConfig(s string) ([]int, error) {
+	ay := make([]int, MaxDBNumber)
+
+	var dbs []string
+	for _, item := range strings.Fields(s) {
+		if strings.IndexByte(item, ':') >= 0 {
+			dbs = append(dbs, item)
+			continue
+		}
+
+		flags, err := ParseNotifyFlags(item)
+		if err != nil {
+			return nil, err
+		}
+
+		for i := range ay {
+			ay[i] = flags
+		}
+	}
+
+	//databases override the default wherever they are
+	for _, item := range dbs {
+		var index int
+		var flags string
+		if n, _ := fmt.Sscanf(strings.Replace(item, ":", " ", 1), "%d %s", &index, &flags); n == 0 {
+			return nil, fmt.Errorf("invalid notify config %s", item)
+		} else if index < 0 || index >= int(MaxDBNumber) {
+			return nil, fmt.Errorf("invalid db index %d", index)
+		}
+
+		var err error
+		if ay[index], err = ParseNotifyFlags(flags); err != nil {
+			return nil, err
+		}
+	}
+
+	return ay, nil
+}
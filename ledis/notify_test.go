@@ -0,0 +1,117 @@
+package ledis
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestParseNotifyConfig(t *testing.T) {
+	if flags, err := ParseNotifyFlags("KEA"); err != nil {
+		t.Fatal(err)
+	} else if flags != NotifyKeyspace|NotifyKeyevent|NotifyAll {
+		t.Fatal(flags)
+	} else if s := NotifyFlagsString(flags); s != "KEA" {
+		t.Fatal(s)
+	}
+
+	if s := NotifyFlagsString(NotifyKeyevent | NotifyKV | NotifyExpired); s != "E$x" {
+		t.Fatal(s)
+	}
+
+	if _, err := ParseNotifyFlags("Ky"); err == nil {
+		t.Fatal("must error")
+	}
+
+	ay, err := ParseNotifyConfig("3:Kh Ex")
+	if err != nil {
+		t.Fatal(err)
+	} else if ay[0] != NotifyKeyevent|NotifyExpired || ay[3] != NotifyKeyspace|NotifyHash {
+		t.Fatal(ay)
+	}
+
+	if _, err := ParseNotifyConfig("16:KEA"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+type testNotifier struct {
+	sync.Mutex
+	events []string
+}
+
+func (n *testNotifier) notify(e *KeyEvent) {
+	n.Lock()
+	n.events = append(n.events, e.Event+" "+string(e.Key))
+	n.Unlock()
+}
+
+//wait waits for the events and clears them
+func (n *testNotifier) wait(t *testing.T, expected ...string) {
+	var s string
+	for i := 0; i < 50; i++ {
+		n.Lock()
+		s = strings.Join(n.events, ",")
+		n.Unlock()
+
+		if s == strings.Join(expected, ",") {
+			n.Lock()
+			n.events = nil
+			n.Unlock()
+			return
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	t.Fatal(s)
+}
+
+func TestNotify(t *testing.T) {
+	getTestDB()
+
+	n := new(testNotifier)
+	testLedis.SetNotifier(n.notify)
+	defer testLedis.SetNotifier(nil)
+
+	db, _ := testLedis.Select(5)
+	testLedis.SetNotifyFlags(5, NotifyKeyevent|NotifyAll)
+	defer testLedis.SetNotifyFlags(5, 0)
+
+	db.Set([]byte("notify_a"), []byte("1"))
+	db.Incr([]byte("notify_a"))
+	db.HSet([]byte("notify_h"), []byte("f"), []byte("v"))
+	db.HDel([]byte("notify_h"), []byte("no_field"))
+	db.ZAdd([]byte("notify_z"), ScorePair{1, []byte("a")})
+	db.Del([]byte("notify_a"), []byte("notify_no_key"), []byte("notify_a"))
+	n.wait(t, "set notify_a", "incrby notify_a", "hset notify_h", "zadd notify_z", "del notify_a")
+
+	//filtered by class
+	testLedis.SetNotifyFlags(5, NotifyKeyevent|NotifyHash)
+	db.Set([]byte("notify_a"), []byte("1"))
+	db.HClear([]byte("notify_h"))
+	db.HSet([]byte("notify_h"), []byte("f"), []byte("v"))
+	n.wait(t, "hset notify_h")
+
+	//only after commit
+	testLedis.SetNotifyFlags(5, NotifyKeyevent|NotifyAll)
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.LPush([]byte("notify_l"), []byte("a"))
+	tx.Rollback()
+
+	if tx, err = db.Begin(); err != nil {
+		t.Fatal(err)
+	}
+	tx.RPush([]byte("notify_l"), []byte("a"))
+	n.wait(t)
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+	n.wait(t, "rpush notify_l")
+
+	db.Expire([]byte("notify_a"), 1)
+	n.wait(t, "expire notify_a", "expired notify_a")
+}
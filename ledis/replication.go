@@ -5,9 +5,14 @@ import (
 	"bytes"
 	"encoding/binary"
 	"errors"
+	"fmt"
 	"github.com/siddontang/go-log/log"
+	"github.com/siddontang/ledisdb/store"
+	"hash/crc32"
 	"io"
+	"math"
 	"os"
+	"time"
 )
 
 var (
@@ -17,9 +22,33 @@ var (
 var (
 	errInvalidBinLogEvent = errors.New("invalid binglog event")
 	errInvalidBinLogFile  = errors.New("invalid binlog file")
+	errBinLogChecksum     = errors.New("binlog event checksum mismatch")
+	errStopReplication    = errors.New("stop replication")
 )
 
+//ReplicateEvent applies the event, and logs it as a new batch if binlog is enabled
 func (l *Ledis) ReplicateEvent(event []byte) error {
+	wb := l.ldb.NewWriteBatch()
+	if err := l.replicateEvent(wb, event); err != nil {
+		return err
+	}
+
+	l.lockWrite()
+	err := wb.Commit()
+	if err == nil && l.binlog != nil {
+		err = l.binlog.Log(event)
+	}
+	seq := l.written()
+	l.unlockWrite()
+
+	if err != nil {
+		return err
+	}
+
+	return l.waitSync(seq)
+}
+
+func (l *Ledis) replicateEvent(wb store.WriteBatch, event []byte) error {
 	if len(event) == 0 {
 		return errInvalidBinLogEvent
 	}
@@ -27,9 +56,9 @@ func (l *Ledis) ReplicateEvent(event []byte) error {
 	logType := uint8(event[0])
 	switch logType {
 	case BinLogTypePut:
-		return l.replicatePutEvent(event)
+		return l.replicatePutEvent(wb, event)
 	case BinLogTypeDeletion:
-		return l.replicateDeleteEvent(event)
+		return l.replicateDeleteEvent(wb, event)
 	case BinLogTypeCommand:
 		return l.replicateCommandEvent(event)
 	default:
@@ -37,52 +66,41 @@ func (l *Ledis) ReplicateEvent(event []byte) error {
 	}
 }
 
-func (l *Ledis) replicatePutEvent(event []byte) error {
+func (l *Ledis) replicatePutEvent(wb store.WriteBatch, event []byte) error {
 	key, value, err := decodeBinLogPut(event)
 	if err != nil {
 		return err
 	}
 
-	if err = l.ldb.Put(key, value); err != nil {
-		return err
-	}
-
-	if l.binlog != nil {
-		err = l.binlog.Log(event)
-	}
-
-	return err
+	//the event buffer is reused by reader
+	wb.Put(append([]byte(nil), key...), append([]byte(nil), value...))
+	return nil
 }
 
-func (l *Ledis) replicateDeleteEvent(event []byte) error {
+func (l *Ledis) replicateDeleteEvent(wb store.WriteBatch, event []byte) error {
 	key, err := decodeBinLogDelete(event)
 	if err != nil {
 		return err
 	}
 
-	if err = l.ldb.Delete(key); err != nil {
-		return err
-	}
-
-	if l.binlog != nil {
-		err = l.binlog.Log(event)
-	}
-
-	return err
+	wb.Delete(append([]byte(nil), key...))
+	return nil
 }
 
 func (l *Ledis) replicateCommandEvent(event []byte) error {
 	return errors.New("command event not supported now")
 }
 
-func ReadEventFromReader(rb io.Reader, f func(createTime uint32, event []byte) error) error {
+func ReadEventFromReader(rb io.Reader, f func(logID uint64, createTime uint32, event []byte) error) error {
+	var logID uint64
 	var createTime uint32
 	var dataLen uint32
+	var checksum uint32
 	var dataBuf bytes.Buffer
 	var err error
 
 	for {
-		if err = binary.Read(rb, binary.BigEndian, &createTime); err != nil {
+		if err = binary.Read(rb, binary.BigEndian, &logID); err != nil {
 			if err == io.EOF {
 				break
 			} else {
@@ -90,15 +108,27 @@ func ReadEventFromReader(rb io.Reader, f func(createTime uint32, event []byte) e
 			}
 		}
 
+		if err = binary.Read(rb, binary.BigEndian, &createTime); err != nil {
+			return err
+		}
+
 		if err = binary.Read(rb, binary.BigEndian, &dataLen); err != nil {
 			return err
 		}
 
+		if err = binary.Read(rb, binary.BigEndian, &checksum); err != nil {
+			return err
+		}
+
 		if _, err = io.CopyN(&dataBuf, rb, int64(dataLen)); err != nil {
 			return err
 		}
 
-		err = f(createTime, dataBuf.Bytes())
+		if crc32.ChecksumIEEE(dataBuf.Bytes()) != checksum {
+			return errBinLogChecksum
+		}
+
+		err = f(logID, createTime, dataBuf.Bytes())
 		if err != nil && err != ErrSkipEvent {
 			return err
 		}
@@ -109,146 +139,363 @@ func ReadEventFromReader(rb io.Reader, f func(createTime uint32, event []byte) e
 	return nil
 }
 
-func (l *Ledis) ReplicateFromReader(rb io.Reader) error {
-	f := func(createTime uint32, event []byte) error {
-		err := l.ReplicateEvent(event)
-		if err != nil {
-			log.Fatal("replication error %s, skip to next", err.Error())
-			return ErrSkipEvent
+//replBatch collects the events of a replicated batch, which are written in one
+//write batch and logged together with the origin log id, so a slave can serve sync
+//to its own slaves
+type replBatch struct {
+	l  *Ledis
+	wb store.WriteBatch
+
+	logID      uint64
+	createTime uint32
+	//number of events in wb
+	num    int
+	events [][]byte
+}
+
+func newReplBatch(l *Ledis) *replBatch {
+	return &replBatch{l: l, wb: l.ldb.NewWriteBatch()}
+}
+
+func (b *replBatch) add(logID uint64, createTime uint32, event []byte) error {
+	if logID != b.logID {
+		if err := b.commit(); err != nil {
+			return err
 		}
-		return nil
+
+		b.logID = logID
+		b.createTime = createTime
 	}
 
-	return ReadEventFromReader(rb, f)
+	if err := b.l.replicateEvent(b.wb, event); err != nil {
+		log.Fatal("replication error %s, skip to next", err.Error())
+		return ErrSkipEvent
+	}
+
+	b.num++
+
+	if b.l.binlog != nil {
+		b.events = append(b.events, append([]byte(nil), event...))
+	}
+
+	return nil
 }
 
-func (l *Ledis) ReplicateFromData(data []byte) error {
-	rb := bytes.NewReader(data)
+func (b *replBatch) commit() error {
+	if b.num == 0 {
+		return nil
+	}
+
+	err := b.wb.Commit()
+	b.wb.Rollback()
+	b.num = 0
 
-	l.Lock()
-	err := l.ReplicateFromReader(rb)
-	l.Unlock()
+	if err == nil && len(b.events) > 0 {
+		err = b.l.binlog.logReplicated(b.logID, b.createTime, b.events)
+	}
 
+	b.events = b.events[0:0]
 	return err
 }
 
-func (l *Ledis) ReplicateFromBinLog(filePath string) error {
-	f, err := os.Open(filePath)
-	if err != nil {
-		return err
+//ReplicateFromReader returns the log id of the last replicated event, 0 if no event.
+//
+//if binlog is enabled, batches are logged with the log ids of master,
+//the caller must hold the write lock
+func (l *Ledis) ReplicateFromReader(rb io.Reader) (uint64, error) {
+	b := newReplBatch(l)
+
+	err := ReadEventFromReader(rb, b.add)
+	if err == nil {
+		err = b.commit()
 	}
 
-	rb := bufio.NewReaderSize(f, 4096)
+	return b.logID, err
+}
+
+func (l *Ledis) ReplicateFromData(data []byte) (uint64, error) {
+	rb := bytes.NewReader(data)
+
+	l.lockWrite()
+	lastID, err := l.ReplicateFromReader(rb)
+	seq := l.written()
+	l.unlockWrite()
 
-	l.Lock()
-	err = l.ReplicateFromReader(rb)
-	l.Unlock()
+	if err == nil {
+		err = l.waitSync(seq)
+	}
 
-	f.Close()
+	return lastID, err
+}
 
+//ReplicateFromBinLog replicates all batches in binlog file
+func (l *Ledis) ReplicateFromBinLog(filePath string) error {
+	firstID, err := readFirstLogID(filePath)
+	if err != nil || firstID == 0 {
+		return err
+	}
+
+	_, _, err = l.ReplicateFromBinLogRange(filePath, firstID-1, math.MaxUint64, math.MaxUint32)
 	return err
 }
 
-const maxSyncEvents = 64
+//ReplicateFromBinLogRange replicates the batches in binlog file whose log id is in (afterID, stopID]
+//and create time is not later than stopTime, stopped is true if a later batch is met.
+//
+//each batch is written at once, and logged with its log id if binlog is enabled.
+//an invalid event at the end of file, torn by a crash or being written, is ignored.
+//the first batch replicated must be afterID + 1, and no log id can be missing after it,
+//else the batches needed are purged and an error is returned.
+//
+//lastID is the log id of the last replicated batch, 0 if no batch replicated
+func (l *Ledis) ReplicateFromBinLogRange(filePath string, afterID uint64, stopID uint64, stopTime uint32) (lastID uint64, stopped bool, err error) {
+	var f *os.File
+	if f, err = os.Open(filePath); err != nil {
+		return
+	}
+	defer f.Close()
 
-func (l *Ledis) ReadEventsTo(info *MasterInfo, w io.Writer) (n int, err error) {
-	n = 0
-	if l.binlog == nil {
-		//binlog not supported
-		info.LogFileIndex = 0
-		info.LogPos = 0
+	var size int64
+	if size, err = validLogFileSize(f); err != nil {
+		return
+	} else if size == 0 {
+		//no complete header
 		return
 	}
 
-	index := info.LogFileIndex
-	offset := info.LogPos
+	if _, err = f.Seek(0, os.SEEK_SET); err != nil {
+		return
+	}
 
-	filePath := l.binlog.FormatLogFilePath(index)
+	rb := bufio.NewReaderSize(io.LimitReader(f, size), 4096)
 
-	var f *os.File
-	f, err = os.Open(filePath)
-	if os.IsNotExist(err) {
-		lastIndex := l.binlog.LogFileIndex()
+	if err = ReadBinLogFileHeader(rb); err != nil {
+		return
+	}
 
-		if index == lastIndex {
-			//no binlog at all
-			info.LogPos = 0
-		} else {
-			//slave binlog info had lost
-			info.LogFileIndex = -1
+	b := newReplBatch(l)
+
+	prevID := afterID
+	fn := func(logID uint64, createTime uint32, event []byte) error {
+		if logID <= afterID {
+			return nil
+		} else if logID > stopID || createTime > stopTime {
+			stopped = true
+			return errStopReplication
+		}
+
+		if logID != prevID {
+			if logID != prevID+1 {
+				return fmt.Errorf("binlog batches after %d are missing, next is %d", prevID, logID)
+			}
+			prevID = logID
 		}
+
+		lastID = logID
+
+		return b.add(logID, createTime, event)
 	}
 
-	if err != nil {
-		if os.IsNotExist(err) {
-			err = nil
+	l.lockWrite()
+	err = ReadEventFromReader(rb, fn)
+	if err == nil || err == errStopReplication {
+		if cerr := b.commit(); cerr != nil {
+			err = cerr
 		}
-		return
 	}
+	seq := l.written()
+	l.unlockWrite()
 
-	defer f.Close()
+	if err == errStopReplication {
+		err = nil
+	}
 
-	var fileSize int64
-	st, _ := f.Stat()
-	fileSize = st.Size()
+	if err == nil {
+		err = l.waitSync(seq)
+	}
+
+	return
+}
+
+const maxSyncEvents = 64
 
-	if fileSize == info.LogPos {
+//ReadEventsTo writes the whole batches logged after logID to w, at most
+//about maxSyncEvents events one time.
+//
+//if no event is written, either logID is the newest, or the events after logID
+//have been purged, compare it with binlog FirstLogID and LastLogID
+func (l *Ledis) ReadEventsTo(logID uint64, w io.Writer) (n int, err error) {
+	n = 0
+	if l.binlog == nil {
+		//binlog not supported
 		return
 	}
 
-	if _, err = f.Seek(offset, os.SEEK_SET); err != nil {
-		//may be invliad seek offset
+	if logID >= l.binlog.LastLogID() || logID+1 < l.binlog.FirstLogID() {
 		return
 	}
 
-	var lastCreateTime uint32 = 0
-	var createTime uint32
-	var dataLen uint32
+	from, end := l.binlog.readPos(logID)
 
 	var eventsNum int = 0
+	var lastID uint64 = logID
 
-	for {
-		if err = binary.Read(f, binary.BigEndian, &createTime); err != nil {
-			if err == io.EOF {
-				//we will try to use next binlog
-				if index < l.binlog.LogFileIndex() {
-					info.LogFileIndex += 1
-					info.LogPos = 0
-				}
+	var head [eventHeaderSize]byte
+	var data []byte
+
+	for index, pos := from.index, from.pos; index <= end.index; index, pos = index+1, int64(fileHeaderSize) {
+		var f *os.File
+		if f, err = os.Open(l.binlog.FormatLogFilePath(index)); err != nil {
+			if os.IsNotExist(err) {
+				//purged or not created yet
 				err = nil
+			}
+			return
+		}
+
+		if _, err = f.Seek(pos, os.SEEK_SET); err != nil {
+			f.Close()
+			return
+		}
+
+		rb := bufio.NewReaderSize(f, 4096)
+
+		for index < end.index || pos+eventHeaderSize <= end.pos {
+			if _, err = io.ReadFull(rb, head[:]); err != nil {
+				break
+			}
+
+			id := binary.BigEndian.Uint64(head[0:])
+			dataLen := binary.BigEndian.Uint32(head[12:])
+
+			if id != lastID && eventsNum >= maxSyncEvents {
+				//stop at a batch boundary
+				f.Close()
+				l.binlog.saveReadPos(lastID, logPos{index, pos})
 				return
-			} else {
+			}
+
+			if id <= logID {
+				if _, err = rb.Discard(int(dataLen)); err != nil {
+					break
+				}
+				pos += eventHeaderSize + int64(dataLen)
+				continue
+			}
+
+			if uint32(cap(data)) < dataLen {
+				data = make([]byte, dataLen)
+			}
+			data = data[0:dataLen]
+
+			if _, err = io.ReadFull(rb, data); err != nil {
+				break
+			}
+
+			if _, err = w.Write(head[:]); err != nil {
+				f.Close()
 				return
 			}
+
+			if _, err = w.Write(data); err != nil {
+				f.Close()
+				return
+			}
+
+			eventsNum++
+			lastID = id
+			n += (eventHeaderSize + int(dataLen))
+			pos += eventHeaderSize + int64(dataLen)
 		}
 
-		eventsNum++
-		if lastCreateTime == 0 {
-			lastCreateTime = createTime
-		} else if lastCreateTime != createTime {
-			return
-		} else if eventsNum > maxSyncEvents {
+		f.Close()
+
+		if err == io.EOF {
+			//end of a former log file, go on with the next one
+			err = nil
+		} else if err != nil {
 			return
 		}
 
-		if err = binary.Read(f, binary.BigEndian, &dataLen); err != nil {
-			return
+		if index == end.index {
+			l.binlog.saveReadPos(lastID, logPos{index, pos})
 		}
+	}
 
-		if err = binary.Write(w, binary.BigEndian, createTime); err != nil {
+	return
+}
+
+//ReplicationLag returns how far a slave which has replicated logID is behind,
+//size is the size of binlog events after logID, seconds is the age of the oldest
+//batch not replicated, both 0 if the slave is up to date or binlog is not enabled
+func (l *Ledis) ReplicationLag(logID uint64) (size int64, seconds int64, err error) {
+	if l.binlog == nil || logID >= l.binlog.LastLogID() {
+		return
+	}
+
+	from, end := l.binlog.readPos(logID)
+
+	var head [eventHeaderSize]byte
+	found := false
+
+	for index, pos := from.index, from.pos; index <= end.index; index, pos = index+1, int64(fileHeaderSize) {
+		var f *os.File
+		if f, err = os.Open(l.binlog.FormatLogFilePath(index)); err != nil {
+			if os.IsNotExist(err) {
+				//purged or not created yet
+				err = nil
+				continue
+			}
 			return
 		}
 
-		if err = binary.Write(w, binary.BigEndian, dataLen); err != nil {
-			return
+		fileSize := end.pos
+		if index < end.index {
+			var st os.FileInfo
+			if st, err = f.Stat(); err != nil {
+				f.Close()
+				return
+			}
+			fileSize = st.Size()
 		}
 
-		if _, err = io.CopyN(w, f, int64(dataLen)); err != nil {
-			return
+		if !found {
+			//skip events replicated and find the create time of the next batch
+			if _, err = f.Seek(pos, os.SEEK_SET); err != nil {
+				f.Close()
+				return
+			}
+
+			rb := bufio.NewReaderSize(f, 4096)
+			for pos+eventHeaderSize <= fileSize {
+				if _, err = io.ReadFull(rb, head[:]); err != nil {
+					f.Close()
+					return
+				}
+
+				if id := binary.BigEndian.Uint64(head[0:]); id > logID {
+					createTime := binary.BigEndian.Uint32(head[8:])
+					if seconds = time.Now().Unix() - int64(createTime); seconds < 0 {
+						seconds = 0
+					}
+					found = true
+					break
+				}
+
+				dataLen := binary.BigEndian.Uint32(head[12:])
+				if _, err = rb.Discard(int(dataLen)); err != nil {
+					f.Close()
+					return
+				}
+				pos += eventHeaderSize + int64(dataLen)
+			}
 		}
 
-		n += (8 + int(dataLen))
-		info.LogPos = info.LogPos + 8 + int64(dataLen)
+		f.Close()
+
+		if found && fileSize > pos {
+			size += fileSize - pos
+		}
 	}
 
 	return
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store"
+	"math"
 	"os"
 	"path"
 	"testing"
@@ -86,32 +87,319 @@ func TestReplication(t *testing.T) {
 	db.HSet([]byte("b1"), []byte("2"), []byte("value"))
 	db.HSet([]byte("c1"), []byte("3"), []byte("value"))
 
-	info := new(MasterInfo)
-	info.LogFileIndex = 1
-	info.LogPos = 0
+	var logID uint64
 	var buf bytes.Buffer
 	var n int
 
 	for {
 		buf.Reset()
-		n, err = master.ReadEventsTo(info, &buf)
+		n, err = master.ReadEventsTo(logID, &buf)
 		if err != nil {
 			t.Fatal(err)
-		} else if info.LogFileIndex == -1 {
-			t.Fatal("invalid log file index -1")
-		} else if info.LogFileIndex == 0 {
-			t.Fatal("invalid log file index 0")
-		} else {
-			if err = slave.ReplicateFromReader(&buf); err != nil {
-				t.Fatal(err)
-			}
-			if n == 0 {
-				break
-			}
+		} else if n == 0 {
+			break
 		}
+
+		if logID, err = slave.ReplicateFromReader(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if logID != master.binlog.LastLogID() {
+		t.Fatal(logID, master.binlog.LastLogID())
 	}
 
 	if err = checkLedisEqual(master, slave); err != nil {
 		t.Fatal(err)
 	}
 }
+
+func TestReplicateFromBinLogRange(t *testing.T) {
+	cfgM := new(config.Config)
+	cfgM.DataDir = "/tmp/test_repl_range/master"
+
+	cfgM.BinLog.MaxFileNum = 10
+	cfgM.BinLog.MaxFileSize = 1024
+
+	os.RemoveAll(cfgM.DataDir)
+
+	master, err := Open(cfgM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	cfgS := new(config.Config)
+	cfgS.DataDir = "/tmp/test_repl_range/slave"
+
+	os.RemoveAll(cfgS.DataDir)
+
+	slave, err := Open(cfgS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	db, _ := master.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+	db.Set([]byte("c"), []byte("3"))
+
+	p := path.Join(master.binlog.LogPath(), master.binlog.LogNames()[0])
+
+	lastID, stopped, err := slave.ReplicateFromBinLogRange(p, 1, 2, math.MaxUint32)
+	if err != nil {
+		t.Fatal(err)
+	} else if lastID != 2 || !stopped {
+		t.Fatal(lastID, stopped)
+	}
+
+	sdb, _ := slave.Select(0)
+	if v, _ := sdb.Get([]byte("a")); v != nil {
+		t.Fatal("a must not be replicated")
+	} else if v, _ := sdb.Get([]byte("b")); string(v) != "2" {
+		t.Fatal(string(v))
+	} else if v, _ := sdb.Get([]byte("c")); v != nil {
+		t.Fatal("c must not be replicated")
+	}
+
+	//batch 4 fills the first log file, batch 5 goes to the next one
+	db.Set([]byte("d"), make([]byte, 1024))
+	db.Set([]byte("e"), []byte("5"))
+
+	p = path.Join(master.binlog.LogPath(), master.binlog.LogNames()[1])
+
+	if _, _, err = slave.ReplicateFromBinLogRange(p, 2, math.MaxUint64, math.MaxUint32); err == nil {
+		t.Fatal("must error, batches 3 and 4 are missing")
+	}
+
+	if lastID, _, err = slave.ReplicateFromBinLogRange(p, 4, math.MaxUint64, math.MaxUint32); err != nil {
+		t.Fatal(err)
+	} else if lastID != 5 {
+		t.Fatal(lastID)
+	}
+}
+
+func TestReplicateFromBinLogTornTail(t *testing.T) {
+	cfgM := new(config.Config)
+	cfgM.DataDir = "/tmp/test_repl_torn/master"
+
+	cfgM.BinLog.MaxFileNum = 10
+	cfgM.BinLog.MaxFileSize = 1024
+
+	os.RemoveAll(cfgM.DataDir)
+
+	master, err := Open(cfgM)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	cfgS := new(config.Config)
+	cfgS.DataDir = "/tmp/test_repl_torn/slave"
+
+	cfgS.BinLog.MaxFileNum = 10
+	cfgS.BinLog.MaxFileSize = 1024
+
+	os.RemoveAll(cfgS.DataDir)
+
+	slave, err := Open(cfgS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer slave.Close()
+
+	db, _ := master.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.HMset([]byte("h"), FVPair{[]byte("f1"), []byte("1")}, FVPair{[]byte("f2"), []byte("2")})
+
+	p := path.Join(master.binlog.LogPath(), master.binlog.LogNames()[0])
+
+	//simulate an event being written
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_APPEND, 0666)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 0, 0, 0, 0, 3, 0, 0})
+	f.Close()
+
+	lastID, stopped, err := slave.ReplicateFromBinLogRange(p, 0, math.MaxUint64, math.MaxUint32)
+	if err != nil {
+		t.Fatal(err)
+	} else if lastID != 2 || stopped {
+		t.Fatal(lastID, stopped)
+	}
+
+	//batches are logged with the log ids of master
+	if id := slave.binlog.LastLogID(); id != 2 {
+		t.Fatal(id)
+	}
+
+	if err = checkLedisEqual(master, slave); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReplicationLag(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_repl_lag"
+
+	cfg.BinLog.MaxFileNum = 10
+	cfg.BinLog.MaxFileSize = 1024
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+	for i := 0; i < 100; i++ {
+		db.Set([]byte(fmt.Sprintf("lag_%d", i)), []byte("value"))
+	}
+
+	lastID := l.binlog.LastLogID()
+	total := l.binlog.WrittenBytes()
+
+	if size, seconds, err := l.ReplicationLag(lastID); err != nil {
+		t.Fatal(err)
+	} else if size != 0 || seconds != 0 {
+		t.Fatal(size, seconds)
+	}
+
+	if size, seconds, err := l.ReplicationLag(0); err != nil {
+		t.Fatal(err)
+	} else if size != total || seconds < 0 || seconds > 1 {
+		t.Fatal(size, total, seconds)
+	}
+
+	//position cached by the last sync
+	var buf bytes.Buffer
+	n, err := l.ReadEventsTo(0, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var syncedID uint64
+	ReadEventFromReader(&buf, func(logID uint64, _ uint32, _ []byte) error {
+		syncedID = logID
+		return nil
+	})
+
+	if size, _, err := l.ReplicationLag(syncedID); err != nil {
+		t.Fatal(err)
+	} else if size != total-int64(n) {
+		t.Fatal(size, total, n)
+	}
+}
+
+//syncLedis replicates master to slave like a syncing slave, returns the last replicated log id
+func syncLedis(t *testing.T, master *Ledis, slave *Ledis, logID uint64) uint64 {
+	var buf bytes.Buffer
+	for {
+		buf.Reset()
+		if n, err := master.ReadEventsTo(logID, &buf); err != nil {
+			t.Fatal(err)
+		} else if n == 0 {
+			return logID
+		}
+
+		var err error
+		if logID, err = slave.ReplicateFromReader(&buf); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestChainedReplication(t *testing.T) {
+	var ay [3]*Ledis
+	for i := range ay {
+		cfg := new(config.Config)
+		cfg.DataDir = fmt.Sprintf("/tmp/test_repl_chain/%d", i)
+		cfg.BinLog.MaxFileNum = 10
+		cfg.BinLog.MaxFileSize = 1024
+
+		os.RemoveAll(cfg.DataDir)
+
+		var err error
+		if ay[i], err = Open(cfg); err != nil {
+			t.Fatal(err)
+		}
+		defer ay[i].Close()
+	}
+
+	master, slave, slave2 := ay[0], ay[1], ay[2]
+
+	db, _ := master.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.Set([]byte("b"), []byte("2"))
+
+	//full sync from master, binlog of slave continues after the dump
+	if err := master.DumpFile("/tmp/test_repl_chain/master.dump"); err != nil {
+		t.Fatal(err)
+	}
+
+	sdb, _ := slave.Select(0)
+	sdb.Set([]byte("stale"), []byte("1"))
+
+	head, err := slave.ReplicateFromDumpFile("/tmp/test_repl_chain/master.dump")
+	if err != nil {
+		t.Fatal(err)
+	} else if head.LogID != 2 || slave.binlog.LastLogID() != 2 || slave.binlog.FirstLogID() != 3 {
+		t.Fatal(head.LogID, slave.binlog.LastLogID(), slave.binlog.FirstLogID())
+	} else if v, _ := sdb.Get([]byte("stale")); v != nil {
+		t.Fatal("stale data must be removed")
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx.Set([]byte("c"), []byte("3"))
+	tx.HSet([]byte("h"), []byte("f"), []byte("v"))
+	if err = tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 50; i++ {
+		db.Set([]byte(fmt.Sprintf("chain_%d", i)), []byte("value"))
+	}
+
+	logID := syncLedis(t, master, slave, head.LogID)
+
+	//replicated batches keep their log ids
+	if logID != master.binlog.LastLogID() || slave.binlog.LastLogID() != logID {
+		t.Fatal(logID, master.binlog.LastLogID(), slave.binlog.LastLogID())
+	}
+
+	//replicating again is not logged twice
+	syncLedis(t, master, slave, head.LogID)
+	if slave.binlog.LastLogID() != logID {
+		t.Fatal(slave.binlog.LastLogID())
+	}
+
+	//the second tier slave syncs from slave
+	if err := slave.DumpFile("/tmp/test_repl_chain/slave.dump"); err != nil {
+		t.Fatal(err)
+	}
+
+	if head, err = slave2.ReplicateFromDumpFile("/tmp/test_repl_chain/slave.dump"); err != nil {
+		t.Fatal(err)
+	} else if head.LogID != logID {
+		t.Fatal(head.LogID, logID)
+	}
+
+	db.Set([]byte("d"), []byte("4"))
+	db.Del([]byte("a"))
+
+	logID = syncLedis(t, master, slave, logID)
+	if id := syncLedis(t, slave, slave2, head.LogID); id != logID || slave2.binlog.LastLogID() != logID {
+		t.Fatal(id, logID, slave2.binlog.LastLogID())
+	}
+
+	if err = checkLedisEqual(master, slave2); err != nil {
+		t.Fatal(err)
+	}
+}
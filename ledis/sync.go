@@ -0,0 +1,135 @@
+package ledis
+
+import (
+	"github.com/siddontang/go-log/log"
+	"github.com/siddontang/ledisdb/config"
+	"sync"
+	"time"
+)
+
+//groupSyncer makes written data durable in groups, writers waiting
+//at the same time share one sync.
+type groupSyncer struct {
+	sync.Mutex
+	cond *sync.Cond
+
+	//sequence of the last write and the last synced write
+	written uint64
+	synced  uint64
+
+	syncing bool
+
+	fn func() error
+}
+
+func newGroupSyncer(fn func() error) *groupSyncer {
+	s := new(groupSyncer)
+	s.cond = sync.NewCond(s)
+	s.fn = fn
+	return s
+}
+
+//add marks a write done, returns its sequence to wait
+func (s *groupSyncer) add() uint64 {
+	s.Lock()
+	s.written++
+	seq := s.written
+	s.Unlock()
+
+	return seq
+}
+
+//wait blocks until the write of seq is synced, if no sync is running,
+//the caller runs one for all writes done now, else waits it
+func (s *groupSyncer) wait(seq uint64) error {
+	s.Lock()
+	defer s.Unlock()
+
+	for s.synced < seq {
+		if s.syncing {
+			s.cond.Wait()
+			continue
+		}
+
+		s.syncing = true
+		target := s.written
+
+		s.Unlock()
+		err := s.fn()
+		s.Lock()
+
+		s.syncing = false
+		s.cond.Broadcast()
+
+		if err != nil {
+			return err
+		}
+
+		if target > s.synced {
+			s.synced = target
+		}
+	}
+
+	return nil
+}
+
+//syncAll syncs all writes done now
+func (s *groupSyncer) syncAll() error {
+	s.Lock()
+	seq := s.written
+	s.Unlock()
+
+	return s.wait(seq)
+}
+
+func (l *Ledis) syncData() error {
+	if err := l.ldb.Sync(); err != nil {
+		return err
+	}
+
+	if l.binlog != nil {
+		return l.binlog.Sync()
+	}
+
+	return nil
+}
+
+//written must be called after a write with lock held,
+//returns the sequence to wait sync
+func (l *Ledis) written() uint64 {
+	if l.syncer == nil {
+		return 0
+	}
+
+	return l.syncer.add()
+}
+
+//waitSync waits the write of seq durable if sync mode is always
+func (l *Ledis) waitSync(seq uint64) error {
+	if l.syncer == nil || l.cfg.SyncMode != config.SyncModeAlways {
+		return nil
+	}
+
+	return l.syncer.wait(seq)
+}
+
+func (l *Ledis) syncCycle() {
+	l.jobs.Add(1)
+	go func() {
+		tick := time.NewTicker(1 * time.Second)
+		end := false
+		for !end {
+			select {
+			case <-tick.C:
+				if err := l.syncer.syncAll(); err != nil {
+					log.Error("sync data error %s", err.Error())
+				}
+			case <-l.quit:
+				end = true
+			}
+		}
+
+		tick.Stop()
+		l.jobs.Done()
+	}()
+}
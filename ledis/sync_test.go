@@ -0,0 +1,98 @@
+package ledis
+
+import (
+	"errors"
+	"fmt"
+	"github.com/siddontang/ledisdb/config"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestGroupSyncer(t *testing.T) {
+	var n int32
+	s := newGroupSyncer(func() error {
+		atomic.AddInt32(&n, 1)
+		time.Sleep(10 * time.Millisecond)
+		return nil
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 100; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := s.wait(s.add()); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	if n == 0 || n >= 100 {
+		t.Fatal(n)
+	}
+
+	if s.synced != s.written {
+		t.Fatal(s.synced, s.written)
+	}
+
+	n = 0
+	if err := s.syncAll(); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal("nothing to sync")
+	}
+}
+
+func TestSyncModeAlways(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_sync_always"
+	cfg.BinLog.MaxFileSize = 1024 * 1024
+	cfg.BinLog.MaxFileNum = 10
+	cfg.SyncMode = "always"
+
+	os.RemoveAll(cfg.DataDir)
+
+	l, err := Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			key := []byte(fmt.Sprintf("sync_%d", i))
+			if err := db.Set(key, key); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+
+	wg.Wait()
+
+	if l.syncer.synced != l.syncer.written {
+		t.Fatal(l.syncer.synced, l.syncer.written)
+	}
+
+	//the writer gets the error if its data is not durable
+	errSync := errors.New("sync error")
+	l.syncer.fn = func() error { return errSync }
+	if err := db.Set([]byte("sync_fail"), []byte("1")); err != errSync {
+		t.Fatal(err)
+	}
+	l.syncer.fn = l.syncData
+
+	cfg.SyncMode = "unknown"
+	if _, err := Open(cfg); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -204,7 +204,7 @@ func (db *DB) bGetMeta(key []byte) (tailSeq int32, tailOff int32, err error) {
 	var v []byte
 
 	mk := db.bEncodeMetaKey(key)
-	v, err = db.db.Get(mk)
+	v, err = db.bucket.Get(mk)
 	if err != nil {
 		return
 	}
@@ -253,7 +253,7 @@ func (db *DB) bDelete(t *tx, key []byte) (drop int64) {
 
 	minKey := db.bEncodeBinKey(key, minSeq)
 	maxKey := db.bEncodeBinKey(key, maxSeq)
-	it := db.db.RangeIterator(minKey, maxKey, store.RangeClose)
+	it := db.bucket.RangeIterator(minKey, maxKey, store.RangeClose)
 	for ; it.Valid(); it.Next() {
 		t.Delete(it.RawKey())
 		drop++
@@ -265,7 +265,7 @@ func (db *DB) bDelete(t *tx, key []byte) (drop int64) {
 
 func (db *DB) bGetSegment(key []byte, seq uint32) ([]byte, []byte, error) {
 	bk := db.bEncodeBinKey(key, seq)
-	segment, err := db.db.Get(bk)
+	segment, err := db.bucket.Get(bk)
 	if err != nil {
 		return bk, nil, err
 	}
@@ -283,7 +283,7 @@ func (db *DB) bAllocateSegment(key []byte, seq uint32) ([]byte, []byte, error) {
 func (db *DB) bIterator(key []byte) *store.RangeLimitIterator {
 	sk := db.bEncodeBinKey(key, minSeq)
 	ek := db.bEncodeBinKey(key, maxSeq)
-	return db.db.RangeIterator(sk, ek, store.RangeClose)
+	return db.bucket.RangeIterator(sk, ek, store.RangeClose)
 }
 
 func (db *DB) bSegAnd(a []byte, b []byte, res *[]byte) {
@@ -446,7 +446,7 @@ func (db *DB) BGet(key []byte) (data []byte, err error) {
 
 	minKey := db.bEncodeBinKey(key, minSeq)
 	maxKey := db.bEncodeBinKey(key, tailSeq)
-	it := db.db.RangeIterator(minKey, maxKey, store.RangeClose)
+	it := db.bucket.RangeIterator(minKey, maxKey, store.RangeClose)
 
 	var seq, s, e uint32
 	for ; it.Valid(); it.Next() {
@@ -476,6 +476,10 @@ func (db *DB) BDelete(key []byte) (drop int64, err error) {
 	drop = db.bDelete(t, key)
 	db.rmExpire(t, BitType, key)
 
+	if drop > 0 {
+		t.notify(db, NotifyGeneric, "del", key)
+	}
+
 	err = t.Commit()
 	return
 }
@@ -508,6 +512,7 @@ func (db *DB) BSetBit(key []byte, offset int32, val uint8) (ori uint8, err error
 				return
 			}
 
+			t.notify(db, NotifyBit, "setbit", key)
 			err = t.Commit()
 			t.Unlock()
 		}
@@ -590,6 +595,7 @@ func (db *DB) BMSetBit(key []byte, args ...BitPair) (place int64, err error) {
 			return
 		}
 
+		t.notify(db, NotifyBit, "setbit", key)
 		err = t.Commit()
 	}
 
@@ -662,7 +668,7 @@ func (db *DB) BCount(key []byte, start int32, end int32) (cnt int32, err error)
 	skey := db.bEncodeBinKey(key, sseq)
 	ekey := db.bEncodeBinKey(key, eseq)
 
-	it := db.db.RangeIterator(skey, ekey, store.RangeOpen)
+	it := db.bucket.RangeIterator(skey, ekey, store.RangeOpen)
 	for ; it.Valid(); it.Next() {
 		segment = it.RawValue()
 		for _, bt := range segment {
@@ -844,6 +850,8 @@ func (db *DB) BOperation(op uint8, dstkey []byte, srckeys ...[]byte) (blen int32
 		}
 	}
 
+	t.notify(db, NotifyBit, "bitop", dstkey)
+
 	err = t.Commit()
 	if err == nil {
 		// blen = int32(db.bCapByteSize(maxDstOff, maxDstOff))
@@ -897,6 +905,8 @@ func (db *DB) BPersist(key []byte) (int64, error) {
 	n, err := db.rmExpire(t, BitType, key)
 	if err != nil {
 		return 0, err
+	} else if n > 0 {
+		t.notify(db, NotifyGeneric, "persist", key)
 	}
 
 	err = t.Commit()
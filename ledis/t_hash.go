@@ -112,7 +112,7 @@ func (db *DB) hSetItem(key []byte, field []byte, value []byte) (int64, error) {
 	ek := db.hEncodeHashKey(key, field)
 
 	var n int64 = 1
-	if v, _ := db.db.Get(ek); v != nil {
+	if v, _ := db.bucket.Get(ek); v != nil {
 		n = 0
 	} else {
 		if _, err := db.hIncrSize(key, 1); err != nil {
@@ -132,7 +132,7 @@ func (db *DB) hDelete(t *tx, key []byte) int64 {
 	stop := db.hEncodeStopKey(key)
 
 	var num int64 = 0
-	it := db.db.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
+	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	for ; it.Valid(); it.Next() {
 		t.Delete(it.Key())
 		num++
@@ -164,7 +164,7 @@ func (db *DB) HLen(key []byte) (int64, error) {
 		return 0, err
 	}
 
-	return Int64(db.db.Get(db.hEncodeSizeKey(key)))
+	return Int64(db.bucket.Get(db.hEncodeSizeKey(key)))
 }
 
 func (db *DB) HSet(key []byte, field []byte, value []byte) (int64, error) {
@@ -183,6 +183,8 @@ func (db *DB) HSet(key []byte, field []byte, value []byte) (int64, error) {
 		return 0, err
 	}
 
+	t.notify(db, NotifyHash, "hset", key)
+
 	//todo add binlog
 
 	err = t.Commit()
@@ -194,7 +196,7 @@ func (db *DB) HGet(key []byte, field []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	return db.db.Get(db.hEncodeHashKey(key, field))
+	return db.bucket.Get(db.hEncodeHashKey(key, field))
 }
 
 func (db *DB) HMset(key []byte, args ...FVPair) error {
@@ -214,7 +216,7 @@ func (db *DB) HMset(key []byte, args ...FVPair) error {
 
 		ek = db.hEncodeHashKey(key, args[i].Field)
 
-		if v, err := db.db.Get(ek); err != nil {
+		if v, err := db.bucket.Get(ek); err != nil {
 			return err
 		} else if v == nil {
 			num++
@@ -227,6 +229,8 @@ func (db *DB) HMset(key []byte, args ...FVPair) error {
 		return err
 	}
 
+	t.notify(db, NotifyHash, "hset", key)
+
 	//todo add binglog
 	err = t.Commit()
 	return err
@@ -235,7 +239,7 @@ func (db *DB) HMset(key []byte, args ...FVPair) error {
 func (db *DB) HMget(key []byte, args ...[]byte) ([][]byte, error) {
 	var ek []byte
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	r := make([][]byte, len(args))
@@ -262,7 +266,7 @@ func (db *DB) HDel(key []byte, args ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	var num int64 = 0
@@ -286,6 +290,10 @@ func (db *DB) HDel(key []byte, args ...[]byte) (int64, error) {
 		return 0, err
 	}
 
+	if num > 0 {
+		t.notify(db, NotifyHash, "hdel", key)
+	}
+
 	err = t.Commit()
 
 	return num, err
@@ -297,7 +305,7 @@ func (db *DB) hIncrSize(key []byte, delta int64) (int64, error) {
 
 	var err error
 	var size int64 = 0
-	if size, err = Int64(db.db.Get(sk)); err != nil {
+	if size, err = Int64(db.bucket.Get(sk)); err != nil {
 		return 0, err
 	} else {
 		size += delta
@@ -328,7 +336,7 @@ func (db *DB) HIncrBy(key []byte, field []byte, delta int64) (int64, error) {
 	ek = db.hEncodeHashKey(key, field)
 
 	var n int64 = 0
-	if n, err = StrInt64(db.db.Get(ek)); err != nil {
+	if n, err = StrInt64(db.bucket.Get(ek)); err != nil {
 		return 0, err
 	}
 
@@ -339,6 +347,8 @@ func (db *DB) HIncrBy(key []byte, field []byte, delta int64) (int64, error) {
 		return 0, err
 	}
 
+	t.notify(db, NotifyHash, "hincrby", key)
+
 	err = t.Commit()
 
 	return n, err
@@ -354,7 +364,7 @@ func (db *DB) HGetAll(key []byte) ([]FVPair, error) {
 
 	v := make([]FVPair, 0, 16)
 
-	it := db.db.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
+	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	for ; it.Valid(); it.Next() {
 		_, f, err := db.hDecodeHashKey(it.Key())
 		if err != nil {
@@ -379,7 +389,7 @@ func (db *DB) HKeys(key []byte) ([][]byte, error) {
 
 	v := make([][]byte, 0, 16)
 
-	it := db.db.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
+	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	for ; it.Valid(); it.Next() {
 		_, f, err := db.hDecodeHashKey(it.Key())
 		if err != nil {
@@ -403,7 +413,7 @@ func (db *DB) HValues(key []byte) ([][]byte, error) {
 
 	v := make([][]byte, 0, 16)
 
-	it := db.db.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
+	it := db.bucket.RangeLimitIterator(start, stop, store.RangeROpen, 0, -1)
 	for ; it.Valid(); it.Next() {
 		_, _, err := db.hDecodeHashKey(it.Key())
 		if err != nil {
@@ -430,6 +440,10 @@ func (db *DB) HClear(key []byte) (int64, error) {
 	num := db.hDelete(t, key)
 	db.rmExpire(t, HashType, key)
 
+	if num > 0 {
+		t.notify(db, NotifyGeneric, "del", key)
+	}
+
 	err := t.Commit()
 	return num, err
 }
@@ -444,7 +458,9 @@ func (db *DB) HMclear(keys ...[]byte) (int64, error) {
 			return 0, err
 		}
 
-		db.hDelete(t, key)
+		if db.hDelete(t, key) > 0 {
+			t.notify(db, NotifyGeneric, "del", key)
+		}
 		db.rmExpire(t, HashType, key)
 	}
 
@@ -496,7 +512,7 @@ func (db *DB) HScan(key []byte, field []byte, count int, inclusive bool) ([]FVPa
 		rangeType = store.RangeOpen
 	}
 
-	it := db.db.RangeLimitIterator(minKey, maxKey, rangeType, 0, count)
+	it := db.bucket.RangeLimitIterator(minKey, maxKey, rangeType, 0, count)
 	for ; it.Valid(); it.Next() {
 		if _, f, err := db.hDecodeHashKey(it.Key()); err != nil {
 			continue
@@ -545,6 +561,8 @@ func (db *DB) HPersist(key []byte) (int64, error) {
 	n, err := db.rmExpire(t, HashType, key)
 	if err != nil {
 		return 0, err
+	} else if n > 0 {
+		t.notify(db, NotifyGeneric, "persist", key)
 	}
 
 	err = t.Commit()
@@ -55,13 +55,13 @@ func (db *DB) encodeKVMaxKey() []byte {
 	return ek
 }
 
-func (db *DB) incr(key []byte, delta int64) (int64, error) {
+func (db *DB) incr(key []byte, delta int64, event string) (int64, error) {
 	if err := checkKeySize(key); err != nil {
 		return 0, err
 	}
 
 	var err error
-	key = db.encodeKVKey(key)
+	ek := db.encodeKVKey(key)
 
 	t := db.kvTx
 
@@ -69,14 +69,15 @@ func (db *DB) incr(key []byte, delta int64) (int64, error) {
 	defer t.Unlock()
 
 	var n int64
-	n, err = StrInt64(db.db.Get(key))
+	n, err = StrInt64(db.bucket.Get(ek))
 	if err != nil {
 		return 0, err
 	}
 
 	n += delta
 
-	t.Put(key, StrPutInt64(n))
+	t.Put(ek, StrPutInt64(n))
+	t.notify(db, NotifyKV, event, key)
 
 	//todo binlog
 
@@ -109,11 +110,11 @@ func (db *DB) setExpireAt(key []byte, when int64) (int64, error) {
 }
 
 func (db *DB) Decr(key []byte) (int64, error) {
-	return db.incr(key, -1)
+	return db.incr(key, -1, "decrby")
 }
 
 func (db *DB) DecrBy(key []byte, decrement int64) (int64, error) {
-	return db.incr(key, -decrement)
+	return db.incr(key, -decrement, "decrby")
 }
 
 func (db *DB) Del(keys ...[]byte) (int64, error) {
@@ -130,7 +131,16 @@ func (db *DB) Del(keys ...[]byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
+	//deletes are not seen before commit, a repeated key is notified once
+	notified := make(map[string]struct{}, len(keys))
 	for i, k := range keys {
+		if v, err := db.bucket.Get(codedKeys[i]); err != nil {
+			return 0, err
+		} else if _, ok := notified[string(k)]; v != nil && !ok {
+			notified[string(k)] = struct{}{}
+			t.notify(db, NotifyGeneric, "del", k)
+		}
+
 		t.Delete(codedKeys[i])
 		db.rmExpire(t, KVType, k)
 	}
@@ -148,7 +158,7 @@ func (db *DB) Exists(key []byte) (int64, error) {
 	key = db.encodeKVKey(key)
 
 	var v []byte
-	v, err = db.db.Get(key)
+	v, err = db.bucket.Get(key)
 	if v != nil && err == nil {
 		return 1, nil
 	}
@@ -163,7 +173,7 @@ func (db *DB) Get(key []byte) ([]byte, error) {
 
 	key = db.encodeKVKey(key)
 
-	return db.db.Get(key)
+	return db.bucket.Get(key)
 }
 
 func (db *DB) GetSet(key []byte, value []byte) ([]byte, error) {
@@ -173,19 +183,20 @@ func (db *DB) GetSet(key []byte, value []byte) ([]byte, error) {
 		return nil, err
 	}
 
-	key = db.encodeKVKey(key)
+	ek := db.encodeKVKey(key)
 
 	t := db.kvTx
 
 	t.Lock()
 	defer t.Unlock()
 
-	oldValue, err := db.db.Get(key)
+	oldValue, err := db.bucket.Get(ek)
 	if err != nil {
 		return nil, err
 	}
 
-	t.Put(key, value)
+	t.Put(ek, value)
+	t.notify(db, NotifyKV, "set", key)
 	//todo, binlog
 
 	err = t.Commit()
@@ -194,17 +205,17 @@ func (db *DB) GetSet(key []byte, value []byte) ([]byte, error) {
 }
 
 func (db *DB) Incr(key []byte) (int64, error) {
-	return db.incr(key, 1)
+	return db.incr(key, 1, "incrby")
 }
 
 func (db *DB) IncrBy(key []byte, increment int64) (int64, error) {
-	return db.incr(key, increment)
+	return db.incr(key, increment, "incrby")
 }
 
 func (db *DB) MGet(keys ...[]byte) ([][]byte, error) {
 	values := make([][]byte, len(keys))
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	for i := range keys {
@@ -244,6 +255,7 @@ func (db *DB) MSet(args ...KVPair) error {
 		value = args[i].Value
 
 		t.Put(key, value)
+		t.notify(db, NotifyKV, "set", args[i].Key)
 
 		//todo binlog
 	}
@@ -260,14 +272,15 @@ func (db *DB) Set(key []byte, value []byte) error {
 	}
 
 	var err error
-	key = db.encodeKVKey(key)
+	ek := db.encodeKVKey(key)
 
 	t := db.kvTx
 
 	t.Lock()
 	defer t.Unlock()
 
-	t.Put(key, value)
+	t.Put(ek, value)
+	t.notify(db, NotifyKV, "set", key)
 
 	//todo, binlog
 
@@ -284,7 +297,7 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 	}
 
 	var err error
-	key = db.encodeKVKey(key)
+	ek := db.encodeKVKey(key)
 
 	var n int64 = 1
 
@@ -293,12 +306,13 @@ func (db *DB) SetNX(key []byte, value []byte) (int64, error) {
 	t.Lock()
 	defer t.Unlock()
 
-	if v, err := db.db.Get(key); err != nil {
+	if v, err := db.bucket.Get(ek); err != nil {
 		return 0, err
 	} else if v != nil {
 		n = 0
 	} else {
-		t.Put(key, value)
+		t.Put(ek, value)
+		t.notify(db, NotifyKV, "set", key)
 
 		//todo binlog
 
@@ -348,7 +362,7 @@ func (db *DB) Scan(key []byte, count int, inclusive bool) ([]KVPair, error) {
 		rangeType = store.RangeOpen
 	}
 
-	it := db.db.RangeLimitIterator(minKey, maxKey, rangeType, 0, count)
+	it := db.bucket.RangeLimitIterator(minKey, maxKey, rangeType, 0, count)
 	for ; it.Valid(); it.Next() {
 		if key, err := db.decodeKVKey(it.Key()); err != nil {
 			continue
@@ -396,6 +410,8 @@ func (db *DB) Persist(key []byte) (int64, error) {
 	n, err := db.rmExpire(t, KVType, key)
 	if err != nil {
 		return 0, err
+	} else if n > 0 {
+		t.notify(db, NotifyGeneric, "persist", key)
 	}
 
 	err = t.Commit()
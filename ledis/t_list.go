@@ -130,6 +130,12 @@ func (db *DB) lpush(key []byte, whereSeq int32, args ...[]byte) (int64, error) {
 
 	db.lSetMeta(metaKey, headSeq, tailSeq)
 
+	if whereSeq == listHeadSeq {
+		t.notify(db, NotifyList, "lpush", key)
+	} else {
+		t.notify(db, NotifyList, "rpush", key)
+	}
+
 	err = t.Commit()
 	return int64(size) + int64(pushCnt), err
 }
@@ -161,7 +167,7 @@ func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
 	}
 
 	itemKey := db.lEncodeListKey(key, seq)
-	value, err = db.db.Get(itemKey)
+	value, err = db.bucket.Get(itemKey)
 	if err != nil {
 		return nil, err
 	}
@@ -178,6 +184,14 @@ func (db *DB) lpop(key []byte, whereSeq int32) ([]byte, error) {
 		db.rmExpire(t, HashType, key)
 	}
 
+	if value != nil {
+		if whereSeq == listHeadSeq {
+			t.notify(db, NotifyList, "lpop", key)
+		} else {
+			t.notify(db, NotifyList, "rpop", key)
+		}
+	}
+
 	err = t.Commit()
 	return value, err
 }
@@ -191,7 +205,7 @@ func (db *DB) lDelete(t *tx, key []byte) int64 {
 	var tailSeq int32
 	var err error
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	headSeq, tailSeq, _, err = db.lGetMeta(it, mk)
@@ -219,7 +233,7 @@ func (db *DB) lGetMeta(it *store.Iterator, ek []byte) (headSeq int32, tailSeq in
 	if it != nil {
 		v = it.Find(ek)
 	} else {
-		v, err = db.db.Get(ek)
+		v, err = db.bucket.Get(ek)
 	}
 	if err != nil {
 		return
@@ -284,7 +298,7 @@ func (db *DB) LIndex(key []byte, index int32) ([]byte, error) {
 
 	metaKey := db.lEncodeMetaKey(key)
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	headSeq, tailSeq, _, err = db.lGetMeta(it, metaKey)
@@ -331,7 +345,7 @@ func (db *DB) LSet(key []byte, index int32, value []byte) error {
 	var tailSeq int32
 	//var size int32
 	var err error
-	t := db.listBatch
+	t := db.listTx
 	t.Lock()
 	defer t.Unlock()
 	metaKey := db.lEncodeMetaKey(key)
@@ -351,6 +365,7 @@ func (db *DB) LSet(key []byte, index int32, value []byte) error {
 	}
 	sk := db.lEncodeListKey(key, seq)
 	t.Put(sk, value)
+	t.notify(db, NotifyList, "lset", key)
 	err = t.Commit()
 	return err
 }
@@ -366,7 +381,7 @@ func (db *DB) LRange(key []byte, start int32, stop int32) ([][]byte, error) {
 
 	metaKey := db.lEncodeMetaKey(key)
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	if headSeq, _, llen, err = db.lGetMeta(it, metaKey); err != nil {
@@ -433,6 +448,10 @@ func (db *DB) LClear(key []byte) (int64, error) {
 	num := db.lDelete(t, key)
 	db.rmExpire(t, ListType, key)
 
+	if num > 0 {
+		t.notify(db, NotifyGeneric, "del", key)
+	}
+
 	err := t.Commit()
 	return num, err
 }
@@ -447,9 +466,10 @@ func (db *DB) LMclear(keys ...[]byte) (int64, error) {
 			return 0, err
 		}
 
-		db.lDelete(t, key)
+		if db.lDelete(t, key) > 0 {
+			t.notify(db, NotifyGeneric, "del", key)
+		}
 		db.rmExpire(t, ListType, key)
-
 	}
 
 	err := t.Commit()
@@ -512,6 +532,8 @@ func (db *DB) LPersist(key []byte) (int64, error) {
 	n, err := db.rmExpire(t, ListType, key)
 	if err != nil {
 		return 0, err
+	} else if n > 0 {
+		t.notify(db, NotifyGeneric, "persist", key)
 	}
 
 	err = t.Commit()
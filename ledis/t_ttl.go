@@ -3,6 +3,7 @@ package ledis
 import (
 	"encoding/binary"
 	"errors"
+	"github.com/siddontang/go-log/log"
 	"github.com/siddontang/ledisdb/store"
 	"time"
 )
@@ -77,12 +78,14 @@ func (db *DB) expireAt(t *tx, dataType byte, key []byte, when int64) {
 
 	t.Put(tk, mk)
 	t.Put(mk, PutInt64(when))
+
+	t.notify(db, NotifyGeneric, "expire", key)
 }
 
 func (db *DB) ttl(dataType byte, key []byte) (t int64, err error) {
 	mk := db.expEncodeMetaKey(dataType, key)
 
-	if t, err = Int64(db.db.Get(mk)); err != nil || t == 0 {
+	if t, err = Int64(db.bucket.Get(mk)); err != nil || t == 0 {
 		t = -1
 	} else {
 		t -= time.Now().Unix()
@@ -97,7 +100,7 @@ func (db *DB) ttl(dataType byte, key []byte) (t int64, err error) {
 
 func (db *DB) rmExpire(t *tx, dataType byte, key []byte) (int64, error) {
 	mk := db.expEncodeMetaKey(dataType, key)
-	if v, err := db.db.Get(mk); err != nil {
+	if v, err := db.bucket.Get(mk); err != nil {
 		return 0, err
 	} else if v == nil {
 		return 0, nil
@@ -148,7 +151,8 @@ func (eli *elimination) regRetireContext(dataType byte, t *tx, onRetire retireCa
 }
 
 //	call by outside ... (from *db to another *db)
-func (eli *elimination) active() {
+//	returns the number of expired keys
+func (eli *elimination) active() (expired int64) {
 	now := time.Now().Unix()
 	db := eli.db
 	dbGet := db.db.Get
@@ -178,10 +182,14 @@ func (eli *elimination) active() {
 			// check expire again
 			if exp <= now {
 				onRetire(t, k)
+				expired++
 				t.Delete(tk)
 				t.Delete(mk)
+				t.notify(db, NotifyExpired, "expired", k)
 
-				t.Commit()
+				if err := t.Commit(); err != nil {
+					log.Error("expire %q error %s", k, err.Error())
+				}
 			}
 
 		}
@@ -200,7 +200,7 @@ func (db *DB) zSetItem(t *tx, key []byte, score int64, member []byte) (int64, er
 	var exists int64 = 0
 	ek := db.zEncodeSetKey(key, member)
 
-	if v, err := db.db.Get(ek); err != nil {
+	if v, err := db.bucket.Get(ek); err != nil {
 		return 0, err
 	} else if v != nil {
 		exists = 1
@@ -223,7 +223,7 @@ func (db *DB) zSetItem(t *tx, key []byte, score int64, member []byte) (int64, er
 
 func (db *DB) zDelItem(t *tx, key []byte, member []byte, skipDelScore bool) (int64, error) {
 	ek := db.zEncodeSetKey(key, member)
-	if v, err := db.db.Get(ek); err != nil {
+	if v, err := db.bucket.Get(ek); err != nil {
 		return 0, err
 	} else if v == nil {
 		//not exists
@@ -298,6 +298,8 @@ func (db *DB) ZAdd(key []byte, args ...ScorePair) (int64, error) {
 		return 0, err
 	}
 
+	t.notify(db, NotifyZSet, "zadd", key)
+
 	//todo add binlog
 	err := t.Commit()
 	return num, err
@@ -306,7 +308,7 @@ func (db *DB) ZAdd(key []byte, args ...ScorePair) (int64, error) {
 func (db *DB) zIncrSize(t *tx, key []byte, delta int64) (int64, error) {
 	sk := db.zEncodeSizeKey(key)
 
-	size, err := Int64(db.db.Get(sk))
+	size, err := Int64(db.bucket.Get(sk))
 	if err != nil {
 		return 0, err
 	} else {
@@ -329,7 +331,7 @@ func (db *DB) ZCard(key []byte) (int64, error) {
 	}
 
 	sk := db.zEncodeSizeKey(key)
-	return Int64(db.db.Get(sk))
+	return Int64(db.bucket.Get(sk))
 }
 
 func (db *DB) ZScore(key []byte, member []byte) (int64, error) {
@@ -340,7 +342,7 @@ func (db *DB) ZScore(key []byte, member []byte) (int64, error) {
 	var score int64 = InvalidScore
 
 	k := db.zEncodeSetKey(key, member)
-	if v, err := db.db.Get(k); err != nil {
+	if v, err := db.bucket.Get(k); err != nil {
 		return InvalidScore, err
 	} else if v == nil {
 		return InvalidScore, ErrScoreMiss
@@ -379,6 +381,10 @@ func (db *DB) ZRem(key []byte, members ...[]byte) (int64, error) {
 		return 0, err
 	}
 
+	if num > 0 {
+		t.notify(db, NotifyZSet, "zrem", key)
+	}
+
 	err := t.Commit()
 	return num, err
 }
@@ -395,7 +401,7 @@ func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 	ek := db.zEncodeSetKey(key, member)
 
 	var oldScore int64 = 0
-	v, err := db.db.Get(ek)
+	v, err := db.bucket.Get(ek)
 	if err != nil {
 		return InvalidScore, err
 	} else if v == nil {
@@ -421,6 +427,8 @@ func (db *DB) ZIncrBy(key []byte, delta int64, member []byte) (int64, error) {
 		t.Delete(oldSk)
 	}
 
+	t.notify(db, NotifyZSet, "zincr", key)
+
 	err = t.Commit()
 	return newScore, err
 }
@@ -434,7 +442,7 @@ func (db *DB) ZCount(key []byte, min int64, max int64) (int64, error) {
 
 	rangeType := store.RangeROpen
 
-	it := db.db.RangeLimitIterator(minKey, maxKey, rangeType, 0, -1)
+	it := db.bucket.RangeLimitIterator(minKey, maxKey, rangeType, 0, -1)
 	var n int64 = 0
 	for ; it.Valid(); it.Next() {
 		n++
@@ -451,7 +459,7 @@ func (db *DB) zrank(key []byte, member []byte, reverse bool) (int64, error) {
 
 	k := db.zEncodeSetKey(key, member)
 
-	it := db.db.NewIterator()
+	it := db.bucket.NewIterator()
 	defer it.Close()
 
 	if v := it.Find(k); v == nil {
@@ -497,9 +505,9 @@ func (db *DB) zIterator(key []byte, min int64, max int64, offset int, count int,
 	maxKey := db.zEncodeStopScoreKey(key, max)
 
 	if !reverse {
-		return db.db.RangeLimitIterator(minKey, maxKey, store.RangeClose, offset, count)
+		return db.bucket.RangeLimitIterator(minKey, maxKey, store.RangeClose, offset, count)
 	} else {
-		return db.db.RevRangeLimitIterator(minKey, maxKey, store.RangeClose, offset, count)
+		return db.bucket.RevRangeLimitIterator(minKey, maxKey, store.RangeClose, offset, count)
 	}
 }
 
@@ -625,6 +633,9 @@ func (db *DB) ZClear(key []byte) (int64, error) {
 
 	rmCnt, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1)
 	if err == nil {
+		if rmCnt > 0 {
+			t.notify(db, NotifyGeneric, "del", key)
+		}
 		err = t.Commit()
 	}
 
@@ -637,8 +648,10 @@ func (db *DB) ZMclear(keys ...[]byte) (int64, error) {
 	defer t.Unlock()
 
 	for _, key := range keys {
-		if _, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1); err != nil {
+		if n, err := db.zRemRange(t, key, MinScore, MaxScore, 0, -1); err != nil {
 			return 0, err
+		} else if n > 0 {
+			t.notify(db, NotifyGeneric, "del", key)
 		}
 	}
 
@@ -676,6 +689,9 @@ func (db *DB) ZRemRangeByRank(key []byte, start int, stop int) (int64, error) {
 
 	rmCnt, err = db.zRemRange(t, key, MinScore, MaxScore, offset, count)
 	if err == nil {
+		if rmCnt > 0 {
+			t.notify(db, NotifyZSet, "zremrangebyrank", key)
+		}
 		err = t.Commit()
 	}
 
@@ -690,6 +706,9 @@ func (db *DB) ZRemRangeByScore(key []byte, min int64, max int64) (int64, error)
 
 	rmCnt, err := db.zRemRange(t, key, min, max, 0, -1)
 	if err == nil {
+		if rmCnt > 0 {
+			t.notify(db, NotifyZSet, "zremrangebyscore", key)
+		}
 		err = t.Commit()
 	}
 
@@ -740,7 +759,7 @@ func (db *DB) zFlush() (drop int64, err error) {
 	maxKey[0] = db.index
 	maxKey[1] = ZScoreType + 1
 
-	it := db.db.RangeLimitIterator(minKey, maxKey, store.RangeROpen, 0, -1)
+	it := db.bucket.RangeLimitIterator(minKey, maxKey, store.RangeROpen, 0, -1)
 	defer it.Close()
 
 	for ; it.Valid(); it.Next() {
@@ -784,7 +803,7 @@ func (db *DB) ZScan(key []byte, member []byte, count int, inclusive bool) ([]Sco
 		rangeType = store.RangeOpen
 	}
 
-	it := db.db.RangeLimitIterator(minKey, maxKey, rangeType, 0, count)
+	it := db.bucket.RangeLimitIterator(minKey, maxKey, rangeType, 0, count)
 	for ; it.Valid(); it.Next() {
 		if _, m, err := db.zDecodeSetKey(it.Key()); err != nil {
 			continue
@@ -834,6 +853,8 @@ func (db *DB) ZPersist(key []byte) (int64, error) {
 	n, err := db.rmExpire(t, ZSetType, key)
 	if err != nil {
 		return 0, err
+	} else if n > 0 {
+		t.notify(db, NotifyGeneric, "persist", key)
 	}
 
 	err = t.Commit()
@@ -13,6 +13,12 @@ type tx struct {
 
 	binlog *BinLog
 	batch  [][]byte
+
+	//not nil if in a transaction, commits to it
+	ltx *Tx
+
+	//key events to notify after commit
+	events []*KeyEvent
 }
 
 func newTx(l *Ledis) *tx {
@@ -26,6 +32,18 @@ func newTx(l *Ledis) *tx {
 	return t
 }
 
+//lockWrite takes the global lock to write store, after a store transaction of Tx
+//ends, which takes the global lock when it commits, so the lock order is the same
+func (l *Ledis) lockWrite() {
+	l.storeTxLock.RLock()
+	l.Lock()
+}
+
+func (l *Ledis) unlockWrite() {
+	l.Unlock()
+	l.storeTxLock.RUnlock()
+}
+
 func (t *tx) Close() {
 	t.wb = nil
 }
@@ -54,29 +72,38 @@ func (t *tx) Lock() {
 
 func (t *tx) Unlock() {
 	t.batch = t.batch[0:0]
+	t.events = nil
 	t.wb.Rollback()
 	t.m.Unlock()
 }
 
 func (t *tx) Commit() error {
+	if t.ltx != nil {
+		return t.ltx.commitBatch(t)
+	}
+
 	var err error
-	if t.binlog != nil {
-		t.l.Lock()
-		err = t.wb.Commit()
-		if err != nil {
-			t.l.Unlock()
-			return err
-		}
 
+	t.l.lockWrite()
+	err = t.wb.Commit()
+	if err == nil && t.binlog != nil {
 		err = t.binlog.Log(t.batch...)
+	}
+	seq := t.l.written()
+	t.l.unlockWrite()
 
-		t.l.Unlock()
-	} else {
-		t.l.Lock()
-		err = t.wb.Commit()
-		t.l.Unlock()
+	if err != nil {
+		return err
 	}
-	return err
+
+	if len(t.events) > 0 {
+		t.l.notify(t.events)
+		t.events = nil
+	}
+
+	//wait outside the global lock, so writers of other txs can join the same sync,
+	//the caller gets the error if the data is not durable
+	return t.l.waitSync(seq)
 }
 
 func (t *tx) Rollback() {
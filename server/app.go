@@ -1,30 +1,69 @@
 package server
 
 import (
+	"errors"
+	"github.com/siddontang/go-log/log"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/ledis"
 	"net"
 	"net/http"
 	"path"
 	"strings"
+	"sync"
+	"time"
 )
 
+var errShutdown = errors.New("server is shutting down")
+
 type App struct {
 	cfg *config.Config
 
 	listener     net.Listener
 	httpListener net.Listener
+	httpServer   *http.Server
 
 	ldb *ledis.Ledis
 
-	closed bool
+	closeOnce sync.Once
 
 	quit chan struct{}
 
-	access *accessLog
+	//closed after all closed, Run waits for it
+	done chan struct{}
+
+	//protects closing, so no request begins after waiting reqs
+	reqLock sync.RWMutex
+	closing bool
+	reqs    sync.WaitGroup
+
+	//protects the settings changed at runtime
+	cfgLock sync.Mutex
+
+	accessLock sync.RWMutex
+	access     *accessLog
+
+	slowlog *slowLog
+
+	clients *clientList
+
+	pubsub *pubsub
+
+	//compiled lua scripts
+	scripts *scriptCache
 
 	//for slave replication
 	m *master
+
+	//1 if write commands are rejected
+	readonly int32
+
+	slock sync.Mutex
+	//connected slaves, keyed by remote address
+	slaves map[string]*slaveInfo
+
+	backup backupState
+
+	stat *metrics
 }
 
 func netType(s string) string {
@@ -44,10 +83,22 @@ func NewApp(cfg *config.Config) (*App, error) {
 	app := new(App)
 
 	app.quit = make(chan struct{})
+	app.done = make(chan struct{})
 
-	app.closed = false
+	app.slaves = make(map[string]*slaveInfo)
 
 	app.cfg = cfg
+	app.updateReadOnly()
+
+	app.stat = newMetrics()
+
+	app.slowlog = newSlowLog(cfg)
+
+	app.clients = newClientList(cfg)
+
+	app.pubsub = newPubSub()
+
+	app.scripts = newScriptCache(cfg)
 
 	var err error
 
@@ -59,16 +110,11 @@ func NewApp(cfg *config.Config) (*App, error) {
 		if app.httpListener, err = net.Listen(netType(cfg.HttpAddr), cfg.HttpAddr); err != nil {
 			return nil, err
 		}
+		app.httpServer = new(http.Server)
 	}
 
 	if len(cfg.AccessLog) > 0 {
-		if path.Dir(cfg.AccessLog) == "." {
-			app.access, err = newAcessLog(path.Join(cfg.DataDir, cfg.AccessLog))
-		} else {
-			app.access, err = newAcessLog(cfg.AccessLog)
-		}
-
-		if err != nil {
+		if app.access, err = app.openAccessLog(cfg.AccessLog); err != nil {
 			return nil, err
 		}
 	}
@@ -77,35 +123,139 @@ func NewApp(cfg *config.Config) (*App, error) {
 		return nil, err
 	}
 
+	if err = app.checkWritableSlave(cfg.SlaveOf, cfg.SlaveReadOnly); err != nil {
+		app.ldb.Close()
+		return nil, err
+	}
+
+	if err = app.setNotifyFlags(cfg.NotifyKeyspaceEvents); err != nil {
+		app.ldb.Close()
+		return nil, err
+	}
+	app.ldb.SetNotifier(app.pubsub.publishKeyEvent)
+
 	app.m = newMaster(app)
 
 	return app, nil
 }
 
-func (app *App) Close() {
-	if app.closed {
-		return
+//openAccessLog opens the access log, relative to data dir if name has no dir
+func (app *App) openAccessLog(name string) (*accessLog, error) {
+	if path.Dir(name) == "." {
+		return newAcessLog(path.Join(app.cfg.DataDir, name))
 	}
+	return newAcessLog(name)
+}
 
-	app.closed = true
+//beginRequest fails if app is closing, endRequest must be called if not
+func (app *App) beginRequest() bool {
+	app.reqLock.RLock()
+	defer app.reqLock.RUnlock()
 
-	close(app.quit)
+	if app.closing {
+		return false
+	}
 
-	app.listener.Close()
+	app.reqs.Add(1)
+	return true
+}
+
+func (app *App) endRequest() {
+	app.reqs.Done()
+}
 
-	if app.httpListener != nil {
-		app.httpListener.Close()
+//waitRequests waits running requests to finish, at most timeout
+func (app *App) waitRequests(timeout time.Duration) bool {
+	ch := make(chan struct{})
+	go func() {
+		app.reqs.Wait()
+		close(ch)
+	}()
+
+	select {
+	case <-ch:
+		return true
+	case <-time.After(timeout):
+		return false
 	}
+}
 
-	app.m.Close()
+//Close shuts down the app gracefully without saving
+func (app *App) Close() {
+	app.shutdown(false)
+}
 
-	if app.access != nil {
-		app.access.Close()
-	}
+//shutdown rejects new requests, waits running ones, stops replication
+//and saves a backup to the backup dir in data dir if save, then closes the store
+func (app *App) shutdown(save bool) {
+	app.closeOnce.Do(func() {
+		app.reqLock.Lock()
+		app.closing = true
+		app.reqLock.Unlock()
+
+		close(app.quit)
+
+		app.listener.Close()
+
+		if app.httpListener != nil {
+			app.httpListener.Close()
+		}
+
+		//replication writes to store too
+		app.m.Lock()
+		app.m.Close()
+		if len(app.m.info.Addr) > 0 {
+			if err := app.m.saveInfo(); err != nil {
+				log.Error("save master info error %s", err.Error())
+			}
+		}
+		app.m.Unlock()
+
+		//a running script holds the write locks, its writes are discarded
+		app.scripts.kill()
+
+		app.cfgLock.Lock()
+		timeout := time.Duration(app.cfg.ShutdownTimeout) * time.Second
+		app.cfgLock.Unlock()
 
-	app.ldb.Close()
+		if timeout <= 0 {
+			timeout = time.Duration(config.DefaultShutdownTimeout) * time.Second
+		}
+
+		if !app.waitRequests(timeout) {
+			log.Warn("requests still running after waiting %s, close client connections", timeout)
+
+			//unblocks requests writing to slow clients, the store is closed after all return
+			app.clients.closeAll()
+			if app.httpServer != nil {
+				app.httpServer.Close()
+			}
+			app.reqs.Wait()
+		}
+
+		if save {
+			if _, err := app.ldb.Backup(path.Join(app.cfg.DataDir, "backup"), new(int64)); err != nil {
+				log.Error("save backup error %s", err.Error())
+			}
+		}
+
+		app.clients.closeAll()
+
+		app.accessLock.Lock()
+		if app.access != nil {
+			app.access.Close()
+			app.access = nil
+		}
+		app.accessLock.Unlock()
+
+		//flushes and fsyncs binlog
+		app.ldb.Close()
+
+		close(app.done)
+	})
 }
 
+//Run serves until the app is closed, returns after Close finishes
 func (app *App) Run() {
 	if len(app.cfg.SlaveOf) > 0 {
 		app.slaveof(app.cfg.SlaveOf)
@@ -113,10 +263,16 @@ func (app *App) Run() {
 
 	go app.httpServe()
 
-	for !app.closed {
+	for {
 		conn, err := app.listener.Accept()
 		if err != nil {
-			continue
+			select {
+			case <-app.quit:
+				<-app.done
+				return
+			default:
+				continue
+			}
 		}
 
 		newClientRESP(conn, app)
@@ -130,12 +286,17 @@ func (app *App) httpServe() {
 
 	mux := http.NewServeMux()
 
+	mux.HandleFunc("/metrics", app.metricsHandler)
+
+	mux.HandleFunc("/subscribe/", app.subscribeHandler)
+	mux.HandleFunc("/psubscribe/", app.subscribeHandler)
+
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		newClientHTTP(app, w, r)
 	})
 
-	svr := http.Server{Handler: mux}
-	svr.Serve(app.httpListener)
+	app.httpServer.Handler = mux
+	app.httpServer.Serve(app.httpListener)
 }
 
 func (app *App) Ledis() *ledis.Ledis {
@@ -3,9 +3,13 @@ package server
 import (
 	"github.com/siddontang/ledisdb/client/go/ledis"
 	"github.com/siddontang/ledisdb/config"
+	ledisdb "github.com/siddontang/ledisdb/ledis"
+	"net"
 	"os"
+	"path"
 	"sync"
 	"testing"
+	"time"
 )
 
 var testAppOnce sync.Once
@@ -25,6 +29,16 @@ func getTestConn() *ledis.Conn {
 	return testLedisClient.Get()
 }
 
+//newTestConn returns a connection not pooled, for tests changing connection state
+func newTestConn() *ledis.Conn {
+	startTestApp()
+
+	cfg := new(ledis.Config)
+	cfg.Addr = "127.0.0.1:16380"
+	cfg.MaxIdleConns = 0
+	return ledis.NewClient(cfg).Get()
+}
+
 func startTestApp() {
 	f := func() {
 		newTestLedisClient()
@@ -53,3 +67,138 @@ func startTestApp() {
 func TestApp(t *testing.T) {
 	startTestApp()
 }
+
+func TestShutdown(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_shutdown"
+	os.RemoveAll(cfg.DataDir)
+
+	cfg.Addr = "127.0.0.1:16391"
+	cfg.BinLog.MaxFileSize = 1024 * 1024
+	cfg.BinLog.MaxFileNum = 1
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		app.Run()
+		close(done)
+	}()
+
+	clientCfg := new(ledis.Config)
+	clientCfg.Addr = cfg.Addr
+	clientCfg.MaxIdleConns = 0
+	client := ledis.NewClient(clientCfg)
+
+	c1 := client.Get()
+	defer c1.Close()
+
+	c2 := client.Get()
+	defer c2.Close()
+
+	if _, err := c1.Do("set", "a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c2.Do("ping"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c1.Do("shutdown", "abc"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if ok, err := ledis.String(c1.Do("shutdown", "save")); err != nil {
+		t.Fatal(err)
+	} else if ok != OK {
+		t.Fatal(ok)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("run not returned after shutdown")
+	}
+
+	if _, err := c2.Do("ping"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := os.Stat(path.Join(cfg.DataDir, "backup")); err != nil {
+		t.Fatal(err)
+	}
+
+	//data and binlog are saved
+	l, err := ledisdb.Open(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	db, _ := l.Select(0)
+	if v, err := db.Get([]byte("a")); err != nil {
+		t.Fatal(err)
+	} else if string(v) != "1" {
+		t.Fatal(string(v))
+	}
+
+	if id := l.BinLog().LastLogID(); id != 1 {
+		t.Fatal(id)
+	}
+}
+
+func TestShutdownTimeout(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_shutdown_timeout"
+	os.RemoveAll(cfg.DataDir)
+
+	cfg.Addr = "127.0.0.1:16392"
+	cfg.ShutdownTimeout = 1
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go app.Run()
+
+	db, _ := app.ldb.Select(0)
+	if err = db.Set([]byte("big"), make([]byte, 1024*1024)); err != nil {
+		t.Fatal(err)
+	}
+
+	//a client never reading replies blocks its request
+	conn, err := net.Dial("tcp", cfg.Addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	for i := 0; i < 64; i++ {
+		if _, err = conn.Write([]byte("*2\r\n$3\r\nget\r\n$3\r\nbig\r\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+
+	done := make(chan struct{})
+	go func() {
+		app.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close must not wait the blocked client after shutdown timeout")
+	}
+
+	//no request runs after the store is closed
+	if !app.waitRequests(time.Second) {
+		t.Fatal("requests still running")
+	}
+}
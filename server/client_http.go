@@ -20,10 +20,19 @@ var allowedContentTypes = map[string]struct{}{
 	"msgpack": struct{}{},
 }
 var unsopportedCommands = map[string]struct{}{
-	"slaveof":  struct{}{},
-	"fullsync": struct{}{},
-	"sync":     struct{}{},
-	"quit":     struct{}{},
+	"slaveof":   struct{}{},
+	"replicaof": struct{}{},
+	"fullsync":  struct{}{},
+	"sync":      struct{}{},
+	"quit":      struct{}{},
+	"client":    struct{}{},
+	"shutdown":  struct{}{},
+	"hello":     struct{}{},
+
+	"subscribe":    struct{}{},
+	"unsubscribe":  struct{}{},
+	"psubscribe":   struct{}{},
+	"punsubscribe": struct{}{},
 }
 
 type httpClient struct {
@@ -58,6 +67,7 @@ func newClientHTTP(app *App, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	c.req.perform()
+	c.req.resp.flush()
 }
 
 func (c *httpClient) addr(r *http.Request) string {
@@ -168,10 +178,46 @@ func (w *httpWriter) writeBulk(b []byte) {
 	}
 }
 
+func (w *httpWriter) writeNull() {
+	w.genericWrite(nil)
+}
+
+func (w *httpWriter) writeBool(b bool) {
+	w.genericWrite(b)
+}
+
+func (w *httpWriter) writeDouble(f float64) {
+	w.genericWrite(f)
+}
+
 func (w *httpWriter) writeArray(lst []interface{}) {
 	w.genericWrite(lst)
 }
 
+func (w *httpWriter) writeMap(kvs []interface{}) {
+	m := make(map[string]interface{}, len(kvs)/2)
+	for i := 0; i+1 < len(kvs); i += 2 {
+		var key string
+		switch k := kvs[i].(type) {
+		case []byte:
+			key = ledis.String(k)
+		default:
+			key = fmt.Sprint(k)
+		}
+
+		if v, ok := kvs[i+1].([]byte); ok {
+			m[key] = ledis.String(v)
+		} else {
+			m[key] = kvs[i+1]
+		}
+	}
+	w.genericWrite(m)
+}
+
+func (w *httpWriter) writePush(lst []interface{}) {
+	w.genericWrite(lst)
+}
+
 func (w *httpWriter) writeSliceArray(lst [][]byte) {
 	arr := make([]interface{}, len(lst))
 	for i, elem := range lst {
@@ -6,27 +6,54 @@ import (
 	"github.com/siddontang/go-log/log"
 	"github.com/siddontang/ledisdb/ledis"
 	"io"
+	"math"
 	"net"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 )
 
 var errReadRequest = errors.New("invalid request protocol")
 
+var (
+	errMaxClients = errors.New("max number of clients reached")
+	errSubscribed = errors.New("only (P)SUBSCRIBE / (P)UNSUBSCRIBE / PING / QUIT allowed in this context")
+)
+
 type respClient struct {
 	app *App
 	ldb *ledis.Ledis
-	db  *ledis.DB
 
 	conn net.Conn
 	rb   *bufio.Reader
 
-	req *requestContext
+	req  *requestContext
+	resp *respWriter
+
+	id         int64
+	createTime time.Time
+
+	//set by CLIENT KILL on itself, closes after the reply
+	closeAfterReply bool
+
+	//not nil after the first subscribe, then messages are pushed
+	sub *subscriber
+
+	//protects the below, read by CLIENT LIST
+	lock       sync.Mutex
+	db         *ledis.DB
+	name       string
+	lastCmd    string
+	lastActive time.Time
 }
 
 type respWriter struct {
 	buff *bufio.Writer
+
+	//2 or 3, set by HELLO
+	proto int
 }
 
 func newClientRESP(conn net.Conn, app *App) {
@@ -37,11 +64,25 @@ func newClientRESP(conn net.Conn, app *App) {
 	c.ldb = app.ldb
 	c.db, _ = app.ldb.Select(0)
 
-	c.rb = bufio.NewReaderSize(conn, 256)
+	c.rb = bufio.NewReaderSize(conn, 4096)
 
 	c.req = newRequestContext(app)
-	c.req.resp = newWriterRESP(conn)
+	c.resp = newWriterRESP(conn)
+	c.req.resp = c.resp
 	c.req.remoteAddr = conn.RemoteAddr().String()
+	c.req.client = c
+
+	c.createTime = time.Now()
+	c.lastActive = c.createTime
+
+	if !app.clients.add(c) {
+		c.req.resp.writeError(errMaxClients)
+		c.req.resp.flush()
+		conn.Close()
+		return
+	}
+
+	app.stat.connect()
 
 	go c.run()
 }
@@ -56,16 +97,102 @@ func (c *respClient) run() {
 			log.Fatal("client run panic %s:%v", buf, e)
 		}
 
+		c.app.removeSlave(c.req.remoteAddr)
+		c.app.clients.remove(c)
+
+		if c.sub != nil {
+			c.app.pubsub.unsubscribeAll(c.sub)
+		}
+		c.app.stat.disconnect()
+
 		c.conn.Close()
 	}()
 
 	for {
+		//timeout may be changed by CONFIG SET, no deadline if disabled
+		var deadline time.Time
+		if d := c.app.clients.idleTimeout(); d > 0 {
+			deadline = time.Now().Add(d)
+		}
+		c.conn.SetReadDeadline(deadline)
+
 		reqData, err := c.readRequest()
 		if err != nil {
 			return
 		}
 
 		c.handleRequest(reqData)
+
+		if c.sub != nil {
+			c.req.resp.flush()
+			c.runPush()
+			return
+		}
+
+		//replies of pipelined requests already read are flushed together
+		if c.rb.Buffered() > 0 && !c.closeAfterReply {
+			continue
+		}
+
+		c.req.resp.flush()
+
+		if c.closeAfterReply {
+			return
+		}
+	}
+}
+
+//runPush serves a subscribed client, requests are read in another goroutine,
+//replies and pushed messages are written here
+func (c *respClient) runPush() {
+	//subscribed clients are never idle
+	c.conn.SetReadDeadline(time.Time{})
+
+	reqs := make(chan [][]byte)
+	quit := make(chan struct{})
+	defer close(quit)
+
+	go func() {
+		defer close(reqs)
+
+		for {
+			reqData, err := c.readRequest()
+			if err != nil {
+				return
+			}
+
+			select {
+			case reqs <- reqData:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case reqData, ok := <-reqs:
+			if !ok {
+				return
+			}
+			c.handleRequest(reqData)
+		case msg := <-c.sub.msgs:
+			c.req.resp.writePush(msg)
+		case <-c.sub.dropped:
+			log.Warn("client %s can not keep up with messages, closed", c.req.remoteAddr)
+			return
+		}
+
+		//write pending messages together
+		if len(c.sub.msgs) > 0 && !c.closeAfterReply {
+			continue
+		}
+
+		c.req.resp.flush()
+
+		if c.closeAfterReply {
+			return
+		}
 	}
 }
 
@@ -138,6 +265,13 @@ func (c *respClient) handleRequest(reqData [][]byte) {
 		c.req.cmd = strings.ToLower(ledis.String(reqData[0]))
 		c.req.args = reqData[1:]
 	}
+	if c.sub != nil && c.sub.count() > 0 && c.resp.proto == 2 {
+		if _, ok := subscribedCommands[c.req.cmd]; !ok {
+			c.req.resp.writeError(errSubscribed)
+			return
+		}
+	}
+
 	if c.req.cmd == "quit" {
 		c.req.resp.writeStatus(OK)
 		c.req.resp.flush()
@@ -145,11 +279,17 @@ func (c *respClient) handleRequest(reqData [][]byte) {
 		return
 	}
 
+	c.lock.Lock()
+	c.lastCmd = req.cmd
+	c.lastActive = time.Now()
 	req.db = c.db
+	c.lock.Unlock()
 
 	c.req.perform()
 
+	c.lock.Lock()
 	c.db = req.db // "SELECT"
+	c.lock.Unlock()
 
 	return
 }
@@ -158,10 +298,17 @@ func (c *respClient) handleRequest(reqData [][]byte) {
 
 func newWriterRESP(conn net.Conn) *respWriter {
 	w := new(respWriter)
-	w.buff = bufio.NewWriterSize(conn, 256)
+	w.buff = bufio.NewWriterSize(conn, 4096)
+	w.proto = 2
 	return w
 }
 
+func (w *respWriter) writeLen(prefix byte, n int) {
+	w.buff.WriteByte(prefix)
+	w.buff.Write(ledis.Slice(strconv.Itoa(n)))
+	w.buff.Write(Delims)
+}
+
 func (w *respWriter) writeError(err error) {
 	w.buff.Write(ledis.Slice("-ERR"))
 	if err != nil {
@@ -183,97 +330,194 @@ func (w *respWriter) writeInteger(n int64) {
 	w.buff.Write(Delims)
 }
 
-func (w *respWriter) writeBulk(b []byte) {
-	w.buff.WriteByte('$')
-	if b == nil {
+//writeNull writes null bulk in RESP2, null in RESP3
+func (w *respWriter) writeNull() {
+	if w.proto == 3 {
+		w.buff.Write(Null)
+	} else {
+		w.buff.WriteByte('$')
 		w.buff.Write(NullBulk)
+	}
+	w.buff.Write(Delims)
+}
+
+func (w *respWriter) writeNullArray() {
+	if w.proto == 3 {
+		w.buff.Write(Null)
+	} else {
+		w.buff.WriteByte('*')
+		w.buff.Write(NullArray)
+	}
+	w.buff.Write(Delims)
+}
+
+//writeBool writes integer 1 or 0 in RESP2
+func (w *respWriter) writeBool(b bool) {
+	if w.proto == 3 {
+		w.buff.WriteByte('#')
+		if b {
+			w.buff.WriteByte('t')
+		} else {
+			w.buff.WriteByte('f')
+		}
+		w.buff.Write(Delims)
+	} else if b {
+		w.writeInteger(1)
 	} else {
-		w.buff.Write(ledis.Slice(strconv.Itoa(len(b))))
+		w.writeInteger(0)
+	}
+}
+
+//writeDouble writes bulk string in RESP2
+func (w *respWriter) writeDouble(f float64) {
+	var s string
+	switch {
+	case math.IsInf(f, 1):
+		s = "inf"
+	case math.IsInf(f, -1):
+		s = "-inf"
+	case math.IsNaN(f):
+		s = "nan"
+	default:
+		s = strconv.FormatFloat(f, 'g', -1, 64)
+	}
+
+	if w.proto == 3 {
+		w.buff.WriteByte(',')
+		w.buff.Write(ledis.Slice(s))
 		w.buff.Write(Delims)
-		w.buff.Write(b)
+	} else {
+		w.writeBulk(ledis.Slice(s))
 	}
+}
 
+func (w *respWriter) writeBulk(b []byte) {
+	if b == nil {
+		w.writeNull()
+		return
+	}
+
+	w.writeLen('$', len(b))
+	w.buff.Write(b)
 	w.buff.Write(Delims)
 }
 
+func (w *respWriter) writeValue(v interface{}) {
+	switch v := v.(type) {
+	case []interface{}:
+		w.writeArray(v)
+	case []byte:
+		w.writeBulk(v)
+	case nil:
+		w.writeNull()
+	case int64:
+		w.writeInteger(v)
+	case bool:
+		w.writeBool(v)
+	case float64:
+		w.writeDouble(v)
+	default:
+		panic("invalid array type")
+	}
+}
+
 func (w *respWriter) writeArray(lst []interface{}) {
-	w.buff.WriteByte('*')
 	if lst == nil {
-		w.buff.Write(NullArray)
-		w.buff.Write(Delims)
+		w.writeNullArray()
+		return
+	}
+
+	w.writeLen('*', len(lst))
+	for i := 0; i < len(lst); i++ {
+		w.writeValue(lst[i])
+	}
+}
+
+//writeMap writes key and value pairs as a map in RESP3, an array in RESP2
+func (w *respWriter) writeMap(kvs []interface{}) {
+	if w.proto == 3 {
+		w.writeLen('%', len(kvs)/2)
 	} else {
-		w.buff.Write(ledis.Slice(strconv.Itoa(len(lst))))
-		w.buff.Write(Delims)
+		w.writeLen('*', len(kvs))
+	}
 
-		for i := 0; i < len(lst); i++ {
-			switch v := lst[i].(type) {
-			case []interface{}:
-				w.writeArray(v)
-			case []byte:
-				w.writeBulk(v)
-			case nil:
-				w.writeBulk(nil)
-			case int64:
-				w.writeInteger(v)
-			default:
-				panic("invalid array type")
-			}
-		}
+	for i := 0; i < len(kvs); i++ {
+		w.writeValue(kvs[i])
+	}
+}
+
+//writePush writes an out of band message, an array in RESP2
+func (w *respWriter) writePush(lst []interface{}) {
+	if w.proto == 3 {
+		w.writeLen('>', len(lst))
+	} else {
+		w.writeLen('*', len(lst))
+	}
+
+	for i := 0; i < len(lst); i++ {
+		w.writeValue(lst[i])
 	}
 }
 
 func (w *respWriter) writeSliceArray(lst [][]byte) {
-	w.buff.WriteByte('*')
 	if lst == nil {
-		w.buff.Write(NullArray)
-		w.buff.Write(Delims)
-	} else {
-		w.buff.Write(ledis.Slice(strconv.Itoa(len(lst))))
-		w.buff.Write(Delims)
+		w.writeNullArray()
+		return
+	}
 
-		for i := 0; i < len(lst); i++ {
-			w.writeBulk(lst[i])
-		}
+	w.writeLen('*', len(lst))
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i])
 	}
 }
 
 func (w *respWriter) writeFVPairArray(lst []ledis.FVPair) {
-	w.buff.WriteByte('*')
 	if lst == nil {
-		w.buff.Write(NullArray)
-		w.buff.Write(Delims)
+		w.writeNullArray()
+		return
+	}
+
+	if w.proto == 3 {
+		w.writeLen('%', len(lst))
 	} else {
-		w.buff.Write(ledis.Slice(strconv.Itoa(len(lst) * 2)))
-		w.buff.Write(Delims)
+		w.writeLen('*', len(lst)*2)
+	}
 
-		for i := 0; i < len(lst); i++ {
-			w.writeBulk(lst[i].Field)
-			w.writeBulk(lst[i].Value)
-		}
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i].Field)
+		w.writeBulk(lst[i].Value)
 	}
 }
 
+//scores are written as integers, a double can not hold all int64 scores,
+//each member and its score is a pair in RESP3
 func (w *respWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool) {
-	w.buff.WriteByte('*')
 	if lst == nil {
-		w.buff.Write(NullArray)
-		w.buff.Write(Delims)
-	} else {
-		if withScores {
-			w.buff.Write(ledis.Slice(strconv.Itoa(len(lst) * 2)))
-			w.buff.Write(Delims)
-		} else {
-			w.buff.Write(ledis.Slice(strconv.Itoa(len(lst))))
-			w.buff.Write(Delims)
-
-		}
+		w.writeNullArray()
+		return
+	}
 
+	if withScores && w.proto == 3 {
+		w.writeLen('*', len(lst))
 		for i := 0; i < len(lst); i++ {
+			w.writeLen('*', 2)
 			w.writeBulk(lst[i].Member)
+			w.writeInteger(lst[i].Score)
+		}
+		return
+	}
 
-			if withScores {
-				w.writeBulk(ledis.StrPutInt64(lst[i].Score))
-			}
+	if withScores {
+		w.writeLen('*', len(lst)*2)
+	} else {
+		w.writeLen('*', len(lst))
+	}
+
+	for i := 0; i < len(lst); i++ {
+		w.writeBulk(lst[i].Member)
+
+		if withScores {
+			w.writeBulk(ledis.StrPutInt64(lst[i].Score))
 		}
 	}
 }
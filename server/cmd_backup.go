@@ -0,0 +1,110 @@
+package server
+
+import (
+	"errors"
+	"github.com/siddontang/ledisdb/ledis"
+	"path"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errBackupInProgress = errors.New("backup already in progress")
+)
+
+type backupState struct {
+	sync.Mutex
+
+	inProgress bool
+	dir        string
+	started    int64
+
+	//bytes written by the running backup
+	written int64
+
+	lastStatus string
+	lastTime   int64
+	lastLogID  uint64
+	lastNative bool
+}
+
+func (app *App) startBackup(dir string) error {
+	s := &app.backup
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.inProgress {
+		return errBackupInProgress
+	}
+
+	s.inProgress = true
+	s.dir = dir
+	s.started = time.Now().Unix()
+	atomic.StoreInt64(&s.written, 0)
+
+	go app.runBackup(dir)
+
+	return nil
+}
+
+func (app *App) runBackup(dir string) {
+	s := &app.backup
+
+	info, err := app.ldb.Backup(dir, &s.written)
+
+	s.Lock()
+	defer s.Unlock()
+
+	s.inProgress = false
+	s.lastTime = time.Now().Unix()
+
+	if err != nil {
+		s.lastStatus = err.Error()
+		return
+	}
+
+	s.lastStatus = "ok"
+	s.lastLogID = info.LogID
+	s.lastNative = info.Native
+}
+
+//BACKUP dir
+func backupCommand(req *requestContext) error {
+	args := req.args
+	if len(args) != 1 {
+		return ErrCmdParams
+	}
+
+	dir := ledis.String(args[0])
+	if len(dir) == 0 {
+		return ErrCmdParams
+	}
+
+	if err := req.app.startBackup(dir); err != nil {
+		return err
+	}
+
+	req.resp.writeStatus("Background backup started")
+	return nil
+}
+
+//BGSAVE, backup to the backup dir in data dir
+func bgsaveCommand(req *requestContext) error {
+	if len(req.args) != 0 {
+		return ErrCmdParams
+	}
+
+	if err := req.app.startBackup(path.Join(req.app.cfg.DataDir, "backup")); err != nil {
+		return err
+	}
+
+	req.resp.writeStatus("Background saving started")
+	return nil
+}
+
+func init() {
+	register("backup", backupCommand, cmdRead)
+	register("bgsave", bgsaveCommand, cmdRead)
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"github.com/siddontang/ledisdb/config"
+	"os"
+	"path"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackupCommand(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_backup_command"
+	cfg.Addr = "127.0.0.1:11185"
+	cfg.BinLog.MaxFileSize = 1024 * 1024
+	cfg.BinLog.MaxFileNum = 10
+
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	go app.Run()
+
+	c := ledis.NewClient(&ledis.Config{Addr: cfg.Addr, MaxIdleConns: 1}).Get()
+	defer c.Close()
+
+	for i := 0; i < 10; i++ {
+		if _, err := c.Do("set", i, i); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	dir := path.Join(cfg.DataDir, "backup_test")
+	if _, err := c.Do("backup", dir); err != nil {
+		t.Fatal(err)
+	}
+
+	var info string
+	for i := 0; i < 100; i++ {
+		if info, err = ledis.String(c.Do("info", "persistence")); err != nil {
+			t.Fatal(err)
+		} else if strings.Contains(info, "last_backup_status") {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if !strings.Contains(info, "last_backup_status:ok") {
+		t.Fatal(info)
+	} else if !strings.Contains(info, "last_backup_log_id:10") {
+		t.Fatal(info)
+	}
+
+	if _, err := os.Stat(path.Join(dir, "backup.info")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ledis.String(c.Do("info", "server")); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("info", "unknown"); err == nil {
+		t.Fatal("must error")
+	}
+}
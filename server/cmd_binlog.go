@@ -0,0 +1,129 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/siddontang/ledisdb/ledis"
+	"os"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	errBinLogDisabled = errors.New("binlog is not enabled")
+)
+
+const binlogTimeFormat = "2006-01-02 15:04:05"
+
+func binlogCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	b := req.app.ldb.BinLog()
+	if b == nil {
+		return errBinLogDisabled
+	}
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "list":
+		return binlogListCommand(req, b)
+	case "info":
+		return binlogInfoCommand(req, b)
+	case "purge":
+		return binlogPurgeCommand(req, b)
+	default:
+		return ErrSyntax
+	}
+}
+
+func binlogListCommand(req *requestContext, b *ledis.BinLog) error {
+	if len(req.args) != 1 {
+		return ErrCmdParams
+	}
+
+	names := b.LogNames()
+	ay := make([]interface{}, 0, len(names))
+
+	for _, name := range names {
+		var size int64
+		if st, err := os.Stat(path.Join(b.LogPath(), name)); err == nil {
+			size = st.Size()
+		}
+
+		ay = append(ay, []interface{}{[]byte(name), size})
+	}
+
+	req.resp.writeArray(ay)
+	return nil
+}
+
+func binlogInfoCommand(req *requestContext, b *ledis.BinLog) error {
+	if len(req.args) != 1 {
+		return ErrCmdParams
+	}
+
+	maxFileSize, maxFileNum := b.Limits()
+
+	ay := []interface{}{
+		[]byte("log_file_index"), b.LogFileIndex(),
+		[]byte("log_file_pos"), b.LogFilePos(),
+		[]byte("log_file_num"), int64(len(b.LogNames())),
+		[]byte("first_log_id"), int64(b.FirstLogID()),
+		[]byte("last_log_id"), int64(b.LastLogID()),
+		[]byte("max_file_size"), int64(maxFileSize),
+		[]byte("max_file_num"), int64(maxFileNum),
+	}
+
+	req.resp.writeMap(ay)
+	return nil
+}
+
+//BINLOG PURGE TO index
+//BINLOG PURGE BEFORE "2006-01-02 15:04:05"
+func binlogPurgeCommand(req *requestContext, b *ledis.BinLog) error {
+	args := req.args
+	if len(args) != 3 {
+		return ErrCmdParams
+	}
+
+	var index int64
+	var err error
+
+	switch strings.ToLower(ledis.String(args[1])) {
+	case "to":
+		if index, err = ledis.StrInt64(args[2], nil); err != nil {
+			return ErrValue
+		}
+	case "before":
+		var t time.Time
+		if t, err = time.ParseInLocation(binlogTimeFormat, ledis.String(args[2]), time.Local); err != nil {
+			return err
+		}
+
+		if index, err = b.LogFileIndexSince(t); err != nil {
+			return err
+		}
+	default:
+		return ErrSyntax
+	}
+
+	if addr, logID := req.app.oldestSlave(); len(addr) > 0 {
+		if logIndex := b.LogFileIndexOf(logID); logIndex < index {
+			return fmt.Errorf("binlog %s is still needed by slave %s", b.FormatLogFileName(logIndex), addr)
+		}
+	}
+
+	if err = b.PurgeTo(index); err != nil {
+		return err
+	}
+
+	req.resp.writeStatus(OK)
+	return nil
+}
+
+func init() {
+	register("binlog", binlogCommand, cmdRead)
+}
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"github.com/siddontang/ledisdb/config"
+	"os"
+	"testing"
+)
+
+func TestBinLogCommand(t *testing.T) {
+	cfg := new(config.Config)
+	cfg.DataDir = "/tmp/test_binlog_command"
+	cfg.Addr = "127.0.0.1:11184"
+	cfg.BinLog.MaxFileSize = 100
+	cfg.BinLog.MaxFileNum = 10
+
+	os.RemoveAll(cfg.DataDir)
+
+	app, err := NewApp(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer app.Close()
+
+	go app.Run()
+
+	c := ledis.NewClient(&ledis.Config{Addr: cfg.Addr, MaxIdleConns: 1}).Get()
+	defer c.Close()
+
+	value := make([]byte, 100)
+	for i := 0; i < 4; i++ {
+		if _, err := c.Do("set", i, value); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ay, err := ledis.Values(c.Do("binlog", "list")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 {
+		t.Fatal(len(ay))
+	}
+
+	if ay, err := ledis.Values(c.Do("binlog", "info")); err != nil {
+		t.Fatal(err)
+	} else if n, _ := ledis.Int64(ay[1], nil); n != 5 {
+		t.Fatal(n)
+	}
+
+	app.addSlave("slave", 1, nil)
+
+	if _, err := c.Do("binlog", "purge", "to", 3); err == nil {
+		t.Fatal("must error, binlog is still needed by slave")
+	}
+
+	if _, err := c.Do("binlog", "purge", "to", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	app.removeSlave("slave")
+
+	if _, err := c.Do("binlog", "purge", "before", "2099-01-01 00:00:00"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ay, err := ledis.Values(c.Do("binlog", "list")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 0 {
+		t.Fatal(len(ay))
+	}
+}
@@ -273,15 +273,15 @@ func bpersistCommand(req *requestContext) error {
 }
 
 func init() {
-	register("bget", bgetCommand)
-	register("bdelete", bdeleteCommand)
-	register("bsetbit", bsetbitCommand)
-	register("bgetbit", bgetbitCommand)
-	register("bmsetbit", bmsetbitCommand)
-	register("bcount", bcountCommand)
-	register("bopt", boptCommand)
-	register("bexpire", bexpireCommand)
-	register("bexpireat", bexpireAtCommand)
-	register("bttl", bttlCommand)
-	register("bpersist", bpersistCommand)
+	register("bget", bgetCommand, cmdRead)
+	register("bdelete", bdeleteCommand, cmdWrite)
+	register("bsetbit", bsetbitCommand, cmdWrite)
+	register("bgetbit", bgetbitCommand, cmdRead)
+	register("bmsetbit", bmsetbitCommand, cmdWrite)
+	register("bcount", bcountCommand, cmdRead)
+	register("bopt", boptCommand, cmdWrite)
+	register("bexpire", bexpireCommand, cmdWrite)
+	register("bexpireat", bexpireAtCommand, cmdWrite)
+	register("bttl", bttlCommand, cmdRead)
+	register("bpersist", bpersistCommand, cmdWrite)
 }
@@ -0,0 +1,317 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errNoSuchClient    = errors.New("no such client")
+	errClientNameSpace = errors.New("client name can not contain spaces")
+)
+
+//clientList keeps all connected resp clients
+type clientList struct {
+	sync.Mutex
+
+	lastID  int64
+	clients map[int64]*respClient
+
+	//read with atomic, changed by CONFIG SET
+	maxClients int64
+	//seconds
+	timeout int64
+}
+
+func newClientList(cfg *config.Config) *clientList {
+	l := new(clientList)
+
+	l.clients = make(map[int64]*respClient)
+
+	l.maxClients = int64(cfg.MaxClients)
+	l.timeout = int64(cfg.Timeout)
+
+	return l
+}
+
+//add registers c and gives it an id, fails if max clients reached
+func (l *clientList) add(c *respClient) bool {
+	l.Lock()
+	defer l.Unlock()
+
+	if max := atomic.LoadInt64(&l.maxClients); max > 0 && int64(len(l.clients)) >= max {
+		return false
+	}
+
+	l.lastID++
+	c.id = l.lastID
+	l.clients[c.id] = c
+
+	return true
+}
+
+func (l *clientList) remove(c *respClient) {
+	l.Lock()
+	delete(l.clients, c.id)
+	l.Unlock()
+}
+
+//closeAll closes the connections of all clients, they remove themselves
+func (l *clientList) closeAll() {
+	for _, c := range l.all() {
+		c.conn.Close()
+	}
+}
+
+func (l *clientList) len() int {
+	l.Lock()
+	defer l.Unlock()
+
+	return len(l.clients)
+}
+
+//all returns clients sorted by id
+func (l *clientList) all() []*respClient {
+	l.Lock()
+	defer l.Unlock()
+
+	ay := make(clientsByID, 0, len(l.clients))
+	for _, c := range l.clients {
+		ay = append(ay, c)
+	}
+	sort.Sort(ay)
+
+	return ay
+}
+
+type clientsByID []*respClient
+
+func (s clientsByID) Len() int           { return len(s) }
+func (s clientsByID) Less(i, j int) bool { return s[i].id < s[j].id }
+func (s clientsByID) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+
+func (l *clientList) idleTimeout() time.Duration {
+	return time.Duration(atomic.LoadInt64(&l.timeout)) * time.Second
+}
+
+func (c *respClient) info(now time.Time) string {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return fmt.Sprintf("id=%d addr=%s name=%s age=%d idle=%d db=%d cmd=%s",
+		c.id, c.req.remoteAddr, c.name,
+		int64(now.Sub(c.createTime)/time.Second), int64(now.Sub(c.lastActive)/time.Second),
+		c.db.Index(), c.lastCmd)
+}
+
+//kill closes the connection of c, or after the reply if c is the client of req
+func (c *respClient) kill(req *requestContext) {
+	if req.client == c {
+		c.closeAfterReply = true
+	} else {
+		c.conn.Close()
+	}
+}
+
+func listClients(req *requestContext) error {
+	if len(req.args) != 1 {
+		return ErrCmdParams
+	}
+
+	now := time.Now()
+
+	var buf bytes.Buffer
+	for _, c := range req.app.clients.all() {
+		buf.WriteString(c.info(now))
+		buf.WriteByte('\n')
+	}
+
+	req.resp.writeBulk(buf.Bytes())
+	return nil
+}
+
+//CLIENT KILL addr
+//CLIENT KILL ID id|ADDR addr
+func killClients(req *requestContext) error {
+	args := req.args[1:]
+
+	if len(args) == 1 {
+		addr := ledis.String(args[0])
+		for _, c := range req.app.clients.all() {
+			if c.req.remoteAddr == addr {
+				c.kill(req)
+				req.resp.writeStatus(OK)
+				return nil
+			}
+		}
+		return errNoSuchClient
+	} else if len(args) != 2 {
+		return ErrCmdParams
+	}
+
+	var match func(c *respClient) bool
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "id":
+		id, err := strconv.ParseInt(ledis.String(args[1]), 10, 64)
+		if err != nil {
+			return ErrValue
+		}
+		match = func(c *respClient) bool { return c.id == id }
+	case "addr":
+		addr := ledis.String(args[1])
+		match = func(c *respClient) bool { return c.req.remoteAddr == addr }
+	default:
+		return ErrSyntax
+	}
+
+	var n int64
+	for _, c := range req.app.clients.all() {
+		if match(c) {
+			c.kill(req)
+			n++
+		}
+	}
+
+	req.resp.writeInteger(n)
+	return nil
+}
+
+func setClientName(req *requestContext) error {
+	if len(req.args) != 2 {
+		return ErrCmdParams
+	}
+
+	name := ledis.String(req.args[1])
+	if strings.ContainsAny(name, " \t\r\n") {
+		return errClientNameSpace
+	}
+
+	c := req.client
+	c.lock.Lock()
+	c.name = name
+	c.lock.Unlock()
+
+	req.resp.writeStatus(OK)
+	return nil
+}
+
+func getClientName(req *requestContext) error {
+	if len(req.args) != 1 {
+		return ErrCmdParams
+	}
+
+	c := req.client
+	c.lock.Lock()
+	name := c.name
+	c.lock.Unlock()
+
+	if len(name) == 0 {
+		req.resp.writeBulk(nil)
+	} else {
+		req.resp.writeBulk([]byte(name))
+	}
+	return nil
+}
+
+//CLIENT LIST
+//CLIENT KILL addr|ID id|ADDR addr
+//CLIENT SETNAME name
+//CLIENT GETNAME
+func clientCommand(req *requestContext) error {
+	if len(req.args) == 0 {
+		return ErrCmdParams
+	}
+
+	//only resp clients are registered
+	if req.client == nil {
+		return ErrNotFound
+	}
+
+	switch strings.ToLower(ledis.String(req.args[0])) {
+	case "list":
+		return listClients(req)
+	case "kill":
+		return killClients(req)
+	case "setname":
+		return setClientName(req)
+	case "getname":
+		return getClientName(req)
+	default:
+		return ErrSyntax
+	}
+}
+
+//HELLO [protover [SETNAME name]]
+//switches the protocol of the connection to RESP2 or RESP3, replies server info
+func helloCommand(req *requestContext) error {
+	args := req.args
+
+	if req.client == nil {
+		return ErrNotFound
+	}
+	w := req.client.resp
+
+	proto := w.proto
+	if len(args) > 0 {
+		v, err := strconv.Atoi(ledis.String(args[0]))
+		if err != nil {
+			return errors.New("protocol version is not an integer or out of range")
+		} else if v != 2 && v != 3 {
+			return errors.New("NOPROTO unsupported protocol version")
+		}
+		proto = v
+		args = args[1:]
+	}
+
+	var name []byte
+	if len(args) == 2 && strings.ToLower(ledis.String(args[0])) == "setname" {
+		name = args[1]
+	} else if len(args) != 0 {
+		return ErrSyntax
+	}
+
+	c := req.client
+	if name != nil {
+		if strings.ContainsAny(ledis.String(name), " \t\r\n") {
+			return errClientNameSpace
+		}
+
+		c.lock.Lock()
+		c.name = string(name)
+		c.lock.Unlock()
+	}
+
+	w.proto = proto
+
+	req.app.cfgLock.Lock()
+	role := "master"
+	if len(req.app.cfg.SlaveOf) > 0 {
+		role = "slave"
+	}
+	req.app.cfgLock.Unlock()
+
+	req.resp.writeMap([]interface{}{
+		[]byte("server"), []byte("ledis"),
+		[]byte("proto"), int64(proto),
+		[]byte("id"), c.id,
+		[]byte("mode"), []byte("standalone"),
+		[]byte("role"), []byte(role),
+		[]byte("modules"), []interface{}{},
+	})
+	return nil
+}
+
+func init() {
+	register("client", clientCommand, cmdRead)
+	register("hello", helloCommand, cmdRead)
+}
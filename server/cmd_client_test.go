@@ -0,0 +1,192 @@
+package server
+
+import (
+	"fmt"
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClientCommand(t *testing.T) {
+	//connections killed here must not be reused by other tests
+	c1 := newTestConn()
+	defer c1.Close()
+
+	c2 := newTestConn()
+	defer c2.Close()
+
+	if _, err := c1.Do("client", "setname", "c1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if name, err := ledis.String(c1.Do("client", "getname")); err != nil {
+		t.Fatal(err)
+	} else if name != "c1" {
+		t.Fatal(name)
+	}
+
+	if _, err := c2.Do("client", "setname", "a b"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c2.Do("select", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	s, err := ledis.String(c1.Do("client", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var id int64 = -1
+	for _, line := range strings.Split(s, "\n") {
+		if strings.Contains(line, "name=c1 ") {
+			if !strings.Contains(line, "db=0 cmd=client") {
+				t.Fatal(line)
+			}
+		} else if strings.Contains(line, "db=2 cmd=select") {
+			fmt.Sscanf(line, "id=%d", &id)
+		}
+	}
+
+	if id < 0 {
+		t.Fatal(s)
+	}
+
+	if n, err := ledis.Int64(c1.Do("client", "kill", "id", id)); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if _, err := c2.Do("ping"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c1.Do("client", "kill", "127.0.0.1:1"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestClientLimits(t *testing.T) {
+	c := newTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("ping"); err != nil {
+		t.Fatal(err)
+	}
+
+	n := testApp.clients.len()
+	if _, err := c.Do("config", "set", "maxclients", n); err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := newTestConn()
+	if _, err := c1.Do("ping"); err == nil || !strings.Contains(err.Error(), "max number of clients") {
+		t.Fatal(err)
+	}
+	c1.Close()
+
+	if _, err := c.Do("config", "set", "maxclients", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("config", "set", "timeout", 1); err != nil {
+		t.Fatal(err)
+	}
+
+	//c waits with the timeout from now
+	c1 = newTestConn()
+	defer c1.Close()
+	if _, err := c1.Do("config", "set", "timeout", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+
+	if _, err := c.Do("ping"); err == nil {
+		t.Fatal("must error")
+	}
+
+	//no timeout for c1 after timeout is disabled
+	if _, err := c1.Do("ping"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestHello(t *testing.T) {
+	//protocol of the connection is changed
+	c := newTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("hello", 4); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("zadd", "hello_z", 1, "a", 2, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("hset", "hello_h", "f", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	//RESP2
+	if ay, err := ledis.Values(c.Do("zrange", "hello_z", 0, -1, "withscores")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 {
+		t.Fatal(ay)
+	}
+
+	ay, err := ledis.Values(c.Do("hello", 3, "setname", "hello_c"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 12 || string(ay[0].([]byte)) != "server" || ay[3].(int64) != 3 {
+		t.Fatal(ay)
+	}
+
+	if name, err := ledis.String(c.Do("client", "getname")); err != nil {
+		t.Fatal(err)
+	} else if name != "hello_c" {
+		t.Fatal(name)
+	}
+
+	//member and score pairs
+	if ay, err := ledis.Values(c.Do("zrange", "hello_z", 0, -1, "withscores")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 {
+		t.Fatal(ay)
+	} else if pair := ay[1].([]interface{}); string(pair[0].([]byte)) != "b" || pair[1].(int64) != 2 {
+		t.Fatal(pair)
+	}
+
+	if ay, err := ledis.Strings(c.Do("hgetall", "hello_h")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 || ay[0] != "f" || ay[1] != "v" {
+		t.Fatal(ay)
+	}
+
+	if v, err := c.Do("get", "hello_no_key"); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal(v)
+	}
+
+	if ay, err := ledis.Values(c.Do("store", "caps")); err != nil {
+		t.Fatal(err)
+	} else if _, ok := ay[3].(bool); !ok {
+		t.Fatal(ay)
+	}
+
+	//back to RESP2
+	if _, err := c.Do("hello", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	if ay, err := ledis.Values(c.Do("store", "caps")); err != nil {
+		t.Fatal(err)
+	} else if _, ok := ay[3].(int64); !ok {
+		t.Fatal(ay)
+	}
+}
@@ -0,0 +1,269 @@
+package server
+
+import (
+	"fmt"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+type configSetter func(app *App, name string, value string) error
+
+//settings can be changed by CONFIG SET, each applies the value to app,
+//called with cfgLock held
+var configSetters = map[string]configSetter{
+	"access_log":              setAccessLogConfig,
+	"slowlog_log_slower_than": setSlowLogConfig,
+	"slowlog_max_len":         setSlowLogConfig,
+	"binlog.max_file_size":    setBinLogConfig,
+	"binlog.max_file_num":     setBinLogConfig,
+	"slaveof":                 setSlaveOfConfig,
+	"maxclients":              setClientConfig,
+	"timeout":                 setClientConfig,
+	"notify_keyspace_events":  setNotifyConfig,
+	"slave_read_only":         setSlaveReadOnlyConfig,
+	"lua_time_limit":          setScriptConfig,
+	"shutdown_timeout":        setShutdownConfig,
+}
+
+func setAccessLogConfig(app *App, name string, value string) error {
+	last := app.cfg.AccessLog
+	if err := app.cfg.Set(name, value); err != nil {
+		return err
+	}
+
+	var access *accessLog
+	if len(value) > 0 {
+		var err error
+		if access, err = app.openAccessLog(value); err != nil {
+			app.cfg.AccessLog = last
+			return err
+		}
+	}
+
+	app.accessLock.Lock()
+	old := app.access
+	app.access = access
+	app.accessLock.Unlock()
+
+	if old != nil {
+		old.Close()
+	}
+
+	return nil
+}
+
+func setSlowLogConfig(app *App, name string, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return ErrValue
+	}
+
+	if name == "slowlog_max_len" {
+		if n <= 0 {
+			return ErrValue
+		}
+		app.slowlog.setMaxLen(int(n))
+	} else {
+		atomic.StoreInt64(&app.slowlog.slowerThan, n)
+	}
+
+	return app.cfg.Set(name, value)
+}
+
+//applies to new connections for maxclients, next idle wait for timeout
+func setClientConfig(app *App, name string, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return ErrValue
+	}
+
+	if name == "maxclients" {
+		atomic.StoreInt64(&app.clients.maxClients, n)
+	} else {
+		atomic.StoreInt64(&app.clients.timeout, n)
+	}
+
+	return app.cfg.Set(name, value)
+}
+
+func setNotifyConfig(app *App, name string, value string) error {
+	if err := app.setNotifyFlags(value); err != nil {
+		return err
+	}
+
+	return app.cfg.Set(name, value)
+}
+
+//applies to scripts started later
+func setScriptConfig(app *App, name string, value string) error {
+	n, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || n < 0 {
+		return ErrValue
+	}
+
+	if err = app.cfg.Set(name, value); err != nil {
+		return err
+	}
+
+	atomic.StoreInt64(&app.scripts.timeLimit, n)
+	return nil
+}
+
+//read when shutdown
+func setShutdownConfig(app *App, name string, value string) error {
+	if n, err := strconv.ParseInt(value, 10, 64); err != nil || n <= 0 {
+		return ErrValue
+	}
+
+	return app.cfg.Set(name, value)
+}
+
+//binlog keeps its own copy of the limits, so only changed by it
+func setBinLogConfig(app *App, name string, value string) error {
+	b := app.ldb.BinLog()
+	if b == nil {
+		return errBinLogDisabled
+	}
+
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		return ErrValue
+	}
+
+	maxFileSize, maxFileNum := b.Limits()
+	if name == "binlog.max_file_size" {
+		maxFileSize = n
+	} else {
+		maxFileNum = n
+	}
+
+	b.SetLimits(maxFileSize, maxFileNum)
+	return nil
+}
+
+//value is host:port, empty or "no one" to stop replication
+func setSlaveOfConfig(app *App, name string, value string) error {
+	if strings.ToLower(value) == "no one" {
+		value = ""
+	}
+
+	if len(value) > 0 {
+		if _, _, err := net.SplitHostPort(value); err != nil {
+			return err
+		}
+	}
+
+	if err := app.checkWritableSlave(value, app.cfg.SlaveReadOnly); err != nil {
+		return err
+	}
+
+	if err := app.slaveof(value); err != nil {
+		return err
+	}
+
+	if err := app.cfg.Set(name, value); err != nil {
+		return err
+	}
+
+	app.updateReadOnly()
+	return nil
+}
+
+func setSlaveReadOnlyConfig(app *App, name string, value string) error {
+	readOnly, err := strconv.ParseBool(value)
+	if err != nil {
+		return err
+	}
+
+	if err = app.checkWritableSlave(app.cfg.SlaveOf, readOnly); err != nil {
+		return err
+	}
+
+	if err := app.cfg.Set(name, value); err != nil {
+		return err
+	}
+
+	app.updateReadOnly()
+	return nil
+}
+
+//configSnapshot returns a copy of the effective config, with cfgLock held
+func (app *App) configSnapshot() *config.Config {
+	cfg := new(config.Config)
+	*cfg = *app.cfg
+
+	if b := app.ldb.BinLog(); b != nil {
+		cfg.BinLog.MaxFileSize, cfg.BinLog.MaxFileNum = b.Limits()
+	}
+
+	return cfg
+}
+
+//CONFIG GET pattern
+//CONFIG SET name value
+//CONFIG REWRITE
+func configCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	app := req.app
+
+	app.cfgLock.Lock()
+	defer app.cfgLock.Unlock()
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "get":
+		if len(args) != 2 {
+			return ErrCmdParams
+		}
+
+		ay, err := app.configSnapshot().Get(strings.ToLower(ledis.String(args[1])))
+		if err != nil {
+			return err
+		}
+
+		values := make([]interface{}, 0, len(ay))
+		for _, v := range ay {
+			values = append(values, []byte(v))
+		}
+		req.resp.writeMap(values)
+	case "set":
+		if len(args) != 3 {
+			return ErrCmdParams
+		}
+
+		name := strings.ToLower(ledis.String(args[1]))
+		setter, ok := configSetters[name]
+		if !ok {
+			return fmt.Errorf("config %s can not be changed at runtime", name)
+		}
+
+		if err := setter(app, name, ledis.String(args[2])); err != nil {
+			return err
+		}
+		req.resp.writeStatus(OK)
+	case "rewrite":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		if err := app.configSnapshot().Rewrite(); err != nil {
+			return err
+		}
+		req.resp.writeStatus(OK)
+	default:
+		return ErrSyntax
+	}
+
+	return nil
+}
+
+func init() {
+	register("config", configCommand, cmdRead)
+}
@@ -0,0 +1,60 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"testing"
+)
+
+func TestConfigCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if ay, err := ledis.Strings(c.Do("config", "get", "addr")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 || ay[1] != "127.0.0.1:16380" {
+		t.Fatal(ay)
+	}
+
+	if ay, err := ledis.Strings(c.Do("config", "get", "binlog.*")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("config", "set", "slowlog_max_len", 64); err != nil {
+		t.Fatal(err)
+	}
+
+	if ay, err := ledis.Strings(c.Do("config", "get", "slowlog_max_len")); err != nil {
+		t.Fatal(err)
+	} else if ay[1] != "64" {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("config", "set", "slowlog_max_len", 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("config", "set", "addr", "127.0.0.1:6380"); err == nil {
+		t.Fatal("must error")
+	}
+
+	//binlog is not enabled in test app
+	if _, err := c.Do("config", "set", "binlog.max_file_num", 10); err == nil {
+		t.Fatal("must error")
+	}
+
+	//a failed access log change keeps the old one
+	if _, err := c.Do("config", "set", "access_log", "/dev/null/access.log"); err == nil {
+		t.Fatal("must error")
+	} else if ay, err := ledis.Strings(c.Do("config", "get", "access_log")); err != nil {
+		t.Fatal(err)
+	} else if ay[1] != "" {
+		t.Fatal(ay)
+	}
+
+	//config is not loaded from file
+	if _, err := c.Do("config", "rewrite"); err == nil {
+		t.Fatal("must error")
+	}
+}
@@ -229,20 +229,20 @@ func lpersistCommand(req *requestContext) error {
 }
 
 func init() {
-	register("lindex", lindexCommand)
-	register("llen", llenCommand)
-	register("lpop", lpopCommand)
-	register("lrange", lrangeCommand)
-	register("lpush", lpushCommand)
-	register("rpop", rpopCommand)
-	register("rpush", rpushCommand)
+	register("lindex", lindexCommand, cmdRead)
+	register("llen", llenCommand, cmdRead)
+	register("lpop", lpopCommand, cmdWrite)
+	register("lrange", lrangeCommand, cmdRead)
+	register("lpush", lpushCommand, cmdWrite)
+	register("rpop", rpopCommand, cmdWrite)
+	register("rpush", rpushCommand, cmdWrite)
 
 	//ledisdb special command
 
-	register("lclear", lclearCommand)
-	register("lmclear", lmclearCommand)
-	register("lexpire", lexpireCommand)
-	register("lexpireat", lexpireAtCommand)
-	register("lttl", lttlCommand)
-	register("lpersist", lpersistCommand)
+	register("lclear", lclearCommand, cmdWrite)
+	register("lmclear", lmclearCommand, cmdWrite)
+	register("lexpire", lexpireCommand, cmdWrite)
+	register("lexpireat", lexpireAtCommand, cmdWrite)
+	register("lttl", lttlCommand, cmdRead)
+	register("lpersist", lpersistCommand, cmdWrite)
 }
@@ -1,20 +1,33 @@
 package server
 
 import (
+	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"github.com/siddontang/go-log/log"
 	"github.com/siddontang/go-snappy/snappy"
 	"github.com/siddontang/ledisdb/ledis"
 	"io/ioutil"
+	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
+var errNoSuchSlave = errors.New("no such slave")
+
+//SLAVEOF host port|NO ONE
+//SLAVEOF replies the replication status without arguments, REPLICAOF is the same
 func slaveofCommand(req *requestContext) error {
 	args := req.args
 
-	if len(args) != 2 {
+	if len(args) == 0 {
+		return replicationStatus(req)
+	} else if len(args) != 2 {
 		return ErrCmdParams
 	}
 
@@ -31,7 +44,18 @@ func slaveofCommand(req *requestContext) error {
 		masterAddr = fmt.Sprintf("%s:%s", args[0], args[1])
 	}
 
-	if err := req.app.slaveof(masterAddr); err != nil {
+	req.app.cfgLock.Lock()
+	err := req.app.checkWritableSlave(masterAddr, req.app.cfg.SlaveReadOnly)
+	if err == nil {
+		err = req.app.slaveof(masterAddr)
+	}
+	if err == nil {
+		req.app.cfg.SlaveOf = masterAddr
+		req.app.updateReadOnly()
+	}
+	req.app.cfgLock.Unlock()
+
+	if err != nil {
 		return err
 	}
 
@@ -40,7 +64,85 @@ func slaveofCommand(req *requestContext) error {
 	return nil
 }
 
+//replicationStatus replies the master address, link status and replicated log ids if it is a slave
+func replicationStatus(req *requestContext) error {
+	app := req.app
+
+	app.cfgLock.Lock()
+	masterAddr := app.cfg.SlaveOf
+	app.cfgLock.Unlock()
+
+	if len(masterAddr) == 0 {
+		req.resp.writeMap([]interface{}{
+			[]byte("role"), []byte("master"),
+		})
+		return nil
+	}
+
+	req.resp.writeMap([]interface{}{
+		[]byte("role"), []byte("slave"),
+		[]byte("master_addr"), []byte(masterAddr),
+		[]byte("master_link_status"), []byte(app.m.stateName()),
+		[]byte("log_id"), int64(atomic.LoadUint64(&app.m.syncedLogID)),
+		[]byte("master_log_id"), int64(atomic.LoadUint64(&app.m.masterLogID)),
+		[]byte("read_only"), app.isReadOnly(),
+	})
+	return nil
+}
+
+//ROLE
+//master: ["master", last log id, [[slave addr, log id], ...]]
+//slave: ["slave", master host, master port, link status, replicated log id]
+func roleCommand(req *requestContext) error {
+	if len(req.args) != 0 {
+		return ErrCmdParams
+	}
+
+	app := req.app
+
+	app.cfgLock.Lock()
+	masterAddr := app.cfg.SlaveOf
+	app.cfgLock.Unlock()
+
+	if len(masterAddr) > 0 {
+		host, portStr, _ := net.SplitHostPort(masterAddr)
+		port, _ := strconv.ParseInt(portStr, 10, 64)
+
+		req.resp.writeArray([]interface{}{
+			[]byte("slave"),
+			[]byte(host),
+			port,
+			[]byte(app.m.stateName()),
+			int64(atomic.LoadUint64(&app.m.syncedLogID)),
+		})
+		return nil
+	}
+
+	var lastID uint64
+	if b := app.ldb.BinLog(); b != nil {
+		lastID = b.LastLogID()
+	}
+
+	var slaves []interface{}
+	for _, s := range app.slaveList() {
+		slaves = append(slaves, []interface{}{[]byte(s.addr), int64(s.logID)})
+	}
+
+	req.resp.writeArray([]interface{}{
+		[]byte("master"),
+		int64(lastID),
+		slaves,
+	})
+	return nil
+}
+
 func fullsyncCommand(req *requestContext) error {
+	//a slave is tracked only on a connection which removes it when closed
+	if b := req.app.ldb.BinLog(); b != nil && req.client != nil {
+		//the dump starts from at least the current binlog
+		req.app.addSlave(req.remoteAddr, b.LastLogID(), req.client)
+	}
+
 	//todo, multi fullsync may use same dump file
 	dumpFile, err := ioutil.TempFile(req.app.cfg.DataDir, "dump_")
 	if err != nil {
@@ -68,41 +170,51 @@ func fullsyncCommand(req *requestContext) error {
 
 var reserveInfoSpace = make([]byte, 16)
 
+//sync reply before compressed:
+//firstLogID(bigendian uint64)|lastLogID(bigendian uint64)|events
+//
+//firstLogID is the oldest log id master keeps, 0 if master binlog is not enabled,
+//lastLogID is the newest log id on master
 func syncCommand(req *requestContext) error {
 	args := req.args
-	if len(args) != 2 {
+	if len(args) != 1 {
 		return ErrCmdParams
 	}
 
-	var logIndex int64
-	var logPos int64
+	var logID uint64
 	var err error
-	logIndex, err = ledis.StrInt64(args[0], nil)
+	logID, err = strconv.ParseUint(ledis.String(args[0]), 10, 64)
 	if err != nil {
 		return ErrCmdParams
 	}
 
-	logPos, err = ledis.StrInt64(args[1], nil)
-	if err != nil {
-		return ErrCmdParams
+	if req.client != nil {
+		req.app.addSlave(req.remoteAddr, logID, req.client)
 	}
 
 	req.syncBuf.Reset()
 
-	//reserve space to write master info
+	//reserve space to write log ids
 	if _, err := req.syncBuf.Write(reserveInfoSpace); err != nil {
 		return err
 	}
 
-	m := &ledis.MasterInfo{logIndex, logPos}
+	var firstID, lastID uint64
+	if b := req.app.ldb.BinLog(); b != nil {
+		firstID = b.FirstLogID()
+	}
 
-	if _, err := req.app.ldb.ReadEventsTo(m, &req.syncBuf); err != nil {
+	if _, err := req.app.ldb.ReadEventsTo(logID, &req.syncBuf); err != nil {
 		return err
 	} else {
+		if b := req.app.ldb.BinLog(); b != nil {
+			lastID = b.LastLogID()
+		}
+
 		buf := req.syncBuf.Bytes()
 
-		binary.BigEndian.PutUint64(buf[0:], uint64(m.LogFileIndex))
-		binary.BigEndian.PutUint64(buf[8:], uint64(m.LogPos))
+		binary.BigEndian.PutUint64(buf[0:], firstID)
+		binary.BigEndian.PutUint64(buf[8:], lastID)
 
 		if len(req.compressBuf) < snappy.MaxEncodedLen(len(buf)) {
 			req.compressBuf = make([]byte, snappy.MaxEncodedLen(len(buf)))
@@ -118,8 +230,145 @@ func syncCommand(req *requestContext) error {
 	return nil
 }
 
+//slaveInfo is what master knows about a connected slave
+type slaveInfo struct {
+	addr string
+
+	//log id last requested by SYNC, or the binlog position of the FULLSYNC dump
+	logID uint64
+
+	lastContact time.Time
+
+	//nil if not from a resp client
+	client *respClient
+}
+
+//fields formats the slave with its lag behind the binlog of app
+func (s *slaveInfo) fields(app *App, now time.Time) []string {
+	size, seconds, err := app.ldb.ReplicationLag(s.logID)
+	if err != nil {
+		log.Error("get replication lag of slave %s error %s", s.addr, err.Error())
+	}
+
+	return []string{
+		fmt.Sprintf("addr=%s", s.addr),
+		fmt.Sprintf("log_id=%d", s.logID),
+		fmt.Sprintf("lag_bytes=%d", size),
+		fmt.Sprintf("lag=%d", seconds),
+		fmt.Sprintf("last_contact=%d", int64(now.Sub(s.lastContact)/time.Second)),
+	}
+}
+
+func (app *App) addSlave(addr string, logID uint64, c *respClient) {
+	app.slock.Lock()
+	defer app.slock.Unlock()
+
+	s, ok := app.slaves[addr]
+	if !ok {
+		s = &slaveInfo{addr: addr, client: c}
+		app.slaves[addr] = s
+	}
+
+	s.logID = logID
+	s.lastContact = time.Now()
+}
+
+func (app *App) removeSlave(addr string) {
+	app.slock.Lock()
+	delete(app.slaves, addr)
+	app.slock.Unlock()
+}
+
+//returns the slave needing the oldest binlog, empty addr if no slave
+func (app *App) oldestSlave() (addr string, logID uint64) {
+	app.slock.Lock()
+	defer app.slock.Unlock()
+
+	for a, s := range app.slaves {
+		if len(addr) == 0 || s.logID < logID {
+			addr = a
+			logID = s.logID
+		}
+	}
+
+	return
+}
+
+//slaveList returns copies of the connected slaves sorted by address
+func (app *App) slaveList() []slaveInfo {
+	app.slock.Lock()
+	defer app.slock.Unlock()
+
+	addrs := make([]string, 0, len(app.slaves))
+	for addr := range app.slaves {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	ay := make([]slaveInfo, 0, len(addrs))
+	for _, addr := range addrs {
+		ay = append(ay, *app.slaves[addr])
+	}
+
+	return ay
+}
+
+//REPLICAS LIST
+//REPLICAS KILL addr
+func replicasCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "list":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		now := time.Now()
+
+		var buf bytes.Buffer
+		for _, s := range req.app.slaveList() {
+			buf.WriteString(strings.Join(s.fields(req.app, now), " "))
+			buf.WriteByte('\n')
+		}
+
+		req.resp.writeBulk(buf.Bytes())
+		return nil
+	case "kill":
+		if len(args) != 2 {
+			return ErrCmdParams
+		}
+
+		addr := ledis.String(args[1])
+
+		req.app.slock.Lock()
+		s, ok := req.app.slaves[addr]
+		delete(req.app.slaves, addr)
+		req.app.slock.Unlock()
+
+		if !ok {
+			return errNoSuchSlave
+		}
+
+		if s.client != nil {
+			s.client.kill(req)
+		}
+
+		req.resp.writeStatus(OK)
+		return nil
+	default:
+		return ErrSyntax
+	}
+}
+
 func init() {
-	register("slaveof", slaveofCommand)
-	register("fullsync", fullsyncCommand)
-	register("sync", syncCommand)
+	register("slaveof", slaveofCommand, cmdRead)
+	register("replicaof", slaveofCommand, cmdRead)
+	register("role", roleCommand, cmdRead)
+	register("fullsync", fullsyncCommand, cmdRead)
+	register("sync", syncCommand, cmdRead)
+	register("replicas", replicasCommand, cmdRead)
 }
@@ -3,9 +3,11 @@ package server
 import (
 	"bytes"
 	"fmt"
+	"github.com/siddontang/ledisdb/client/go/ledis"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store"
 	"os"
+	"strings"
 	"testing"
 	"time"
 )
@@ -26,6 +28,18 @@ func checkDataEqual(master *App, slave *App) error {
 	return nil
 }
 
+//waitDataEqual waits for slave to replicate, slave syncs every second when idle
+func waitDataEqual(master *App, slave *App) (err error) {
+	for i := 0; i < 30; i++ {
+		time.Sleep(100 * time.Millisecond)
+		if err = checkDataEqual(master, slave); err == nil {
+			return
+		}
+	}
+
+	return
+}
+
 func TestReplication(t *testing.T) {
 	data_dir := "/tmp/test_replication"
 	os.RemoveAll(data_dir)
@@ -48,6 +62,7 @@ func TestReplication(t *testing.T) {
 	slaveCfg.DataDir = fmt.Sprintf("%s/slave", data_dir)
 	slaveCfg.Addr = "127.0.0.1:11183"
 	slaveCfg.SlaveOf = masterCfg.Addr
+	slaveCfg.SlaveReadOnly = true
 
 	slave, err = NewApp(slaveCfg)
 	if err != nil {
@@ -73,13 +88,14 @@ func TestReplication(t *testing.T) {
 		t.Fatal(err)
 	}
 
+	checkReadOnlySlave(t, masterCfg.Addr, slaveCfg.Addr)
+
 	db.Set([]byte("a1"), value)
 	db.Set([]byte("b1"), value)
 	db.HSet([]byte("a1"), []byte("1"), value)
 	db.HSet([]byte("b1"), []byte("2"), value)
 
-	time.Sleep(1 * time.Second)
-	if err = checkDataEqual(master, slave); err != nil {
+	if err = waitDataEqual(master, slave); err != nil {
 		t.Fatal(err)
 	}
 
@@ -100,10 +116,243 @@ func TestReplication(t *testing.T) {
 	}
 
 	slave.slaveof(masterCfg.Addr)
-	time.Sleep(1 * time.Second)
 
-	if err = checkDataEqual(master, slave); err != nil {
+	if err = waitDataEqual(master, slave); err != nil {
+		t.Fatal(err)
+	}
+
+	checkMasterSlaves(t, masterCfg.Addr)
+}
+
+func checkReadOnlySlave(t *testing.T, masterAddr string, slaveAddr string) {
+	c := ledis.NewClient(&ledis.Config{Addr: slaveAddr, MaxIdleConns: 1}).Get()
+	defer c.Close()
+
+	if _, err := c.Do("set", "a", "1"); err == nil || !strings.Contains(err.Error(), "READONLY") {
+		t.Fatal(err)
+	} else if _, err = c.Do("get", "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ay, err := ledis.Values(c.Do("role")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 5 {
+		t.Fatal(ay)
+	} else if role, _ := ledis.String(ay[0], nil); role != "slave" {
+		t.Fatal(role)
+	} else if port, _ := ledis.Int(ay[2], nil); port != 11182 {
+		t.Fatal(port)
+	} else if state, _ := ledis.String(ay[3], nil); state != "connected" {
+		t.Fatal(state)
+	}
+
+	if ay, err := ledis.Values(c.Do("replicaof")); err != nil {
+		t.Fatal(err)
+	} else {
+		m := make(map[string]string)
+		for i := 0; i+1 < len(ay); i += 2 {
+			m[fmt.Sprintf("%s", ay[i])] = fmt.Sprintf("%s", ay[i+1])
+			if n, ok := ay[i+1].(int64); ok {
+				m[fmt.Sprintf("%s", ay[i])] = fmt.Sprint(n)
+			}
+		}
+
+		if m["master_addr"] != masterAddr || m["master_link_status"] != "connected" || m["read_only"] != "1" {
+			t.Fatal(m)
+		}
+	}
+
+	if _, err := c.Do("config", "set", "slave_read_only", "false"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("set", "slave_write", "1"); err != nil {
 		t.Fatal(err)
+	} else if _, err = c.Do("del", "slave_write"); err != nil {
+		t.Fatal(err)
+	} else if _, err = c.Do("config", "set", "slave_read_only", "true"); err != nil {
+		t.Fatal(err)
+	}
+
+	mc := ledis.NewClient(&ledis.Config{Addr: masterAddr, MaxIdleConns: 1}).Get()
+	defer mc.Close()
+
+	if ay, err := ledis.Values(mc.Do("role")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 3 {
+		t.Fatal(ay)
+	} else if role, _ := ledis.String(ay[0], nil); role != "master" {
+		t.Fatal(role)
+	} else if slaves, _ := ledis.Values(ay[2], nil); len(slaves) != 1 {
+		t.Fatal(slaves)
+	}
+}
+
+func checkMasterSlaves(t *testing.T, masterAddr string) {
+	c := ledis.NewClient(&ledis.Config{Addr: masterAddr, MaxIdleConns: 1}).Get()
+	defer c.Close()
+
+	//the slave requests the last log id in its next sync
+	var info string
+	var err error
+	for i := 0; i < 30; i++ {
+		if info, err = ledis.String(c.Do("info", "replication")); err != nil {
+			t.Fatal(err)
+		} else if strings.Contains(info, "connected_slaves:1") && strings.Contains(info, "lag_bytes=0,lag=0") {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if !strings.Contains(info, "role:master") || !strings.Contains(info, "connected_slaves:1") ||
+		!strings.Contains(info, "lag_bytes=0,lag=0") {
+		t.Fatal(info)
+	}
+
+	list, err := ledis.String(c.Do("replicas", "list"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Split(strings.TrimSpace(list), "\n")
+	if len(lines) != 1 || !strings.HasPrefix(lines[0], "addr=") {
+		t.Fatal(list)
 	}
 
+	addr := strings.TrimPrefix(strings.Fields(lines[0])[0], "addr=")
+
+	if _, err = c.Do("replicas", "kill", "127.0.0.1:1"); err == nil {
+		t.Fatal("must error")
+	} else if _, err = c.Do("replicas", "kill", addr); err != nil {
+		t.Fatal(err)
+	}
+
+	//empty list is a nil bulk
+	if list, err = ledis.String(c.Do("replicas", "list")); err != nil && err != ledis.ErrNil {
+		t.Fatal(err)
+	} else if strings.Contains(list, addr) {
+		t.Fatal(list)
+	}
+}
+
+func TestChainedReplication(t *testing.T) {
+	data_dir := "/tmp/test_chained_replication"
+	os.RemoveAll(data_dir)
+
+	var apps [3]*App
+	for i := range apps {
+		cfg := new(config.Config)
+		cfg.DataDir = fmt.Sprintf("%s/%d", data_dir, i)
+		cfg.Addr = fmt.Sprintf("127.0.0.1:%d", 11184+i)
+		cfg.BinLog.MaxFileSize = 1 * 1024 * 1024
+		cfg.BinLog.MaxFileNum = 10
+		if i > 0 {
+			cfg.SlaveOf = apps[i-1].cfg.Addr
+			cfg.SlaveReadOnly = true
+		}
+
+		var err error
+		if apps[i], err = NewApp(cfg); err != nil {
+			t.Fatal(err)
+		}
+		defer apps[i].Close()
+	}
+
+	master, slave, slave2 := apps[0], apps[1], apps[2]
+
+	//local writes of a slave with binlog would take the log ids of master
+	if err := setSlaveReadOnlyConfig(slave, "slave_read_only", "false"); err != errWritableSlave {
+		t.Fatal(err)
+	}
+
+	cfg := *slave.cfg
+	cfg.DataDir = fmt.Sprintf("%s/writable", data_dir)
+	cfg.Addr = "127.0.0.1:11189"
+	cfg.SlaveReadOnly = false
+	if _, err := NewApp(&cfg); err != errWritableSlave {
+		t.Fatal(err)
+	}
+
+	db, _ := master.ldb.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+	db.HSet([]byte("h"), []byte("f"), []byte("v"))
+
+	for _, app := range apps {
+		go app.Run()
+	}
+
+	if err := waitDataEqual(master, slave2); err != nil {
+		t.Fatal(err)
+	}
+
+	db.Set([]byte("b"), []byte("2"))
+	db.Del([]byte("a"))
+
+	if err := waitDataEqual(master, slave2); err != nil {
+		t.Fatal(err)
+	}
+
+	//the slave logs with the log ids of master
+	for i := 0; i < 30; i++ {
+		if slave2.ldb.BinLog().LastLogID() == master.ldb.BinLog().LastLogID() {
+			break
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	if id, masterID := slave.ldb.BinLog().LastLogID(), master.ldb.BinLog().LastLogID(); id != masterID {
+		t.Fatal(id, masterID)
+	} else if id = slave2.ldb.BinLog().LastLogID(); id != masterID {
+		t.Fatal(id, masterID)
+	}
+}
+
+func TestReplicationMasterNoBinLog(t *testing.T) {
+	data_dir := "/tmp/test_replication_nobinlog"
+	os.RemoveAll(data_dir)
+
+	masterCfg := new(config.Config)
+	masterCfg.DataDir = fmt.Sprintf("%s/master", data_dir)
+	masterCfg.Addr = "127.0.0.1:11187"
+
+	master, err := NewApp(masterCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer master.Close()
+
+	slaveCfg := new(config.Config)
+	slaveCfg.DataDir = fmt.Sprintf("%s/slave", data_dir)
+	slaveCfg.Addr = "127.0.0.1:11188"
+	slaveCfg.SlaveOf = masterCfg.Addr
+
+	slave, err := NewApp(slaveCfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	db, _ := master.ldb.Select(0)
+	db.Set([]byte("a"), []byte("1"))
+
+	go master.Run()
+	go slave.Run()
+
+	if err = waitDataEqual(master, slave); err != nil {
+		t.Fatal(err)
+	}
+
+	//the slave stops replication as master has no binlog
+	for i := 0; i < 30 && slave.m.stateName() != "connect"; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		slave.Close()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("close slave must not block")
+	}
 }
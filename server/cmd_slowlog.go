@@ -0,0 +1,172 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//max length of the command kept in slow log and access log
+const maxLogCommandLen = 256
+
+type slowLogEntry struct {
+	id       int64
+	time     int64
+	duration int64
+	cmd      []byte
+	addr     string
+}
+
+//slowLog keeps the latest slow commands in memory
+type slowLog struct {
+	sync.Mutex
+
+	//microseconds, read with atomic, < 0 disables
+	slowerThan int64
+
+	maxLen int
+
+	lastID  int64
+	entries []*slowLogEntry
+}
+
+func newSlowLog(cfg *config.Config) *slowLog {
+	s := new(slowLog)
+
+	s.slowerThan = cfg.SlowLogSlowerThan
+
+	s.maxLen = cfg.SlowLogMaxLen
+	if s.maxLen <= 0 {
+		s.maxLen = config.DefaultSlowLogMaxLen
+	}
+
+	s.entries = make([]*slowLogEntry, 0, s.maxLen)
+
+	return s
+}
+
+func (s *slowLog) isSlow(d time.Duration) bool {
+	slowerThan := atomic.LoadInt64(&s.slowerThan)
+	return slowerThan >= 0 && int64(d/time.Microsecond) >= slowerThan
+}
+
+//log saves a copy of cmd, the oldest entry is dropped if full
+func (s *slowLog) log(addr string, start time.Time, d time.Duration, cmd []byte) {
+	e := new(slowLogEntry)
+	e.time = start.Unix()
+	e.duration = int64(d / time.Microsecond)
+	e.cmd = append([]byte{}, cmd...)
+	e.addr = addr
+
+	s.Lock()
+	e.id = s.lastID
+	s.lastID++
+
+	if len(s.entries) >= s.maxLen {
+		n := copy(s.entries, s.entries[len(s.entries)-s.maxLen+1:])
+		s.entries = s.entries[0:n]
+	}
+	s.entries = append(s.entries, e)
+	s.Unlock()
+}
+
+func (s *slowLog) setMaxLen(n int) {
+	s.Lock()
+	defer s.Unlock()
+
+	s.maxLen = n
+	if len(s.entries) > n {
+		m := copy(s.entries, s.entries[len(s.entries)-n:])
+		s.entries = s.entries[0:m]
+	}
+}
+
+//get returns at most n newest entries, newest first, all if n < 0
+func (s *slowLog) get(n int) []*slowLogEntry {
+	s.Lock()
+	defer s.Unlock()
+
+	if n < 0 || n > len(s.entries) {
+		n = len(s.entries)
+	}
+
+	ay := make([]*slowLogEntry, 0, n)
+	for i := len(s.entries) - 1; i >= len(s.entries)-n; i-- {
+		ay = append(ay, s.entries[i])
+	}
+
+	return ay
+}
+
+func (s *slowLog) len() int {
+	s.Lock()
+	defer s.Unlock()
+
+	return len(s.entries)
+}
+
+func (s *slowLog) reset() {
+	s.Lock()
+	s.entries = s.entries[0:0]
+	s.Unlock()
+}
+
+//SLOWLOG GET [n]
+//SLOWLOG LEN
+//SLOWLOG RESET
+func slowlogCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	s := req.app.slowlog
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "get":
+		if len(args) > 2 {
+			return ErrCmdParams
+		}
+
+		n := 10
+		if len(args) == 2 {
+			v, err := ledis.StrInt64(args[1], nil)
+			if err != nil {
+				return ErrValue
+			}
+			n = int(v)
+		}
+
+		entries := s.get(n)
+		ay := make([]interface{}, 0, len(entries))
+		for _, e := range entries {
+			ay = append(ay, []interface{}{e.id, e.time, e.duration, []interface{}{e.cmd}, []byte(e.addr)})
+		}
+
+		req.resp.writeArray(ay)
+	case "len":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		req.resp.writeInteger(int64(s.len()))
+	case "reset":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		s.reset()
+		req.resp.writeStatus(OK)
+	default:
+		return ErrSyntax
+	}
+
+	return nil
+}
+
+func init() {
+	register("slowlog", slowlogCommand, cmdRead)
+}
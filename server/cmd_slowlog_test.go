@@ -0,0 +1,78 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSlowLog(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	//log every command
+	s := testApp.slowlog
+	old := atomic.LoadInt64(&s.slowerThan)
+	atomic.StoreInt64(&s.slowerThan, 0)
+	defer atomic.StoreInt64(&s.slowerThan, old)
+
+	if _, err := c.Do("slowlog", "reset"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("set", "slowlog_a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ledis.Int64(c.Do("slowlog", "len")); err != nil {
+		t.Fatal(err)
+	} else if n < 2 {
+		t.Fatal(n)
+	}
+
+	//newest first: slowlog len, set
+	ay, err := ledis.Values(c.Do("slowlog", "get", 2))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 {
+		t.Fatal(len(ay))
+	}
+
+	e, err := ledis.Values(ay[1], nil)
+	if err != nil {
+		t.Fatal(err)
+	} else if len(e) != 5 {
+		t.Fatal(len(e))
+	}
+
+	if cmd, err := ledis.Strings(e[3], nil); err != nil {
+		t.Fatal(err)
+	} else if cmd[0] != "set slowlog_a 1" {
+		t.Fatal(cmd)
+	}
+
+	if _, err := c.Do("slowlog", "unknown"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestSlowLogMaxLen(t *testing.T) {
+	s := new(slowLog)
+	s.maxLen = 2
+
+	for i := 0; i < 3; i++ {
+		s.log("", time.Now(), 0, []byte{byte('a' + i)})
+	}
+
+	ay := s.get(-1)
+	if len(ay) != 2 {
+		t.Fatal(len(ay))
+	} else if string(ay[0].cmd) != "c" || string(ay[1].cmd) != "b" {
+		t.Fatal(string(ay[0].cmd), string(ay[1].cmd))
+	}
+
+	if ay[0].id != 2 {
+		t.Fatal(ay[0].id)
+	}
+}
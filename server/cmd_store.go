@@ -0,0 +1,47 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/ledis"
+	"strings"
+)
+
+func storeCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "caps":
+		return storeCapsCommand(req)
+	default:
+		return ErrSyntax
+	}
+}
+
+//STORE CAPS
+func storeCapsCommand(req *requestContext) error {
+	if len(req.args) != 1 {
+		return ErrCmdParams
+	}
+
+	caps := req.app.ldb.DataDB().Capabilities()
+
+	ay := []interface{}{
+		[]byte("name"), []byte(req.app.cfg.DBName),
+		[]byte("tx"), caps.Tx,
+		[]byte("snapshot"), caps.Snapshot,
+		[]byte("cheap_reverse_iteration"), caps.CheapReverseIteration,
+		[]byte("compaction"), caps.Compaction,
+		[]byte("approximate_size"), caps.ApproximateSize,
+		[]byte("native_backup"), caps.NativeBackup,
+		[]byte("sync"), caps.Sync,
+	}
+
+	req.resp.writeMap(ay)
+	return nil
+}
+
+func init() {
+	register("store", storeCommand, cmdRead)
+}
@@ -521,27 +521,27 @@ func zpersistCommand(req *requestContext) error {
 }
 
 func init() {
-	register("zadd", zaddCommand)
-	register("zcard", zcardCommand)
-	register("zcount", zcountCommand)
-	register("zincrby", zincrbyCommand)
-	register("zrange", zrangeCommand)
-	register("zrangebyscore", zrangebyscoreCommand)
-	register("zrank", zrankCommand)
-	register("zrem", zremCommand)
-	register("zremrangebyrank", zremrangebyrankCommand)
-	register("zremrangebyscore", zremrangebyscoreCommand)
-	register("zrevrange", zrevrangeCommand)
-	register("zrevrank", zrevrankCommand)
-	register("zrevrangebyscore", zrevrangebyscoreCommand)
-	register("zscore", zscoreCommand)
+	register("zadd", zaddCommand, cmdWrite)
+	register("zcard", zcardCommand, cmdRead)
+	register("zcount", zcountCommand, cmdRead)
+	register("zincrby", zincrbyCommand, cmdWrite)
+	register("zrange", zrangeCommand, cmdRead)
+	register("zrangebyscore", zrangebyscoreCommand, cmdRead)
+	register("zrank", zrankCommand, cmdRead)
+	register("zrem", zremCommand, cmdWrite)
+	register("zremrangebyrank", zremrangebyrankCommand, cmdWrite)
+	register("zremrangebyscore", zremrangebyscoreCommand, cmdWrite)
+	register("zrevrange", zrevrangeCommand, cmdRead)
+	register("zrevrank", zrevrankCommand, cmdRead)
+	register("zrevrangebyscore", zrevrangebyscoreCommand, cmdRead)
+	register("zscore", zscoreCommand, cmdRead)
 
 	//ledisdb special command
 
-	register("zclear", zclearCommand)
-	register("zmclear", zmclearCommand)
-	register("zexpire", zexpireCommand)
-	register("zexpireat", zexpireAtCommand)
-	register("zttl", zttlCommand)
-	register("zpersist", zpersistCommand)
+	register("zclear", zclearCommand, cmdWrite)
+	register("zmclear", zmclearCommand, cmdWrite)
+	register("zexpire", zexpireCommand, cmdWrite)
+	register("zexpireat", zexpireAtCommand, cmdWrite)
+	register("zttl", zttlCommand, cmdRead)
+	register("zpersist", zpersistCommand, cmdWrite)
 }
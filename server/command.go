@@ -10,17 +10,37 @@ import (
 
 type CommandFunc func(req *requestContext) error
 
-var regCmds = map[string]CommandFunc{}
+type cmdFlag int
 
-func register(name string, f CommandFunc) {
+const (
+	//reads data or changes server state only
+	cmdRead cmdFlag = iota
+	//writes data, rejected by a read only slave
+	cmdWrite
+)
+
+type command struct {
+	f    CommandFunc
+	flag cmdFlag
+}
+
+var regCmds = map[string]*command{}
+
+func register(name string, f CommandFunc, flag cmdFlag) {
 	if _, ok := regCmds[strings.ToLower(name)]; ok {
 		panic(fmt.Sprintf("%s has been registered", name))
 	}
 
-	regCmds[name] = f
+	regCmds[name] = &command{f, flag}
 }
 
 func pingCommand(req *requestContext) error {
+	//a RESP2 client with subscriptions can only get arrays
+	if c := req.client; c != nil && c.sub != nil && c.sub.count() > 0 && c.resp.proto == 2 {
+		req.resp.writeArray([]interface{}{[]byte("pong"), []byte{}})
+		return nil
+	}
+
 	req.resp.writeStatus(PONG)
 	return nil
 }
@@ -52,8 +72,61 @@ func selectCommand(req *requestContext) error {
 	return nil
 }
 
+//COMPACT [ALL|KV|HASH|LIST|ZSET|BIT]
+//compacts current db without argument, all dbs with ALL, or one data type of current db
+func compactCommand(req *requestContext) error {
+	args := req.args
+	if len(args) > 1 {
+		return ErrCmdParams
+	}
+
+	var err error
+	if len(args) == 0 {
+		err = req.db.Compact()
+	} else if t := strings.ToLower(ledis.String(args[0])); t == "all" {
+		err = req.ldb.Compact()
+	} else {
+		err = req.db.CompactType(t)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	req.resp.writeStatus(OK)
+	return nil
+}
+
+//SHUTDOWN [NOSAVE|SAVE]
+//replies OK, then shuts down after running requests finish, saves a backup if SAVE
+func shutdownCommand(req *requestContext) error {
+	args := req.args
+	if len(args) > 1 {
+		return ErrCmdParams
+	}
+
+	save := false
+	if len(args) == 1 {
+		switch strings.ToLower(ledis.String(args[0])) {
+		case "save":
+			save = true
+		case "nosave":
+		default:
+			return ErrSyntax
+		}
+	}
+
+	req.resp.writeStatus(OK)
+
+	//shutdown waits this request, so must not block it
+	go req.app.shutdown(save)
+	return nil
+}
+
 func init() {
-	register("ping", pingCommand)
-	register("echo", echoCommand)
-	register("select", selectCommand)
+	register("ping", pingCommand, cmdRead)
+	register("echo", echoCommand, cmdRead)
+	register("select", selectCommand, cmdRead)
+	register("compact", compactCommand, cmdRead)
+	register("shutdown", shutdownCommand, cmdRead)
 }
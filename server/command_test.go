@@ -0,0 +1,110 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"strings"
+	"testing"
+)
+
+func TestCompactCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("set", "compact_a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("compact"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("compact", "kv"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("compact", "all"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("compact", "unknown"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if info, err := ledis.String(c.Do("info", "disk")); err != nil {
+		t.Fatal(err)
+	} else if !strings.Contains(info, "store_disk_size:") {
+		t.Fatal(info)
+	}
+}
+
+func TestStoreCapsCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	ay, err := ledis.Values(c.Do("store", "caps"))
+	if err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 16 {
+		t.Fatal(len(ay))
+	}
+
+	if name, err := ledis.String(ay[1], nil); err != nil {
+		t.Fatal(err)
+	} else if name != "goleveldb" {
+		t.Fatal(name)
+	}
+
+	if compaction, err := ledis.Int64(ay[9], nil); err != nil {
+		t.Fatal(err)
+	} else if compaction != 1 {
+		t.Fatal(compaction)
+	}
+
+	if _, err := c.Do("store", "unknown"); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestPipeline(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("del", "pipeline_a"); err != nil {
+		t.Fatal(err)
+	}
+
+	//errors are replied in order too
+	n := 1000
+	for i := 0; i < n; i++ {
+		if i == n/2 {
+			c.Send("pipeline_no_such_cmd")
+		}
+		if err := c.Send("incr", "pipeline_a"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := c.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= n; i++ {
+		if i == n/2+1 {
+			if _, err := c.Receive(); err == nil {
+				t.Fatal("must error")
+			}
+		}
+
+		if v, err := ledis.Int64(c.Receive()); err != nil {
+			t.Fatal(err)
+		} else if v != int64(i) {
+			t.Fatal(v, i)
+		}
+	}
+
+	if v, err := ledis.Int64(c.Do("incr", "pipeline_a")); err != nil {
+		t.Fatal(err)
+	} else if v != int64(n+1) {
+		t.Fatal(v)
+	}
+}
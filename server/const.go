@@ -20,6 +20,9 @@ var (
 	NullBulk  = []byte("-1")
 	NullArray = []byte("-1")
 
+	//RESP3 null
+	Null = []byte("_")
+
 	PONG = "PONG"
 	OK   = "OK"
 )
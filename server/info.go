@@ -0,0 +1,198 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/siddontang/ledisdb/ledis"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type infoSection struct {
+	name string
+	gen  func(app *App, buf *bytes.Buffer)
+}
+
+//output order of sections in INFO
+var infoSections = []infoSection{
+	{"server", infoServer},
+	{"clients", infoClients},
+	{"persistence", infoPersistence},
+	{"replication", infoReplication},
+	{"binlog", infoBinLog},
+	{"disk", infoDisk},
+}
+
+func writeInfoPair(buf *bytes.Buffer, key string, value interface{}) {
+	buf.WriteString(fmt.Sprintf("%s:%v\r\n", key, value))
+}
+
+func infoServer(app *App, buf *bytes.Buffer) {
+	writeInfoPair(buf, "os", runtime.GOOS)
+	writeInfoPair(buf, "process_id", os.Getpid())
+	writeInfoPair(buf, "addr", app.cfg.Addr)
+	writeInfoPair(buf, "http_addr", app.cfg.HttpAddr)
+	writeInfoPair(buf, "data_dir", app.cfg.DataDir)
+	writeInfoPair(buf, "db_name", app.cfg.DBName)
+	writeInfoPair(buf, "goroutine_num", runtime.NumGoroutine())
+}
+
+func infoClients(app *App, buf *bytes.Buffer) {
+	writeInfoPair(buf, "connected_clients", app.clients.len())
+	writeInfoPair(buf, "maxclients", atomic.LoadInt64(&app.clients.maxClients))
+	writeInfoPair(buf, "timeout", atomic.LoadInt64(&app.clients.timeout))
+}
+
+func infoPersistence(app *App, buf *bytes.Buffer) {
+	s := &app.backup
+
+	s.Lock()
+	defer s.Unlock()
+
+	if s.inProgress {
+		writeInfoPair(buf, "backup_in_progress", 1)
+		writeInfoPair(buf, "backup_dir", s.dir)
+		writeInfoPair(buf, "backup_started_time", s.started)
+		writeInfoPair(buf, "backup_written_bytes", atomic.LoadInt64(&s.written))
+	} else {
+		writeInfoPair(buf, "backup_in_progress", 0)
+	}
+
+	if s.lastTime > 0 {
+		writeInfoPair(buf, "last_backup_status", s.lastStatus)
+		writeInfoPair(buf, "last_backup_time", s.lastTime)
+		writeInfoPair(buf, "last_backup_log_id", s.lastLogID)
+		writeInfoPair(buf, "last_backup_native", s.lastNative)
+	}
+}
+
+func infoReplication(app *App, buf *bytes.Buffer) {
+	app.cfgLock.Lock()
+	masterAddr := app.cfg.SlaveOf
+	app.cfgLock.Unlock()
+
+	if len(masterAddr) > 0 {
+		writeInfoPair(buf, "role", "slave")
+		writeInfoPair(buf, "master_addr", masterAddr)
+		writeInfoPair(buf, "master_link_status", app.m.stateName())
+		writeInfoPair(buf, "log_id", atomic.LoadUint64(&app.m.syncedLogID))
+		writeInfoPair(buf, "master_log_id", atomic.LoadUint64(&app.m.masterLogID))
+		if app.isReadOnly() {
+			writeInfoPair(buf, "read_only", 1)
+		} else {
+			writeInfoPair(buf, "read_only", 0)
+		}
+	} else {
+		writeInfoPair(buf, "role", "master")
+	}
+
+	now := time.Now()
+
+	slaves := app.slaveList()
+	writeInfoPair(buf, "connected_slaves", len(slaves))
+	for i, s := range slaves {
+		writeInfoPair(buf, fmt.Sprintf("slave%d", i), strings.Join(s.fields(app, now), ","))
+	}
+}
+
+func infoBinLog(app *App, buf *bytes.Buffer) {
+	b := app.ldb.BinLog()
+	if b == nil {
+		writeInfoPair(buf, "binlog_enabled", 0)
+		return
+	}
+
+	writeInfoPair(buf, "binlog_enabled", 1)
+	writeInfoPair(buf, "log_file_index", b.LogFileIndex())
+	writeInfoPair(buf, "log_file_pos", b.LogFilePos())
+	writeInfoPair(buf, "first_log_id", b.FirstLogID())
+	writeInfoPair(buf, "last_log_id", b.LastLogID())
+}
+
+//dirSize returns the total size of files in dir
+func dirSize(dir string) int64 {
+	var size int64
+	filepath.Walk(dir, func(_ string, f os.FileInfo, err error) error {
+		if err == nil && !f.IsDir() {
+			size += f.Size()
+		}
+		return nil
+	})
+	return size
+}
+
+func infoDisk(app *App, buf *bytes.Buffer) {
+	writeInfoPair(buf, "store_disk_size", dirSize(path.Join(app.cfg.DataDir, fmt.Sprintf("%s_data", app.cfg.DBName))))
+
+	if b := app.ldb.BinLog(); b != nil {
+		writeInfoPair(buf, "binlog_disk_size", dirSize(b.LogPath()))
+	}
+
+	//estimate of each db, only if store supports
+	for i := 0; i < int(ledis.MaxDBNumber); i++ {
+		db, _ := app.ldb.Select(i)
+		if size, err := db.DiskSize(); err != nil {
+			break
+		} else if size > 0 {
+			writeInfoPair(buf, fmt.Sprintf("db%d_disk_size", i), size)
+		}
+	}
+}
+
+func (app *App) info(section string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	section = strings.ToLower(section)
+	found := false
+
+	for _, s := range infoSections {
+		if len(section) > 0 && section != "all" && section != s.name {
+			continue
+		}
+
+		found = true
+
+		if buf.Len() > 0 {
+			buf.WriteString("\r\n")
+		}
+
+		buf.WriteString(fmt.Sprintf("# %s\r\n", strings.Title(s.name)))
+		s.gen(app, &buf)
+	}
+
+	if !found {
+		return nil, ErrSyntax
+	}
+
+	return buf.Bytes(), nil
+}
+
+//INFO [section]
+func infoCommand(req *requestContext) error {
+	args := req.args
+	if len(args) > 1 {
+		return ErrCmdParams
+	}
+
+	var section string
+	if len(args) == 1 {
+		section = ledis.String(args[0])
+	}
+
+	data, err := req.app.info(section)
+	if err != nil {
+		return err
+	}
+
+	req.resp.writeBulk(data)
+	return nil
+}
+
+func init() {
+	register("info", infoCommand, cmdRead)
+}
@@ -0,0 +1,230 @@
+package server
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/siddontang/ledisdb/ledis"
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+//upper bounds of command latency histogram, in seconds
+var latencyBuckets = []float64{0.0001, 0.0005, 0.001, 0.005, 0.01, 0.05, 0.1, 0.5, 1}
+
+type cmdStat struct {
+	calls  int64
+	errors int64
+
+	//microseconds
+	duration int64
+
+	//count of each latency bucket, the last is +Inf
+	buckets []int64
+}
+
+//metrics is updated with atomic, so requests never wait for it
+type metrics struct {
+	//all registered commands, no command is added after app starts
+	cmds map[string]*cmdStat
+
+	connectedClients int64
+	totalConnections int64
+}
+
+func newMetrics() *metrics {
+	m := new(metrics)
+
+	m.cmds = make(map[string]*cmdStat, len(regCmds))
+	for name := range regCmds {
+		m.cmds[name] = &cmdStat{buckets: make([]int64, len(latencyBuckets)+1)}
+	}
+
+	return m
+}
+
+func (m *metrics) observe(cmd string, d time.Duration, err error) {
+	s, ok := m.cmds[cmd]
+	if !ok {
+		return
+	}
+
+	atomic.AddInt64(&s.calls, 1)
+	if err != nil {
+		atomic.AddInt64(&s.errors, 1)
+	}
+
+	atomic.AddInt64(&s.duration, int64(d/time.Microsecond))
+
+	i := sort.SearchFloat64s(latencyBuckets, d.Seconds())
+	atomic.AddInt64(&s.buckets[i], 1)
+}
+
+func (m *metrics) connect() {
+	atomic.AddInt64(&m.connectedClients, 1)
+	atomic.AddInt64(&m.totalConnections, 1)
+}
+
+func (m *metrics) disconnect() {
+	atomic.AddInt64(&m.connectedClients, -1)
+}
+
+type metricsWriter struct {
+	buf bytes.Buffer
+}
+
+func (w *metricsWriter) head(name string, typ string, help string) {
+	fmt.Fprintf(&w.buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+}
+
+func (w *metricsWriter) value(name string, labels string, v interface{}) {
+	if len(labels) > 0 {
+		fmt.Fprintf(&w.buf, "%s{%s} %v\n", name, labels, v)
+	} else {
+		fmt.Fprintf(&w.buf, "%s %v\n", name, v)
+	}
+}
+
+func (w *metricsWriter) single(name string, typ string, help string, v interface{}) {
+	w.head(name, typ, help)
+	w.value(name, "", v)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+func (app *App) writeCmdMetrics(w *metricsWriter) {
+	m := app.stat
+
+	names := make([]string, 0, len(m.cmds))
+	for name, s := range m.cmds {
+		if atomic.LoadInt64(&s.calls) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	w.head("ledis_commands_total", "counter", "Number of processed commands.")
+	for _, name := range names {
+		w.value("ledis_commands_total", fmt.Sprintf("cmd=%q", name), atomic.LoadInt64(&m.cmds[name].calls))
+	}
+
+	w.head("ledis_command_errors_total", "counter", "Number of commands returning an error.")
+	for _, name := range names {
+		w.value("ledis_command_errors_total", fmt.Sprintf("cmd=%q", name), atomic.LoadInt64(&m.cmds[name].errors))
+	}
+
+	w.head("ledis_command_duration_seconds", "histogram", "Latency of processed commands.")
+	for _, name := range names {
+		s := m.cmds[name]
+
+		var count int64
+		for i, le := range latencyBuckets {
+			count += atomic.LoadInt64(&s.buckets[i])
+			w.value("ledis_command_duration_seconds_bucket", fmt.Sprintf("cmd=%q,le=%q", name, formatFloat(le)), count)
+		}
+		count += atomic.LoadInt64(&s.buckets[len(latencyBuckets)])
+		w.value("ledis_command_duration_seconds_bucket", fmt.Sprintf("cmd=%q,le=\"+Inf\"", name), count)
+
+		sum := float64(atomic.LoadInt64(&s.duration)) / 1e6
+		w.value("ledis_command_duration_seconds_sum", fmt.Sprintf("cmd=%q", name), formatFloat(sum))
+		w.value("ledis_command_duration_seconds_count", fmt.Sprintf("cmd=%q", name), count)
+	}
+}
+
+func (app *App) writeReplMetrics(w *metricsWriter) {
+	b := app.ldb.BinLog()
+
+	if b != nil {
+		w.single("ledis_binlog_written_bytes_total", "counter", "Bytes of binlog events written since start.", b.WrittenBytes())
+		w.single("ledis_binlog_last_log_id", "gauge", "Log id of the newest binlog batch.", b.LastLogID())
+
+		lastID := b.LastLogID()
+
+		slaves := app.slaveList()
+
+		w.head("ledis_slave_log_id", "gauge", "Log id each connected slave last requested.")
+		for _, s := range slaves {
+			w.value("ledis_slave_log_id", fmt.Sprintf("addr=%q", s.addr), s.logID)
+		}
+
+		w.head("ledis_slave_lag_batches", "gauge", "Binlog batches each connected slave is behind.")
+		for _, s := range slaves {
+			var lag uint64
+			if lastID > s.logID {
+				lag = lastID - s.logID
+			}
+			w.value("ledis_slave_lag_batches", fmt.Sprintf("addr=%q", s.addr), lag)
+		}
+	}
+
+	//as a slave
+	masterID := atomic.LoadUint64(&app.m.masterLogID)
+	if masterID > 0 {
+		syncedID := atomic.LoadUint64(&app.m.syncedLogID)
+
+		var lag uint64
+		if masterID > syncedID {
+			lag = masterID - syncedID
+		}
+
+		w.single("ledis_replication_log_id", "gauge", "Log id of the last batch replicated from master.", syncedID)
+		w.single("ledis_replication_master_log_id", "gauge", "Newest log id of master seen in the last sync.", masterID)
+		w.single("ledis_replication_lag_batches", "gauge", "Binlog batches behind master in the last sync.", lag)
+	}
+}
+
+func (app *App) writeLedisMetrics(w *metricsWriter) {
+	st := app.ldb.ExpireStat()
+
+	w.head("ledis_expire_cycle_duration_seconds", "summary", "Duration of active expire cycles.")
+	w.value("ledis_expire_cycle_duration_seconds_sum", "", formatFloat(st.Duration.Seconds()))
+	w.value("ledis_expire_cycle_duration_seconds_count", "", st.Cycles)
+	w.single("ledis_expire_cycle_last_duration_seconds", "gauge", "Duration of the last active expire cycle.", formatFloat(st.LastDuration.Seconds()))
+	w.single("ledis_expired_keys_total", "counter", "Number of keys removed by active expire cycles.", st.Expired)
+
+	w.single("ledis_store_disk_bytes", "gauge", "Size of store files.",
+		dirSize(path.Join(app.cfg.DataDir, fmt.Sprintf("%s_data", app.cfg.DBName))))
+
+	if b := app.ldb.BinLog(); b != nil {
+		w.single("ledis_binlog_disk_bytes", "gauge", "Size of binlog files.", dirSize(b.LogPath()))
+	}
+
+	//only if store supports
+	if !app.ldb.DataDB().Capabilities().ApproximateSize {
+		return
+	}
+
+	w.head("ledis_db_disk_bytes", "gauge", "Approximate size of each db in store.")
+	for i := 0; i < int(ledis.MaxDBNumber); i++ {
+		db, _ := app.ldb.Select(i)
+		if size, err := db.DiskSize(); err == nil {
+			w.value("ledis_db_disk_bytes", fmt.Sprintf("db=\"%d\"", i), size)
+		}
+	}
+}
+
+func (app *App) writeMetrics(wr io.Writer) error {
+	w := new(metricsWriter)
+
+	app.writeCmdMetrics(w)
+
+	w.single("ledis_connected_clients", "gauge", "Number of connected clients.", atomic.LoadInt64(&app.stat.connectedClients))
+	w.single("ledis_connections_total", "counter", "Number of accepted connections.", atomic.LoadInt64(&app.stat.totalConnections))
+
+	app.writeReplMetrics(w)
+	app.writeLedisMetrics(w)
+
+	_, err := wr.Write(w.buf.Bytes())
+	return err
+}
+
+func (app *App) metricsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	app.writeMetrics(w)
+}
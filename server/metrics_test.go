@@ -0,0 +1,38 @@
+package server
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestMetrics(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("set", "metrics_a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("incr", "metrics_a", "2"); err == nil {
+		t.Fatal("must error")
+	}
+
+	w := httptest.NewRecorder()
+	testApp.metricsHandler(w, nil)
+
+	s := w.Body.String()
+	for _, line := range []string{
+		`ledis_commands_total{cmd="set"} `,
+		`ledis_command_errors_total{cmd="incr"} `,
+		`ledis_command_duration_seconds_bucket{cmd="set",le="+Inf"} `,
+		`ledis_command_duration_seconds_count{cmd="set"} `,
+		`ledis_connected_clients `,
+		`ledis_expire_cycle_duration_seconds_count `,
+		`ledis_store_disk_bytes `,
+	} {
+		if !strings.Contains(s, line) {
+			t.Fatal(line)
+		}
+	}
+}
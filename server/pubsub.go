@@ -0,0 +1,535 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/siddontang/ledisdb/ledis"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//max messages waiting to be written to a subscriber, a slower one is dropped
+const maxPendingMessages = 1024
+
+type subscriber struct {
+	//push messages, message or pmessage
+	msgs chan []interface{}
+
+	//closed when the subscriber can not keep up
+	dropped  chan struct{}
+	dropOnce sync.Once
+
+	//only used by the goroutine owning the subscriber
+	channels map[string]struct{}
+	patterns map[string]struct{}
+}
+
+func newSubscriber() *subscriber {
+	s := new(subscriber)
+
+	s.msgs = make(chan []interface{}, maxPendingMessages)
+	s.dropped = make(chan struct{})
+
+	s.channels = make(map[string]struct{})
+	s.patterns = make(map[string]struct{})
+
+	return s
+}
+
+func (s *subscriber) send(msg []interface{}) {
+	select {
+	case s.msgs <- msg:
+	default:
+		s.dropOnce.Do(func() { close(s.dropped) })
+	}
+}
+
+func (s *subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+
+//pubsub delivers published messages to subscribers of channels and patterns
+type pubsub struct {
+	sync.RWMutex
+
+	channels map[string]map[*subscriber]struct{}
+	patterns map[string]map[*subscriber]struct{}
+}
+
+func newPubSub() *pubsub {
+	p := new(pubsub)
+
+	p.channels = make(map[string]map[*subscriber]struct{})
+	p.patterns = make(map[string]map[*subscriber]struct{})
+
+	return p
+}
+
+func addSubscriber(m map[string]map[*subscriber]struct{}, name string, s *subscriber) {
+	subs, ok := m[name]
+	if !ok {
+		subs = make(map[*subscriber]struct{})
+		m[name] = subs
+	}
+	subs[s] = struct{}{}
+}
+
+func removeSubscriber(m map[string]map[*subscriber]struct{}, name string, s *subscriber) {
+	if subs, ok := m[name]; ok {
+		delete(subs, s)
+		if len(subs) == 0 {
+			delete(m, name)
+		}
+	}
+}
+
+func (p *pubsub) subscribe(s *subscriber, channel string) {
+	p.Lock()
+	addSubscriber(p.channels, channel, s)
+	p.Unlock()
+
+	s.channels[channel] = struct{}{}
+}
+
+func (p *pubsub) unsubscribe(s *subscriber, channel string) {
+	p.Lock()
+	removeSubscriber(p.channels, channel, s)
+	p.Unlock()
+
+	delete(s.channels, channel)
+}
+
+func (p *pubsub) psubscribe(s *subscriber, pattern string) {
+	p.Lock()
+	addSubscriber(p.patterns, pattern, s)
+	p.Unlock()
+
+	s.patterns[pattern] = struct{}{}
+}
+
+func (p *pubsub) punsubscribe(s *subscriber, pattern string) {
+	p.Lock()
+	removeSubscriber(p.patterns, pattern, s)
+	p.Unlock()
+
+	delete(s.patterns, pattern)
+}
+
+func (p *pubsub) unsubscribeAll(s *subscriber) {
+	p.Lock()
+	for channel := range s.channels {
+		removeSubscriber(p.channels, channel, s)
+	}
+	for pattern := range s.patterns {
+		removeSubscriber(p.patterns, pattern, s)
+	}
+	p.Unlock()
+
+	s.channels = make(map[string]struct{})
+	s.patterns = make(map[string]struct{})
+}
+
+//publish returns the number of subscribers receiving the message
+func (p *pubsub) publish(channel []byte, msg []byte) int64 {
+	p.RLock()
+	defer p.RUnlock()
+
+	var n int64
+
+	if subs, ok := p.channels[ledis.String(channel)]; ok {
+		m := []interface{}{[]byte("message"), channel, msg}
+		for s := range subs {
+			s.send(m)
+			n++
+		}
+	}
+
+	for pattern, subs := range p.patterns {
+		if !globMatch(pattern, ledis.String(channel)) {
+			continue
+		}
+
+		m := []interface{}{[]byte("pmessage"), []byte(pattern), channel, msg}
+		for s := range subs {
+			s.send(m)
+			n++
+		}
+	}
+
+	return n
+}
+
+//publishKeyEvent is the notifier of ledis, publishes the event to the keyspace channel of the key
+//and the keyevent channel of the event
+func (p *pubsub) publishKeyEvent(e *ledis.KeyEvent) {
+	if e.Flags&ledis.NotifyKeyspace != 0 {
+		p.publish([]byte(fmt.Sprintf("__keyspace@%d__:%s", e.DB, e.Key)), []byte(e.Event))
+	}
+
+	if e.Flags&ledis.NotifyKeyevent != 0 {
+		p.publish([]byte(fmt.Sprintf("__keyevent@%d__:%s", e.DB, e.Event)), e.Key)
+	}
+}
+
+//setNotifyFlags applies the notify config to all dbs
+func (app *App) setNotifyFlags(value string) error {
+	ay, err := ledis.ParseNotifyConfig(value)
+	if err != nil {
+		return err
+	}
+
+	for index, flags := range ay {
+		app.ldb.SetNotifyFlags(index, flags)
+	}
+	return nil
+}
+
+//activeChannels returns channels having subscribers matching pattern, all if empty
+func (p *pubsub) activeChannels(pattern string) []string {
+	p.RLock()
+	defer p.RUnlock()
+
+	ay := make([]string, 0, len(p.channels))
+	for channel := range p.channels {
+		if len(pattern) == 0 || globMatch(pattern, channel) {
+			ay = append(ay, channel)
+		}
+	}
+	sort.Strings(ay)
+
+	return ay
+}
+
+func (p *pubsub) numSub(channel string) int64 {
+	p.RLock()
+	defer p.RUnlock()
+
+	return int64(len(p.channels[channel]))
+}
+
+func (p *pubsub) numPat() int64 {
+	p.RLock()
+	defer p.RUnlock()
+
+	var n int64
+	for _, subs := range p.patterns {
+		n += int64(len(subs))
+	}
+	return n
+}
+
+//globMatch matches s with a glob pattern supporting *, ?, [...] and \ escape
+func globMatch(pattern string, s string) bool {
+	for len(pattern) > 0 {
+		switch pattern[0] {
+		case '*':
+			for len(pattern) > 1 && pattern[1] == '*' {
+				pattern = pattern[1:]
+			}
+			if len(pattern) == 1 {
+				return true
+			}
+			for i := 0; i <= len(s); i++ {
+				if globMatch(pattern[1:], s[i:]) {
+					return true
+				}
+			}
+			return false
+		case '?':
+			if len(s) == 0 {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		case '[':
+			if len(s) == 0 {
+				return false
+			}
+
+			end := strings.IndexByte(pattern[1:], ']')
+			if end < 0 {
+				//no closing bracket, match [ literally
+				if s[0] != '[' {
+					return false
+				}
+				s = s[1:]
+				pattern = pattern[1:]
+				continue
+			}
+
+			class := pattern[1 : end+1]
+			not := len(class) > 0 && class[0] == '^'
+			if not {
+				class = class[1:]
+			}
+
+			matched := false
+			for i := 0; i < len(class); i++ {
+				if i+2 < len(class) && class[i+1] == '-' {
+					if class[i] <= s[0] && s[0] <= class[i+2] {
+						matched = true
+					}
+					i += 2
+				} else if class[i] == s[0] {
+					matched = true
+				}
+			}
+
+			if matched == not {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[end+2:]
+		default:
+			if pattern[0] == '\\' && len(pattern) > 1 {
+				pattern = pattern[1:]
+			}
+			if len(s) == 0 || s[0] != pattern[0] {
+				return false
+			}
+			s = s[1:]
+			pattern = pattern[1:]
+		}
+	}
+
+	return len(s) == 0
+}
+
+//commands allowed for a RESP2 client with subscriptions
+var subscribedCommands = map[string]struct{}{
+	"subscribe":    struct{}{},
+	"unsubscribe":  struct{}{},
+	"psubscribe":   struct{}{},
+	"punsubscribe": struct{}{},
+	"ping":         struct{}{},
+	"quit":         struct{}{},
+}
+
+//subscriberOf returns the subscriber of the client of req, creates it if not exists,
+//the client serves pushed messages after the request
+func subscriberOf(req *requestContext) *subscriber {
+	c := req.client
+	if c.sub == nil {
+		c.sub = newSubscriber()
+	}
+	return c.sub
+}
+
+func writeSubscribeReply(req *requestContext, kind string, name []byte, count int) {
+	req.resp.writePush([]interface{}{[]byte(kind), name, int64(count)})
+}
+
+//SUBSCRIBE channel [channel ...]
+func subscribeCommand(req *requestContext) error {
+	if len(req.args) == 0 {
+		return ErrCmdParams
+	}
+
+	s := subscriberOf(req)
+	for _, channel := range req.args {
+		req.app.pubsub.subscribe(s, string(channel))
+		writeSubscribeReply(req, "subscribe", channel, s.count())
+	}
+
+	return nil
+}
+
+//PSUBSCRIBE pattern [pattern ...]
+func psubscribeCommand(req *requestContext) error {
+	if len(req.args) == 0 {
+		return ErrCmdParams
+	}
+
+	s := subscriberOf(req)
+	for _, pattern := range req.args {
+		req.app.pubsub.psubscribe(s, string(pattern))
+		writeSubscribeReply(req, "psubscribe", pattern, s.count())
+	}
+
+	return nil
+}
+
+//UNSUBSCRIBE [channel ...], all channels if no channel
+func unsubscribeCommand(req *requestContext) error {
+	s := subscriberOf(req)
+
+	channels := req.args
+	if len(channels) == 0 {
+		for channel := range s.channels {
+			channels = append(channels, []byte(channel))
+		}
+	}
+
+	if len(channels) == 0 {
+		writeSubscribeReply(req, "unsubscribe", nil, s.count())
+		return nil
+	}
+
+	for _, channel := range channels {
+		req.app.pubsub.unsubscribe(s, string(channel))
+		writeSubscribeReply(req, "unsubscribe", channel, s.count())
+	}
+
+	return nil
+}
+
+//PUNSUBSCRIBE [pattern ...], all patterns if no pattern
+func punsubscribeCommand(req *requestContext) error {
+	s := subscriberOf(req)
+
+	patterns := req.args
+	if len(patterns) == 0 {
+		for pattern := range s.patterns {
+			patterns = append(patterns, []byte(pattern))
+		}
+	}
+
+	if len(patterns) == 0 {
+		writeSubscribeReply(req, "punsubscribe", nil, s.count())
+		return nil
+	}
+
+	for _, pattern := range patterns {
+		req.app.pubsub.punsubscribe(s, string(pattern))
+		writeSubscribeReply(req, "punsubscribe", pattern, s.count())
+	}
+
+	return nil
+}
+
+//PUBLISH channel message
+func publishCommand(req *requestContext) error {
+	if len(req.args) != 2 {
+		return ErrCmdParams
+	}
+
+	//args are not reused after the request, so can be kept in messages
+	req.resp.writeInteger(req.app.pubsub.publish(req.args[0], req.args[1]))
+	return nil
+}
+
+//PUBSUB CHANNELS [pattern]
+//PUBSUB NUMSUB [channel ...]
+//PUBSUB NUMPAT
+func pubsubCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	p := req.app.pubsub
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "channels":
+		if len(args) > 2 {
+			return ErrCmdParams
+		}
+
+		var pattern string
+		if len(args) == 2 {
+			pattern = ledis.String(args[1])
+		}
+
+		channels := p.activeChannels(pattern)
+		ay := make([][]byte, 0, len(channels))
+		for _, channel := range channels {
+			ay = append(ay, []byte(channel))
+		}
+		req.resp.writeSliceArray(ay)
+	case "numsub":
+		ay := make([]interface{}, 0, 2*(len(args)-1))
+		for _, channel := range args[1:] {
+			ay = append(ay, channel, p.numSub(ledis.String(channel)))
+		}
+		req.resp.writeMap(ay)
+	case "numpat":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+		req.resp.writeInteger(p.numPat())
+	default:
+		return ErrSyntax
+	}
+
+	return nil
+}
+
+type sseMessage struct {
+	Pattern string `json:"pattern,omitempty"`
+	Channel string `json:"channel"`
+	Message string `json:"message"`
+}
+
+//subscribeHandler streams messages as server-sent events,
+//GET /subscribe/channel[/channel...] or /psubscribe/pattern[/pattern...]
+func (app *App) subscribeHandler(w http.ResponseWriter, r *http.Request) {
+	names := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	kind := names[0]
+	names = names[1:]
+
+	if len(names) == 0 || len(names[0]) == 0 {
+		http.Error(w, fmt.Sprintf("no channel to %s", kind), http.StatusBadRequest)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming unsupported", http.StatusInternalServerError)
+		return
+	}
+
+	s := newSubscriber()
+	defer app.pubsub.unsubscribeAll(s)
+
+	for _, name := range names {
+		if kind == "psubscribe" {
+			app.pubsub.psubscribe(s, name)
+		} else {
+			app.pubsub.subscribe(s, name)
+		}
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+	flusher.Flush()
+
+	var closed <-chan bool
+	if n, ok := w.(http.CloseNotifier); ok {
+		closed = n.CloseNotify()
+	}
+
+	for {
+		select {
+		case m := <-s.msgs:
+			//message channel msg, or pmessage pattern channel msg
+			var e sseMessage
+			if len(m) == 4 {
+				e.Pattern = ledis.String(m[1].([]byte))
+			}
+			e.Channel = ledis.String(m[len(m)-2].([]byte))
+			e.Message = ledis.String(m[len(m)-1].([]byte))
+
+			data, _ := json.Marshal(e)
+			fmt.Fprintf(w, "event: %s\ndata: %s\n\n", m[0], data)
+			flusher.Flush()
+		case <-s.dropped:
+			return
+		case <-closed:
+			return
+		case <-app.quit:
+			return
+		}
+	}
+}
+
+func init() {
+	register("subscribe", subscribeCommand, cmdRead)
+	register("unsubscribe", unsubscribeCommand, cmdRead)
+	register("psubscribe", psubscribeCommand, cmdRead)
+	register("punsubscribe", punsubscribeCommand, cmdRead)
+	register("publish", publishCommand, cmdRead)
+	register("pubsub", pubsubCommand, cmdRead)
+}
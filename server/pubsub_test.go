@@ -0,0 +1,287 @@
+package server
+
+import (
+	"bufio"
+	"fmt"
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGlobMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		s       string
+		match   bool
+	}{
+		{"*", "", true},
+		{"news.*", "news.a/b", true},
+		{"news.*", "new", false},
+		{"h?llo", "hello", true},
+		{"h?llo", "hllo", false},
+		{"h*llo", "heeello", true},
+		{"h[ae]llo", "hallo", true},
+		{"h[ae]llo", "hillo", false},
+		{"h[^e]llo", "hallo", true},
+		{"h[^e]llo", "hello", false},
+		{"h[a-c]llo", "hbllo", true},
+		{"h\\*llo", "h*llo", true},
+		{"h\\*llo", "hello", false},
+		{"a[b", "a[b", true},
+	}
+
+	for _, test := range tests {
+		if m := globMatch(test.pattern, test.s); m != test.match {
+			t.Fatal(test.pattern, test.s, m)
+		}
+	}
+}
+
+//checkMessage checks the push reply, count in subscribe replies is an integer
+func checkMessage(t *testing.T, reply interface{}, expected ...string) {
+	values, err := ledis.Values(reply, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ay := make([]string, 0, len(values))
+	for _, v := range values {
+		if b, ok := v.([]byte); ok {
+			ay = append(ay, string(b))
+		} else {
+			ay = append(ay, fmt.Sprint(v))
+		}
+	}
+
+	if strings.Join(ay, " ") != strings.Join(expected, " ") {
+		t.Fatal(ay, expected)
+	}
+}
+
+func TestPubSub(t *testing.T) {
+	//connections in push mode must not be reused by other tests
+	sub := newTestConn()
+	defer sub.Close()
+
+	psub := newTestConn()
+	defer psub.Close()
+
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := sub.Do("subscribe", "pubsub_a", "pubsub_b"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := sub.Receive(); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "subscribe", "pubsub_b", "2")
+	}
+
+	if reply, err := psub.Do("psubscribe", "pubsub_*"); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "psubscribe", "pubsub_*", "1")
+	}
+
+	if n, err := ledis.Int64(c.Do("publish", "pubsub_a", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 2 {
+		t.Fatal(n)
+	}
+
+	if reply, err := sub.Receive(); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "message", "pubsub_a", "hello")
+	}
+
+	if reply, err := psub.Receive(); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "pmessage", "pubsub_*", "pubsub_a", "hello")
+	}
+
+	if ay, err := ledis.Strings(c.Do("pubsub", "channels", "pubsub_*")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 2 || ay[0] != "pubsub_a" {
+		t.Fatal(ay)
+	}
+
+	if ay, err := ledis.Values(c.Do("pubsub", "numsub", "pubsub_a", "pubsub_c")); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 || ay[1].(int64) != 1 || ay[3].(int64) != 0 {
+		t.Fatal(ay)
+	}
+
+	if n, err := ledis.Int64(c.Do("pubsub", "numpat")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	//only subscribe commands in RESP2
+	if _, err := sub.Do("get", "pubsub_a"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if reply, err := sub.Do("ping"); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "pong", "")
+	}
+
+	//order of unsubscribed channels is random
+	if reply, err := sub.Do("unsubscribe"); err != nil {
+		t.Fatal(err)
+	} else if ay, _ := ledis.Values(reply, nil); len(ay) != 3 || ay[2].(int64) != 1 {
+		t.Fatal(ay)
+	}
+
+	if reply, err := sub.Receive(); err != nil {
+		t.Fatal(err)
+	} else if ay, _ := ledis.Values(reply, nil); len(ay) != 3 || ay[2].(int64) != 0 {
+		t.Fatal(ay)
+	}
+
+	//all commands after unsubscribed
+	if _, err := sub.Do("get", "pubsub_a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ledis.Int64(c.Do("publish", "pubsub_b", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+}
+
+func TestPubSubRESP3(t *testing.T) {
+	sub := newTestConn()
+	defer sub.Close()
+
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := sub.Do("hello", 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := sub.Do("subscribe", "pubsub3_a"); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "subscribe", "pubsub3_a", "1")
+	}
+
+	//other commands are allowed in RESP3
+	if _, err := sub.Do("set", "pubsub3_a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("publish", "pubsub3_a", "hello"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := sub.Receive(); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "message", "pubsub3_a", "hello")
+	}
+}
+
+func TestPubSubHTTP(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	s := httptest.NewServer(http.HandlerFunc(testApp.subscribeHandler))
+	defer s.Close()
+
+	resp, err := http.Get(s.URL + "/psubscribe/pubsub_http_*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatal(ct)
+	}
+
+	if n, err := ledis.Int64(c.Do("publish", "pubsub_http_a", "hello")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	rb := bufio.NewReader(resp.Body)
+	if line, err := rb.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	} else if line != "event: pmessage\n" {
+		t.Fatal(line)
+	}
+
+	if line, err := rb.ReadString('\n'); err != nil {
+		t.Fatal(err)
+	} else if line != `data: {"pattern":"pubsub_http_*","channel":"pubsub_http_a","message":"hello"}`+"\n" {
+		t.Fatal(line)
+	}
+}
+
+func TestKeyspaceNotify(t *testing.T) {
+	sub := newTestConn()
+	defer sub.Close()
+
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("config", "set", "notify_keyspace_events", "y"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("config", "set", "notify_keyspace_events", "KE$ 1:Eh"); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Do("config", "set", "notify_keyspace_events", "")
+
+	if _, err := sub.Do("subscribe", "__keyspace@0__:notify_k"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := sub.Do("psubscribe", "__keyevent@1__:*"); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "psubscribe", "__keyevent@1__:*", "2")
+	}
+
+	if _, err := c.Do("set", "notify_k", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := sub.Receive(); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "message", "__keyspace@0__:notify_k", "set")
+	}
+
+	//only hash events in db 1
+	if _, err := c.Do("select", 1); err != nil {
+		t.Fatal(err)
+	}
+	defer c.Do("select", 0)
+
+	if _, err := c.Do("set", "notify_k", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("hset", "notify_h", "f", "v"); err != nil {
+		t.Fatal(err)
+	}
+
+	if reply, err := sub.Receive(); err != nil {
+		t.Fatal(err)
+	} else {
+		checkMessage(t, reply, "pmessage", "__keyevent@1__:*", "__keyevent@1__:hset", "notify_h")
+	}
+}
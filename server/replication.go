@@ -16,17 +16,35 @@ import (
 	"path"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
 var (
-	errConnectMaster = errors.New("connect master error")
+	errConnectMaster  = errors.New("connect master error")
+	errReadOnly       = errors.New("READONLY you can't write against a read only slave")
+	errMasterNoBinLog = errors.New("master binlog not enabled")
+	errWritableSlave  = errors.New("a slave with binlog enabled must be read only")
 )
 
+//link states of a slave to its master
+const (
+	//waiting to connect
+	replConnect int32 = iota
+	replConnecting
+	//full sync in progress
+	replSync
+	replConnected
+)
+
+var replStateNames = []string{"connect", "connecting", "sync", "connected"}
+
 type MasterInfo struct {
-	Addr         string `json:"addr"`
-	LogFileIndex int64  `json:"log_file_index"`
-	LogPos       int64  `json:"log_pos"`
+	Addr string `json:"addr"`
+	//log id of the last replicated batch
+	LogID uint64 `json:"log_id"`
+	//a full sync is done, the batches after LogID can be replicated by sync
+	Synced bool `json:"synced"`
 }
 
 func (m *MasterInfo) Save(filePath string) error {
@@ -88,6 +106,14 @@ type master struct {
 	syncBuf bytes.Buffer
 
 	compressBuf []byte
+
+	//for metrics, the last replicated log id and the last log id of master,
+	//read without lock
+	syncedLogID uint64
+	masterLogID uint64
+
+	//link state, read without lock
+	state int32
 }
 
 func newMaster(app *App) *master {
@@ -130,6 +156,19 @@ func (m *master) saveInfo() error {
 	return m.info.Save(m.infoName)
 }
 
+func (m *master) setSyncPos(masterLogID uint64) {
+	atomic.StoreUint64(&m.syncedLogID, m.info.LogID)
+	atomic.StoreUint64(&m.masterLogID, masterLogID)
+}
+
+func (m *master) setState(state int32) {
+	atomic.StoreInt32(&m.state, state)
+}
+
+func (m *master) stateName() string {
+	return replStateNames[atomic.LoadInt32(&m.state)]
+}
+
 func (m *master) connect() error {
 	if len(m.info.Addr) == 0 {
 		return fmt.Errorf("no assign master addr")
@@ -152,8 +191,8 @@ func (m *master) connect() error {
 
 func (m *master) resetInfo(addr string) {
 	m.info.Addr = addr
-	m.info.LogFileIndex = 0
-	m.info.LogPos = 0
+	m.info.LogID = 0
+	m.info.Synced = false
 }
 
 func (m *master) stopReplication() error {
@@ -189,42 +228,41 @@ func (m *master) runReplication() {
 	m.wg.Add(1)
 	defer m.wg.Done()
 
+	defer m.setState(replConnect)
+
 	for {
 		select {
 		case <-m.quit:
 			return
 		default:
+			m.setState(replConnecting)
 			if err := m.connect(); err != nil {
 				log.Error("connect master %s error %s, try 2s later", m.info.Addr, err.Error())
+				m.setState(replConnect)
 				time.Sleep(2 * time.Second)
 				continue
 			}
 		}
 
-		if m.info.LogFileIndex == 0 {
+		if !m.info.Synced {
 			//try a fullsync
+			m.setState(replSync)
 			if err := m.fullSync(); err != nil {
 				log.Warn("full sync error %s", err.Error())
 				return
 			}
-
-			if m.info.LogFileIndex == 0 {
-				//master not support binlog, we cannot sync, so stop replication
-				m.stopReplication()
-				return
-			}
 		}
 
 		for {
 			for {
-				lastIndex := m.info.LogFileIndex
-				lastPos := m.info.LogPos
+				lastID := m.info.LogID
 				if err := m.sync(); err != nil {
 					log.Warn("sync error %s", err.Error())
 					return
 				}
+				m.setState(replConnected)
 
-				if m.info.LogFileIndex == lastIndex && m.info.LogPos == lastPos {
+				if m.info.LogID == lastID {
 					//sync no data, wait 1s and retry
 					break
 				}
@@ -242,13 +280,40 @@ func (m *master) runReplication() {
 	return
 }
 
-var (
-	fullSyncCmd   = []byte("*1\r\n$8\r\nfullsync\r\n")               //fullsync
-	syncCmdFormat = "*3\r\n$4\r\nsync\r\n$%d\r\n%s\r\n$%d\r\n%s\r\n" //sync index pos
-)
+//FullSyncCmd is the fullsync command, the reply is a bulk string of the dump
+var FullSyncCmd = []byte("*1\r\n$8\r\nfullsync\r\n")
+
+const syncCmdFormat = "*2\r\n$4\r\nsync\r\n$%d\r\n%s\r\n"
+
+//SyncCmd returns the sync command for batches after logID,
+//the reply is a bulk string of snappy compressed sync data
+func SyncCmd(logID uint64) []byte {
+	logIDStr := strconv.FormatUint(logID, 10)
+	return ledis.Slice(fmt.Sprintf(syncCmdFormat, len(logIDStr), logIDStr))
+}
+
+//ParseSyncData parses the decompressed sync data, returns the first and last log ids
+//of master binlog, first is 0 if binlog is not enabled, and the batches, empty if none
+func ParseSyncData(buf []byte) (firstID uint64, lastID uint64, batches []byte, err error) {
+	if len(buf) < 16 {
+		err = fmt.Errorf("invalid sync data len %d", len(buf))
+		return
+	}
+
+	firstID = binary.BigEndian.Uint64(buf[0:8])
+	lastID = binary.BigEndian.Uint64(buf[8:16])
+	batches = buf[16:]
+	return
+}
 
 func (m *master) fullSync() error {
-	if _, err := m.conn.Write(fullSyncCmd); err != nil {
+	//our data is replaced, so a full sync must be done again if it fails
+	m.info.Synced = false
+	if err := m.saveInfo(); err != nil {
+		return err
+	}
+
+	if _, err := m.conn.Write(FullSyncCmd); err != nil {
 		return err
 	}
 
@@ -267,31 +332,23 @@ func (m *master) fullSync() error {
 		return err
 	}
 
-	if err = m.app.ldb.FlushAll(); err != nil {
-		return err
-	}
-
 	var head *ledis.MasterInfo
-	head, err = m.app.ldb.LoadDumpFile(dumpPath)
+	head, err = m.app.ldb.ReplicateFromDumpFile(dumpPath)
 
 	if err != nil {
 		log.Error("load dump file error %s", err.Error())
 		return err
 	}
 
-	m.info.LogFileIndex = head.LogFileIndex
-	m.info.LogPos = head.LogPos
+	m.info.LogID = head.LogID
+	m.info.Synced = true
+	m.setSyncPos(head.LogID)
 
 	return m.saveInfo()
 }
 
 func (m *master) sync() error {
-	logIndexStr := strconv.FormatInt(m.info.LogFileIndex, 10)
-	logPosStr := strconv.FormatInt(m.info.LogPos, 10)
-
-	cmd := ledis.Slice(fmt.Sprintf(syncCmdFormat, len(logIndexStr),
-		logIndexStr, len(logPosStr), logPosStr))
-	if _, err := m.conn.Write(cmd); err != nil {
+	if _, err := m.conn.Write(SyncCmd(m.info.LogID)); err != nil {
 		return err
 	}
 
@@ -310,31 +367,61 @@ func (m *master) sync() error {
 		m.compressBuf = buf
 	}
 
-	if len(buf) < 16 {
-		return fmt.Errorf("invalid sync data len %d", len(buf))
+	firstID, lastID, batches, err := ParseSyncData(buf)
+	if err != nil {
+		return err
 	}
 
-	m.info.LogFileIndex = int64(binary.BigEndian.Uint64(buf[0:8]))
-	m.info.LogPos = int64(binary.BigEndian.Uint64(buf[8:16]))
-
-	if m.info.LogFileIndex == 0 {
+	if firstID == 0 {
 		//master now not support binlog, stop replication
-		m.stopReplication()
+		return errMasterNoBinLog
+	}
+
+	if len(batches) == 0 {
+		if m.info.LogID+1 < firstID || m.info.LogID > lastID {
+			//the batches we need are purged, or master binlog is reset,
+			//we must start a full sync instead
+			return m.fullSync()
+		}
+
+		m.setSyncPos(lastID)
 		return nil
-	} else if m.info.LogFileIndex == -1 {
-		//-1 means than binlog index and pos are lost, we must start a full sync instead
-		return m.fullSync()
 	}
 
-	err = m.app.ldb.ReplicateFromData(buf[16:])
-	if err != nil {
+	var logID uint64
+	if logID, err = m.app.ldb.ReplicateFromData(batches); err != nil {
 		return err
 	}
 
+	m.info.LogID = logID
+	m.setSyncPos(lastID)
+
 	return m.saveInfo()
 
 }
 
+//updateReadOnly rejects writes if app is a read only slave, called with cfgLock held
+func (app *App) updateReadOnly() {
+	var readonly int32
+	if len(app.cfg.SlaveOf) > 0 && app.cfg.SlaveReadOnly {
+		readonly = 1
+	}
+	atomic.StoreInt32(&app.readonly, readonly)
+}
+
+//checkWritableSlave refuses a writable slave with binlog enabled, its local writes
+//would take the log ids of master batches, which are then not logged
+func (app *App) checkWritableSlave(slaveOf string, readOnly bool) error {
+	if len(slaveOf) > 0 && !readOnly && app.ldb.BinLog() != nil {
+		return errWritableSlave
+	}
+	return nil
+}
+
+func (app *App) isReadOnly() bool {
+	return atomic.LoadInt32(&app.readonly) == 1
+}
+
 func (app *App) slaveof(masterAddr string) error {
 	app.m.Lock()
 	defer app.m.Unlock()
@@ -12,7 +12,13 @@ type responseWriter interface {
 	writeStatus(string)
 	writeInteger(int64)
 	writeBulk([]byte)
+	writeNull()
+	writeBool(bool)
+	writeDouble(float64)
 	writeArray([]interface{})
+	//key and value pairs
+	writeMap([]interface{})
+	writePush([]interface{})
 	writeSliceArray([][]byte)
 	writeFVPairArray([]ledis.FVPair)
 	writeScorePairArray([]ledis.ScorePair, bool)
@@ -31,11 +37,12 @@ type requestContext struct {
 
 	resp responseWriter
 
+	//nil if not from a resp client
+	client *respClient
+
 	syncBuf     bytes.Buffer
 	compressBuf []byte
 
-	reqErr chan error
-
 	buf bytes.Buffer
 }
 
@@ -47,46 +54,65 @@ func newRequestContext(app *App) *requestContext {
 	req.db, _ = app.ldb.Select(0) //use default db
 
 	req.compressBuf = make([]byte, 256)
-	req.reqErr = make(chan error)
 
 	return req
 }
 
+//perform executes the command and writes the reply, the caller flushes it,
+//so pipelined replies can be flushed once
 func (req *requestContext) perform() {
 	var err error
 
 	start := time.Now()
 
+	if !req.app.beginRequest() {
+		req.resp.writeError(errShutdown)
+		return
+	}
+	defer req.app.endRequest()
+
 	if len(req.cmd) == 0 {
 		err = ErrEmptyCommand
-	} else if exeCmd, ok := regCmds[req.cmd]; !ok {
+	} else if cmd, ok := regCmds[req.cmd]; !ok {
 		err = ErrNotFound
+	} else if cmd.flag == cmdWrite && req.app.isReadOnly() {
+		err = errReadOnly
 	} else {
-		go func() {
-			req.reqErr <- exeCmd(req)
-		}()
-
-		err = <-req.reqErr
+		err = cmd.f(req)
 	}
 
 	duration := time.Since(start)
 
-	if req.app.access != nil {
+	req.app.stat.observe(req.cmd, duration, err)
+
+	slow := req.app.slowlog.isSlow(duration)
+
+	//access log may be changed by CONFIG SET
+	req.app.accessLock.RLock()
+	access := req.app.access
+
+	if access != nil || slow {
 		fullCmd := req.catGenericCommand()
-		cost := duration.Nanoseconds() / 1000000
 
 		truncateLen := len(fullCmd)
-		if truncateLen > 256 {
-			truncateLen = 256
+		if truncateLen > maxLogCommandLen {
+			truncateLen = maxLogCommandLen
 		}
 
-		req.app.access.Log(req.remoteAddr, cost, fullCmd[:truncateLen], err)
+		if access != nil {
+			cost := duration.Nanoseconds() / 1000000
+			access.Log(req.remoteAddr, cost, fullCmd[:truncateLen], err)
+		}
+
+		if slow {
+			req.app.slowlog.log(req.remoteAddr, start, duration, fullCmd[:truncateLen])
+		}
 	}
+	req.app.accessLock.RUnlock()
 
 	if err != nil {
 		req.resp.writeError(err)
 	}
-	req.resp.flush()
 	return
 }
 
@@ -0,0 +1,630 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/ledis"
+	"github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errNoScript      = errors.New("NOSCRIPT no matching script, use EVAL")
+	errScriptCommand = errors.New("command is not allowed from scripts")
+	errScriptNumKeys = errors.New("number of keys can not be greater than number of args")
+	errScriptKilled  = errors.New("script killed by user with SCRIPT KILL")
+	errScriptTimeout = errors.New("script timed out, exceeded lua_time_limit")
+	errNotBusy       = errors.New("NOTBUSY no scripts in execution right now")
+)
+
+//commands of server or connection, not allowed from scripts,
+//a script can only use data commands of the db it runs in
+var scriptDisabledCommands = map[string]bool{
+	"eval":         true,
+	"evalsha":      true,
+	"script":       true,
+	"select":       true,
+	"client":       true,
+	"hello":        true,
+	"config":       true,
+	"info":         true,
+	"shutdown":     true,
+	"compact":      true,
+	"backup":       true,
+	"bgsave":       true,
+	"binlog":       true,
+	"slowlog":      true,
+	"store":        true,
+	"slaveof":      true,
+	"replicaof":    true,
+	"role":         true,
+	"replicas":     true,
+	"sync":         true,
+	"fullsync":     true,
+	"subscribe":    true,
+	"unsubscribe":  true,
+	"psubscribe":   true,
+	"punsubscribe": true,
+}
+
+//scriptCache keeps compiled scripts by the sha1 of their source, and the running scripts
+type scriptCache struct {
+	sync.Mutex
+
+	protos map[string]*lua.FunctionProto
+
+	running map[*runningScript]struct{}
+
+	//milliseconds, read with atomic, changed by CONFIG SET
+	timeLimit int64
+}
+
+//runningScript can be stopped by SCRIPT KILL
+type runningScript struct {
+	cancel context.CancelFunc
+	killed int32
+}
+
+func newScriptCache(cfg *config.Config) *scriptCache {
+	c := new(scriptCache)
+	c.protos = make(map[string]*lua.FunctionProto)
+	c.running = make(map[*runningScript]struct{})
+	c.timeLimit = int64(cfg.LuaTimeLimit)
+	return c
+}
+
+//start returns the context a script runs in, which is done after the time limit
+func (c *scriptCache) start() (context.Context, *runningScript) {
+	ctx, cancel := context.WithCancel(context.Background())
+	if limit := atomic.LoadInt64(&c.timeLimit); limit > 0 {
+		ctx, cancel = context.WithTimeout(context.Background(), time.Duration(limit)*time.Millisecond)
+	}
+
+	r := &runningScript{cancel: cancel}
+
+	c.Lock()
+	c.running[r] = struct{}{}
+	c.Unlock()
+
+	return ctx, r
+}
+
+func (c *scriptCache) end(r *runningScript) {
+	c.Lock()
+	delete(c.running, r)
+	c.Unlock()
+
+	r.cancel()
+}
+
+//kill stops all running scripts, returns false if none is running
+func (c *scriptCache) kill() bool {
+	c.Lock()
+	defer c.Unlock()
+
+	for r := range c.running {
+		atomic.StoreInt32(&r.killed, 1)
+		r.cancel()
+	}
+
+	return len(c.running) > 0
+}
+
+func sha1Hex(data []byte) string {
+	h := sha1.Sum(data)
+	return hex.EncodeToString(h[:])
+}
+
+//load compiles the script and caches it, returns its sha1
+func (c *scriptCache) load(script []byte) (string, *lua.FunctionProto, error) {
+	sha := sha1Hex(script)
+	if proto := c.get(sha); proto != nil {
+		return sha, proto, nil
+	}
+
+	name := fmt.Sprintf("@user_script:%s", sha)
+	chunk, err := parse.Parse(bytes.NewReader(script), name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	proto, err := lua.Compile(chunk, name)
+	if err != nil {
+		return "", nil, err
+	}
+
+	c.Lock()
+	c.protos[sha] = proto
+	c.Unlock()
+
+	return sha, proto, nil
+}
+
+func (c *scriptCache) get(sha string) *lua.FunctionProto {
+	c.Lock()
+	defer c.Unlock()
+
+	return c.protos[strings.ToLower(sha)]
+}
+
+func (c *scriptCache) flush() {
+	c.Lock()
+	c.protos = make(map[string]*lua.FunctionProto)
+	c.Unlock()
+}
+
+//scriptWriter converts the reply of a command called from a script to a lua value
+type scriptWriter struct {
+	l     *lua.LState
+	value lua.LValue
+
+	//error reply of the command
+	err error
+}
+
+func (w *scriptWriter) table(lst []interface{}) *lua.LTable {
+	t := w.l.CreateTable(len(lst), 0)
+	for i, v := range lst {
+		t.RawSetInt(i+1, w.toLua(v))
+	}
+	return t
+}
+
+func (w *scriptWriter) toLua(v interface{}) lua.LValue {
+	switch v := v.(type) {
+	case []interface{}:
+		return w.table(v)
+	case []byte:
+		return lua.LString(v)
+	case int64:
+		return lua.LNumber(v)
+	case bool:
+		return lua.LBool(v)
+	case float64:
+		return lua.LNumber(v)
+	default:
+		//nil is false in a table
+		return lua.LFalse
+	}
+}
+
+func (w *scriptWriter) writeError(err error) {
+	w.err = err
+}
+
+func (w *scriptWriter) writeStatus(status string) {
+	t := w.l.NewTable()
+	t.RawSetString("ok", lua.LString(status))
+	w.value = t
+}
+
+func (w *scriptWriter) writeInteger(n int64) {
+	w.value = lua.LNumber(n)
+}
+
+func (w *scriptWriter) writeBulk(b []byte) {
+	if b == nil {
+		w.value = lua.LFalse
+	} else {
+		w.value = lua.LString(b)
+	}
+}
+
+func (w *scriptWriter) writeNull() {
+	w.value = lua.LFalse
+}
+
+func (w *scriptWriter) writeBool(b bool) {
+	w.value = lua.LBool(b)
+}
+
+func (w *scriptWriter) writeDouble(f float64) {
+	w.value = lua.LNumber(f)
+}
+
+func (w *scriptWriter) writeArray(lst []interface{}) {
+	if lst == nil {
+		w.value = lua.LFalse
+		return
+	}
+	w.value = w.table(lst)
+}
+
+//key and value pairs are flattened
+func (w *scriptWriter) writeMap(kvs []interface{}) {
+	w.value = w.table(kvs)
+}
+
+func (w *scriptWriter) writePush(lst []interface{}) {
+	w.value = w.table(lst)
+}
+
+func (w *scriptWriter) writeSliceArray(lst [][]byte) {
+	t := w.l.CreateTable(len(lst), 0)
+	for i, v := range lst {
+		if v == nil {
+			t.RawSetInt(i+1, lua.LFalse)
+		} else {
+			t.RawSetInt(i+1, lua.LString(v))
+		}
+	}
+	w.value = t
+}
+
+func (w *scriptWriter) writeFVPairArray(lst []ledis.FVPair) {
+	t := w.l.CreateTable(len(lst)*2, 0)
+	for _, v := range lst {
+		t.Append(lua.LString(v.Field))
+		t.Append(lua.LString(v.Value))
+	}
+	w.value = t
+}
+
+func (w *scriptWriter) writeScorePairArray(lst []ledis.ScorePair, withScores bool) {
+	t := w.l.CreateTable(len(lst)*2, 0)
+	for _, v := range lst {
+		t.Append(lua.LString(v.Member))
+		if withScores {
+			t.Append(lua.LNumber(v.Score))
+		}
+	}
+	w.value = t
+}
+
+func (w *scriptWriter) writeBulkFrom(n int64, rb io.Reader) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(rb, buf); err != nil {
+		w.err = err
+		return
+	}
+	w.value = lua.LString(buf)
+}
+
+func (w *scriptWriter) flush() {
+}
+
+//scriptValue converts a lua value to a reply value,
+//numbers are truncated to integers if integral, tables are arrays up to the first nil
+func scriptValue(v lua.LValue) interface{} {
+	switch v := v.(type) {
+	case lua.LString:
+		return []byte(string(v))
+	case lua.LNumber:
+		if f := float64(v); f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return int64(f)
+		}
+		return float64(v)
+	case lua.LBool:
+		if v {
+			return int64(1)
+		}
+		return nil
+	case *lua.LTable:
+		if s, ok := v.RawGetString("err").(lua.LString); ok {
+			return []byte(string(s))
+		} else if s, ok := v.RawGetString("ok").(lua.LString); ok {
+			return []byte(string(s))
+		}
+
+		ay := []interface{}{}
+		for i := 1; ; i++ {
+			e := v.RawGetInt(i)
+			if e == lua.LNil {
+				break
+			}
+			ay = append(ay, scriptValue(e))
+		}
+		return ay
+	default:
+		return nil
+	}
+}
+
+//writeScriptReply writes the value returned by a script,
+//a table with err or ok field is an error or status reply
+func writeScriptReply(w responseWriter, v lua.LValue) {
+	if t, ok := v.(*lua.LTable); ok {
+		if s, ok := t.RawGetString("err").(lua.LString); ok {
+			w.writeError(errors.New(string(s)))
+			return
+		} else if s, ok := t.RawGetString("ok").(lua.LString); ok {
+			w.writeStatus(string(s))
+			return
+		}
+	}
+
+	switch v := scriptValue(v).(type) {
+	case []interface{}:
+		w.writeArray(v)
+	case []byte:
+		w.writeBulk(v)
+	case int64:
+		w.writeInteger(v)
+	case float64:
+		w.writeDouble(v)
+	default:
+		w.writeBulk(nil)
+	}
+}
+
+//scriptContext runs commands called from a script in the transaction of the script
+type scriptContext struct {
+	req *requestContext
+}
+
+func newScriptContext(req *requestContext, tx *ledis.Tx) *scriptContext {
+	s := new(scriptContext)
+
+	sub := new(requestContext)
+	sub.app = req.app
+	sub.ldb = req.ldb
+	sub.db = tx.DB
+	sub.remoteAddr = req.remoteAddr
+	sub.compressBuf = make([]byte, 256)
+
+	s.req = sub
+	return s
+}
+
+//call runs the command of the lua arguments, an error reply raises a lua error,
+//or is returned as a table with err field if protected
+func (s *scriptContext) call(l *lua.LState, protected bool) int {
+	n := l.GetTop()
+	if n == 0 {
+		l.RaiseError("please specify at least one argument for ledis.call")
+	}
+
+	args := make([][]byte, n)
+	for i := 1; i <= n; i++ {
+		switch v := l.Get(i).(type) {
+		case lua.LString:
+			args[i-1] = []byte(string(v))
+		case lua.LNumber:
+			args[i-1] = []byte(v.String())
+		default:
+			l.ArgError(i, "command arguments must be strings or integers")
+		}
+	}
+
+	req := s.req
+	req.cmd = strings.ToLower(ledis.String(args[0]))
+	req.args = args[1:]
+
+	w := &scriptWriter{l: l, value: lua.LFalse}
+	req.resp = w
+
+	var err error
+	if cmd, ok := regCmds[req.cmd]; !ok {
+		err = ErrNotFound
+	} else if scriptDisabledCommands[req.cmd] {
+		err = errScriptCommand
+	} else if cmd.flag == cmdWrite && req.app.isReadOnly() {
+		err = errReadOnly
+	} else if err = cmd.f(req); err == nil {
+		err = w.err
+	}
+
+	if err != nil {
+		if !protected {
+			l.RaiseError("%s", err.Error())
+		}
+
+		t := l.NewTable()
+		t.RawSetString("err", lua.LString(err.Error()))
+		l.Push(t)
+		return 1
+	}
+
+	l.Push(w.value)
+	return 1
+}
+
+func replyTable(field string) lua.LGFunction {
+	return func(l *lua.LState) int {
+		t := l.NewTable()
+		t.RawSetString(field, lua.LString(l.CheckString(1)))
+		l.Push(t)
+		return 1
+	}
+}
+
+func stringTable(l *lua.LState, ay [][]byte) *lua.LTable {
+	t := l.CreateTable(len(ay), 0)
+	for i, v := range ay {
+		t.RawSetInt(i+1, lua.LString(v))
+	}
+	return t
+}
+
+//newScriptState creates a lua state with safe libs and the ledis table
+func newScriptState(s *scriptContext) *lua.LState {
+	l := lua.NewState(lua.Options{SkipOpenLibs: true})
+
+	for _, lib := range []struct {
+		name string
+		f    lua.LGFunction
+	}{
+		{lua.BaseLibName, lua.OpenBase},
+		{lua.TabLibName, lua.OpenTable},
+		{lua.StringLibName, lua.OpenString},
+		{lua.MathLibName, lua.OpenMath},
+	} {
+		l.Push(l.NewFunction(lib.f))
+		l.Push(lua.LString(lib.name))
+		l.Call(1, 0)
+	}
+
+	//no file access
+	l.SetGlobal("dofile", lua.LNil)
+	l.SetGlobal("loadfile", lua.LNil)
+
+	t := l.NewTable()
+	l.SetFuncs(t, map[string]lua.LGFunction{
+		"call":         func(l *lua.LState) int { return s.call(l, false) },
+		"pcall":        func(l *lua.LState) int { return s.call(l, true) },
+		"error_reply":  replyTable("err"),
+		"status_reply": replyTable("ok"),
+		"sha1hex": func(l *lua.LState) int {
+			l.Push(lua.LString(sha1Hex([]byte(l.CheckString(1)))))
+			return 1
+		},
+	})
+	l.SetGlobal("ledis", t)
+	//scripts written for redis work too
+	l.SetGlobal("redis", t)
+
+	return l
+}
+
+//evalScript runs the script in a transaction of the db, so it is atomic,
+//its writes are committed at once if it succeeds, else discarded,
+//args are numkeys key [key ...] arg [arg ...]
+func evalScript(req *requestContext, proto *lua.FunctionProto, args [][]byte) error {
+	numKeys, err := strconv.Atoi(ledis.String(args[0]))
+	if err != nil || numKeys < 0 {
+		return ErrValue
+	} else if numKeys > len(args)-1 {
+		return errScriptNumKeys
+	}
+
+	tx, err := req.db.Begin()
+	if err != nil {
+		return err
+	}
+
+	l := newScriptState(newScriptContext(req, tx))
+	defer l.Close()
+
+	l.SetGlobal("KEYS", stringTable(l, args[1:numKeys+1]))
+	l.SetGlobal("ARGV", stringTable(l, args[numKeys+1:]))
+
+	//the script holds the write locks, so it must not run forever
+	ctx, r := req.app.scripts.start()
+	defer req.app.scripts.end(r)
+	l.SetContext(ctx)
+
+	l.Push(l.NewFunctionFromProto(proto))
+	if err = l.PCall(0, 1, nil); err != nil {
+		tx.Rollback()
+
+		if atomic.LoadInt32(&r.killed) == 1 {
+			return errScriptKilled
+		} else if ctx.Err() == context.DeadlineExceeded {
+			return errScriptTimeout
+		}
+		return err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return err
+	}
+
+	writeScriptReply(req.resp, l.Get(-1))
+	return nil
+}
+
+//EVAL script numkeys key [key ...] arg [arg ...]
+func evalCommand(req *requestContext) error {
+	if len(req.args) < 2 {
+		return ErrCmdParams
+	}
+
+	_, proto, err := req.app.scripts.load(req.args[0])
+	if err != nil {
+		return err
+	}
+
+	return evalScript(req, proto, req.args[1:])
+}
+
+//EVALSHA sha1 numkeys key [key ...] arg [arg ...]
+func evalshaCommand(req *requestContext) error {
+	if len(req.args) < 2 {
+		return ErrCmdParams
+	}
+
+	proto := req.app.scripts.get(ledis.String(req.args[0]))
+	if proto == nil {
+		return errNoScript
+	}
+
+	return evalScript(req, proto, req.args[1:])
+}
+
+//SCRIPT LOAD script
+//SCRIPT EXISTS sha1 [sha1 ...]
+//SCRIPT FLUSH
+//SCRIPT KILL
+func scriptCommand(req *requestContext) error {
+	args := req.args
+	if len(args) == 0 {
+		return ErrCmdParams
+	}
+
+	scripts := req.app.scripts
+
+	switch strings.ToLower(ledis.String(args[0])) {
+	case "load":
+		if len(args) != 2 {
+			return ErrCmdParams
+		}
+
+		sha, _, err := scripts.load(args[1])
+		if err != nil {
+			return err
+		}
+		req.resp.writeBulk([]byte(sha))
+	case "exists":
+		if len(args) < 2 {
+			return ErrCmdParams
+		}
+
+		ay := make([]interface{}, len(args)-1)
+		for i, sha := range args[1:] {
+			if scripts.get(ledis.String(sha)) != nil {
+				ay[i] = int64(1)
+			} else {
+				ay[i] = int64(0)
+			}
+		}
+		req.resp.writeArray(ay)
+	case "flush":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		scripts.flush()
+		req.resp.writeStatus(OK)
+	case "kill":
+		if len(args) != 1 {
+			return ErrCmdParams
+		}
+
+		if !scripts.kill() {
+			return errNotBusy
+		}
+		req.resp.writeStatus(OK)
+	default:
+		return ErrSyntax
+	}
+
+	return nil
+}
+
+func init() {
+	register("eval", evalCommand, cmdRead)
+	register("evalsha", evalshaCommand, cmdRead)
+	register("script", scriptCommand, cmdRead)
+}
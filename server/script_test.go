@@ -0,0 +1,184 @@
+package server
+
+import (
+	"github.com/siddontang/ledisdb/client/go/ledis"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEval(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	//compare and set
+	cas := `
+local v = ledis.call("get", KEYS[1])
+if v == ARGV[1] then
+	ledis.call("set", KEYS[1], ARGV[2])
+	return 1
+end
+return 0`
+
+	if _, err := c.Do("set", "eval_a", "1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := ledis.Int64(c.Do("eval", cas, 1, "eval_a", "1", "2")); err != nil {
+		t.Fatal(err)
+	} else if n != 1 {
+		t.Fatal(n)
+	}
+
+	if n, err := ledis.Int64(c.Do("eval", cas, 1, "eval_a", "1", "3")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if v, err := ledis.String(c.Do("get", "eval_a")); err != nil {
+		t.Fatal(err)
+	} else if v != "2" {
+		t.Fatal(v)
+	}
+
+	//replies converted, nil reply is false in lua
+	if ay, err := ledis.Values(c.Do("eval", `return {1, "a", ledis.call("hget", "eval_no_key", "f"), "b", nil, "c"}`, 0)); err != nil {
+		t.Fatal(err)
+	} else if len(ay) != 4 || ay[0].(int64) != 1 || string(ay[1].([]byte)) != "a" || ay[2] != nil {
+		t.Fatal(ay)
+	}
+
+	if s, err := ledis.String(c.Do("eval", `return ledis.call("set", "eval_b", 1)`, 0)); err != nil {
+		t.Fatal(err)
+	} else if s != OK {
+		t.Fatal(s)
+	}
+
+	if _, err := c.Do("eval", `return ledis.error_reply("my error")`, 0); err == nil || err.Error() != "ERR my error" {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("eval", `return ledis.call("select", 1)`, 0); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("eval", `return`, 2, "a"); err == nil {
+		t.Fatal("must error")
+	}
+
+	//writes are discarded if the script fails
+	if _, err := c.Do("eval", `ledis.call("set", KEYS[1], "3"); ledis.call("incr", KEYS[2])`, 2, "eval_a", "eval_b_str"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("set", "eval_b_str", "abc"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("eval", `ledis.call("set", KEYS[1], "4"); ledis.call("incr", KEYS[2])`, 2, "eval_a", "eval_b_str"); err == nil {
+		t.Fatal("must error")
+	}
+
+	if v, err := ledis.String(c.Do("get", "eval_a")); err != nil {
+		t.Fatal(err)
+	} else if v != "3" {
+		t.Fatal(v)
+	}
+
+	//pcall returns the error
+	if v, err := ledis.String(c.Do("eval", `local r = ledis.pcall("incr", KEYS[1]); return r.err`, 1, "eval_b_str")); err != nil {
+		t.Fatal(err)
+	} else if len(v) == 0 {
+		t.Fatal(v)
+	}
+}
+
+func TestScriptCommand(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	script := `return ARGV[1]`
+
+	sha, err := ledis.String(c.Do("script", "load", script))
+	if err != nil {
+		t.Fatal(err)
+	} else if sha != sha1Hex([]byte(script)) {
+		t.Fatal(sha)
+	}
+
+	if v, err := ledis.String(c.Do("evalsha", sha, 0, "hello")); err != nil {
+		t.Fatal(err)
+	} else if v != "hello" {
+		t.Fatal(v)
+	}
+
+	if ay, err := ledis.Values(c.Do("script", "exists", sha, "0000")); err != nil {
+		t.Fatal(err)
+	} else if ay[0].(int64) != 1 || ay[1].(int64) != 0 {
+		t.Fatal(ay)
+	}
+
+	if _, err := c.Do("script", "load", "return ("); err == nil {
+		t.Fatal("must error")
+	}
+
+	if _, err := c.Do("script", "flush"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("evalsha", sha, 0); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func TestScriptKill(t *testing.T) {
+	c := getTestConn()
+	defer c.Close()
+
+	if _, err := c.Do("script", "kill"); err == nil || !strings.Contains(err.Error(), "NOTBUSY") {
+		t.Fatal(err)
+	}
+
+	loop := `ledis.call("set", "script_loop", 1) while true do end`
+
+	//stopped after lua_time_limit, writes discarded
+	if _, err := c.Do("config", "set", "lua_time_limit", 100); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := c.Do("eval", loop, 0); err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Fatal(err)
+	} else if n, err := ledis.Int64(c.Do("exists", "script_loop")); err != nil {
+		t.Fatal(err)
+	} else if n != 0 {
+		t.Fatal(n)
+	}
+
+	if _, err := c.Do("config", "set", "lua_time_limit", 0); err != nil {
+		t.Fatal(err)
+	}
+
+	c1 := newTestConn()
+	defer c1.Close()
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := c1.Do("eval", loop, 0)
+		done <- err
+	}()
+
+	var err error
+	for i := 0; i < 50; i++ {
+		time.Sleep(20 * time.Millisecond)
+		if _, err = c.Do("script", "kill"); err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		t.Fatal(err)
+	} else if err = <-done; err == nil || !strings.Contains(err.Error(), "killed") {
+		t.Fatal(err)
+	}
+}
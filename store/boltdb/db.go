@@ -31,6 +31,8 @@ func (s Store) Open(dbPath string, cfg *config.Config) (driver.IDB, error) {
 		return nil, err
 	}
 
+	db.db.NoSync = cfg.BoltDB.NoSync
+
 	var tx *bolt.Tx
 	tx, err = db.db.Begin(true)
 	if err != nil {
@@ -120,6 +122,49 @@ func (db *DB) NewWriteBatch() driver.IWriteBatch {
 	return driver.NewWriteBatch(db)
 }
 
+func (db *DB) BackupSnapshot() (driver.IBackupSnapshot, error) {
+	//a read transaction sees the data when it begins
+	tx, err := db.db.Begin(false)
+	if err != nil {
+		return nil, err
+	}
+
+	return &backupSnapshot{tx}, nil
+}
+
+type backupSnapshot struct {
+	tx *bolt.Tx
+}
+
+func (s *backupSnapshot) Save(dir string) error {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return err
+	}
+
+	return s.tx.CopyFile(path.Join(dir, "ledis_bolt.db"), 0600)
+}
+
+func (s *backupSnapshot) Close() {
+	s.tx.Rollback()
+}
+
+//Sync flushes data to disk, only needed when nosync is set
+func (db *DB) Sync() error {
+	return db.db.Sync()
+}
+
+func (db *DB) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Tx:                    true,
+		Snapshot:              true,
+		CheapReverseIteration: true,
+		Compaction:            false,
+		ApproximateSize:       false,
+		NativeBackup:          true,
+		Sync:                  true,
+	}
+}
+
 func (db *DB) Begin() (driver.Tx, error) {
 	tx, err := db.db.Begin(true)
 	if err != nil {
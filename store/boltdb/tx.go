@@ -11,7 +11,12 @@ type Tx struct {
 }
 
 func (t *Tx) Get(key []byte) ([]byte, error) {
-	return t.b.Get(key), nil
+	//value is only valid in tx, so copy it
+	v := t.b.Get(key)
+	if v == nil {
+		return nil, nil
+	}
+	return append([]byte{}, v...), nil
 }
 
 func (t *Tx) Put(key []byte, value []byte) error {
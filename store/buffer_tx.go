@@ -0,0 +1,270 @@
+package store
+
+import (
+	"bytes"
+	"errors"
+	"github.com/siddontang/ledisdb/store/driver"
+	"sort"
+)
+
+var errTxClosed = errors.New("tx is closed")
+
+//bufferTx saves its writes in a sorted list over the store, nil value means
+//deleted, and applies them with a write batch when commit.
+type bufferTx struct {
+	db driver.IDB
+
+	keys   [][]byte
+	values [][]byte
+}
+
+func newBufferTx(db driver.IDB) *bufferTx {
+	return &bufferTx{db: db}
+}
+
+//find returns the index of the first key >= key, and whether it is equal
+func (t *bufferTx) find(key []byte) (int, bool) {
+	i := sort.Search(len(t.keys), func(i int) bool {
+		return bytes.Compare(t.keys[i], key) >= 0
+	})
+
+	return i, i < len(t.keys) && bytes.Equal(t.keys[i], key)
+}
+
+func (t *bufferTx) set(key []byte, value []byte) {
+	i, ok := t.find(key)
+	if ok {
+		t.values[i] = value
+		return
+	}
+
+	t.keys = append(t.keys, nil)
+	copy(t.keys[i+1:], t.keys[i:])
+	t.keys[i] = append([]byte{}, key...)
+
+	t.values = append(t.values, nil)
+	copy(t.values[i+1:], t.values[i:])
+	t.values[i] = value
+}
+
+func (t *bufferTx) Get(key []byte) ([]byte, error) {
+	if i, ok := t.find(key); ok {
+		if t.values[i] == nil {
+			return nil, nil
+		}
+		return append([]byte{}, t.values[i]...), nil
+	}
+
+	return t.db.Get(key)
+}
+
+func (t *bufferTx) Put(key []byte, value []byte) error {
+	t.set(key, append([]byte{}, value...))
+	return nil
+}
+
+func (t *bufferTx) Delete(key []byte) error {
+	t.set(key, nil)
+	return nil
+}
+
+func (t *bufferTx) NewIterator() driver.IIterator {
+	return &bufferTxIterator{tx: t, it: t.db.NewIterator()}
+}
+
+func (t *bufferTx) NewWriteBatch() driver.IWriteBatch {
+	return driver.NewWriteBatch(t)
+}
+
+func (t *bufferTx) BatchPut(writes []driver.Write) error {
+	for _, w := range writes {
+		if w.Value == nil {
+			t.Delete(w.Key)
+		} else {
+			t.Put(w.Key, w.Value)
+		}
+	}
+	return nil
+}
+
+func (t *bufferTx) Rollback() error {
+	if t.db == nil {
+		return errTxClosed
+	}
+
+	t.close()
+	return nil
+}
+
+func (t *bufferTx) Commit() error {
+	if t.db == nil {
+		return errTxClosed
+	}
+
+	wb := t.db.NewWriteBatch()
+	for i, key := range t.keys {
+		if t.values[i] == nil {
+			wb.Delete(key)
+		} else {
+			wb.Put(key, t.values[i])
+		}
+	}
+
+	err := wb.Commit()
+	t.close()
+	return err
+}
+
+func (t *bufferTx) close() {
+	t.db = nil
+	t.keys = nil
+	t.values = nil
+}
+
+//seek returns the index of the nearest write from key, in the direction of forward,
+//key is included only if inclusive, nil key means from the first or the last,
+//returns -1 if not found
+func (t *bufferTx) seek(key []byte, forward bool, inclusive bool) int {
+	if key == nil {
+		if forward {
+			return 0
+		}
+		return len(t.keys) - 1
+	}
+
+	i, ok := t.find(key)
+	if forward {
+		if ok && !inclusive {
+			i++
+		}
+		return i
+	}
+
+	if ok && inclusive {
+		return i
+	}
+	return i - 1
+}
+
+//bufferTxIterator merges the writes of tx with the store, skipping deleted keys,
+//it seeks again for every step, so the writes after creating it are also seen
+type bufferTxIterator struct {
+	tx *bufferTx
+	it driver.IIterator
+
+	key   []byte
+	value []byte
+	valid bool
+}
+
+//seekStore is like bufferTx seek, but on the iterator of store
+func (it *bufferTxIterator) seekStore(key []byte, forward bool, inclusive bool) ([]byte, []byte, bool) {
+	s := it.it
+
+	if key == nil {
+		if forward {
+			s.First()
+		} else {
+			s.Last()
+		}
+	} else {
+		s.Seek(key)
+		if forward {
+			if !inclusive && s.Valid() && bytes.Equal(s.Key(), key) {
+				s.Next()
+			}
+		} else if !s.Valid() {
+			s.Last()
+		} else if !inclusive || !bytes.Equal(s.Key(), key) {
+			s.Prev()
+		}
+	}
+
+	if !s.Valid() {
+		return nil, nil, false
+	}
+	return s.Key(), s.Value(), true
+}
+
+func (it *bufferTxIterator) seek(key []byte, forward bool, inclusive bool) {
+	t := it.tx
+
+	for {
+		k, v, ok := it.seekStore(key, forward, inclusive)
+		i := t.seek(key, forward, inclusive)
+
+		if i < 0 || i >= len(t.keys) {
+			it.set(k, v, ok)
+			return
+		}
+
+		if ok {
+			c := bytes.Compare(t.keys[i], k)
+			if (forward && c > 0) || (!forward && c < 0) {
+				it.set(k, v, ok)
+				return
+			}
+		}
+
+		if t.values[i] != nil {
+			it.set(t.keys[i], t.values[i], true)
+			return
+		}
+
+		//deleted in tx, go on from it
+		key = t.keys[i]
+		inclusive = false
+	}
+}
+
+func (it *bufferTxIterator) set(key []byte, value []byte, valid bool) {
+	it.valid = valid
+	if valid {
+		//not reuse the old buffer, callers may still hold it
+		it.key = append([]byte{}, key...)
+		it.value = append([]byte{}, value...)
+	} else {
+		it.key = nil
+		it.value = nil
+	}
+}
+
+func (it *bufferTxIterator) Close() error {
+	return it.it.Close()
+}
+
+func (it *bufferTxIterator) First() {
+	it.seek(nil, true, true)
+}
+
+func (it *bufferTxIterator) Last() {
+	it.seek(nil, false, true)
+}
+
+func (it *bufferTxIterator) Seek(key []byte) {
+	it.seek(key, true, true)
+}
+
+func (it *bufferTxIterator) Next() {
+	if it.valid {
+		it.seek(it.key, true, false)
+	}
+}
+
+func (it *bufferTxIterator) Prev() {
+	if it.valid {
+		it.seek(it.key, false, false)
+	}
+}
+
+func (it *bufferTxIterator) Valid() bool {
+	return it.valid
+}
+
+func (it *bufferTxIterator) Key() []byte {
+	return it.key
+}
+
+func (it *bufferTxIterator) Value() []byte {
+	return it.value
+}
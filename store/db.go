@@ -74,11 +74,76 @@ func (db *DB) RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, off
 	return NewRevRangeLimitIterator(db.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
 }
 
-func (db *DB) Begin() (Tx, error) {
+//Backup saves data in dir using the native backup of store,
+//returns driver.ErrBackupSupport if the store not supports
+func (db *DB) Backup(dir string) error {
+	s, err := db.BackupSnapshot()
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	return s.Save(dir)
+}
+
+//BackupSnapshot returns the data now to save by the native backup of store later,
+//returns driver.ErrBackupSupport if the store not supports
+func (db *DB) BackupSnapshot() (driver.IBackupSnapshot, error) {
+	if b, ok := db.db.(driver.IBackuper); ok {
+		return b.BackupSnapshot()
+	}
+
+	return nil, driver.ErrBackupSupport
+}
+
+//Sync makes all committed data durable, does nothing if the store
+//always writes data to disk when commit
+func (db *DB) Sync() error {
+	if s, ok := db.db.(driver.ISyncer); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
+
+//CompactRange compacts keys in [min, max], nil means no limit,
+//returns driver.ErrCompactSupport if the store not supports
+func (db *DB) CompactRange(min []byte, max []byte) error {
+	if c, ok := db.db.(driver.ICompacter); ok {
+		return c.CompactRange(min, max)
+	}
+
+	return driver.ErrCompactSupport
+}
+
+//ApproximateSize returns the approximate size of keys in [min, max),
+//returns driver.ErrSizeSupport if the store not supports
+func (db *DB) ApproximateSize(min []byte, max []byte) (int64, error) {
+	if s, ok := db.db.(driver.ISizer); ok {
+		return s.ApproximateSize(min, max)
+	}
+
+	return 0, driver.ErrSizeSupport
+}
+
+func (db *DB) Capabilities() driver.Capabilities {
+	return db.db.Capabilities()
+}
+
+//Begin starts a real transaction of store,
+//returns driver.ErrTxSupport if the store not supports
+func (db *DB) Begin() (*Tx, error) {
 	tx, err := db.db.Begin()
 	if err != nil {
 		return nil, err
 	}
 
-	return tx, nil
+	return &Tx{tx}, nil
+}
+
+//BeginBuffer starts a transaction which buffers writes in memory and
+//commits them with a write batch, it works on all stores but does not
+//isolate from other writers, the caller must stop them until it ends
+func (db *DB) BeginBuffer() *Tx {
+	return &Tx{newBufferTx(db.db)}
 }
@@ -5,9 +5,32 @@ import (
 )
 
 var (
-	ErrTxSupport = errors.New("transaction is not supported")
+	ErrTxSupport     = errors.New("transaction is not supported")
+	ErrBackupSupport  = errors.New("native backup is not supported")
+	ErrCompactSupport = errors.New("compaction is not supported")
+	ErrSizeSupport    = errors.New("approximate size is not supported")
 )
 
+//Capabilities describes what a driver supports, so callers
+//can choose the best way to use it
+type Capabilities struct {
+	//Begin returns a real transaction, not ErrTxSupport
+	Tx bool
+	//iterator reads a consistent snapshot of data
+	Snapshot bool
+	//Prev is as cheap as Next, reverse iteration in lsm tree is slower
+	CheapReverseIteration bool
+
+	//implements ICompacter
+	Compaction bool
+	//implements ISizer
+	ApproximateSize bool
+	//implements IBackuper
+	NativeBackup bool
+	//implements ISyncer
+	Sync bool
+}
+
 type IDB interface {
 	Close() error
 
@@ -21,6 +44,43 @@ type IDB interface {
 	NewWriteBatch() IWriteBatch
 
 	Begin() (Tx, error)
+
+	Capabilities() Capabilities
+}
+
+//IBackuper is optional, implemented by the driver which can copy
+//its data to another dir natively
+type IBackuper interface {
+	//BackupSnapshot returns a consistent view of data now, which is
+	//saved later while others go on writing
+	BackupSnapshot() (IBackupSnapshot, error)
+}
+
+type IBackupSnapshot interface {
+	//Save copies the data of snapshot to dir
+	Save(dir string) error
+	Close()
+}
+
+//ISyncer is optional, implemented by the driver which may not
+//write data to disk when commit
+type ISyncer interface {
+	//Sync must make all committed data durable
+	Sync() error
+}
+
+//ICompacter is optional, implemented by the driver which can
+//compact a key range manually
+type ICompacter interface {
+	//CompactRange compacts keys in [min, max], nil means no limit
+	CompactRange(min []byte, max []byte) error
+}
+
+//ISizer is optional, implemented by the driver which can estimate
+//the disk size of a key range
+type ISizer interface {
+	//ApproximateSize returns the approximate size of keys in [min, max)
+	ApproximateSize(min []byte, max []byte) (int64, error)
 }
 
 type IIterator interface {
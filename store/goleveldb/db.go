@@ -5,14 +5,13 @@ import (
 	"github.com/siddontang/goleveldb/leveldb/cache"
 	"github.com/siddontang/goleveldb/leveldb/filter"
 	"github.com/siddontang/goleveldb/leveldb/opt"
+	"github.com/siddontang/goleveldb/leveldb/util"
 	"github.com/siddontang/ledisdb/config"
 	"github.com/siddontang/ledisdb/store/driver"
 
 	"os"
 )
 
-const defaultFilterBits int = 10
-
 type Store struct {
 }
 
@@ -31,6 +30,8 @@ type DB struct {
 
 	iteratorOpts *opt.ReadOptions
 
+	syncOpts *opt.WriteOptions
+
 	cache cache.Cache
 
 	filter filter.Filter
@@ -68,6 +69,9 @@ func (db *DB) open() error {
 	db.iteratorOpts = &opt.ReadOptions{}
 	db.iteratorOpts.DontFillCache = true
 
+	db.syncOpts = &opt.WriteOptions{}
+	db.syncOpts.Sync = true
+
 	var err error
 	db.db, err = leveldb.OpenFile(db.path, db.opts)
 
@@ -83,7 +87,7 @@ func newOptions(cfg *config.LevelDBConfig) *opt.Options {
 	opts.BlockCache = cache.NewLRUCache(cfg.CacheSize)
 
 	//we must use bloomfilter
-	opts.Filter = filter.NewBloomFilter(defaultFilterBits)
+	opts.Filter = filter.NewBloomFilter(cfg.BloomFilterBits)
 
 	if !cfg.Compression {
 		opts.Compression = opt.NoCompression
@@ -125,6 +129,45 @@ func (db *DB) NewWriteBatch() driver.IWriteBatch {
 	return wb
 }
 
+//Sync writes a deletion of the empty key with sync option, because goleveldb
+//ignores empty batch, ledis never uses the empty key
+func (db *DB) Sync() error {
+	wb := new(leveldb.Batch)
+	wb.Delete([]byte{})
+
+	return db.db.Write(wb, db.syncOpts)
+}
+
+func (db *DB) CompactRange(min []byte, max []byte) error {
+	return db.db.CompactRange(util.Range{Start: min, Limit: max})
+}
+
+func (db *DB) ApproximateSize(min []byte, max []byte) (int64, error) {
+	sizes, err := db.db.SizeOf([]util.Range{{Start: min, Limit: max}})
+	if err != nil {
+		return 0, err
+	}
+
+	var size int64
+	for _, s := range sizes {
+		size += int64(s)
+	}
+
+	return size, nil
+}
+
+func (db *DB) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Tx:                    false,
+		Snapshot:              true,
+		CheapReverseIteration: false,
+		Compaction:            true,
+		ApproximateSize:       true,
+		NativeBackup:          false,
+		Sync:                  true,
+	}
+}
+
 func (db *DB) NewIterator() driver.IIterator {
 	it := &Iterator{
 		db.db.NewIterator(nil, db.iteratorOpts),
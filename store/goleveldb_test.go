@@ -30,3 +30,11 @@ func TestGoLevelDB(t *testing.T) {
 
 	db.Close()
 }
+
+func TestGoLevelDBBufferTx(t *testing.T) {
+	db := newTestGoLevelDB()
+
+	testBufferTx(db, t)
+
+	db.Close()
+}
@@ -18,8 +18,6 @@ import (
 	"unsafe"
 )
 
-const defaultFilterBits int = 10
-
 type Store struct {
 }
 
@@ -81,6 +79,9 @@ type DB struct {
 	writeOpts    *WriteOptions
 	iteratorOpts *ReadOptions
 
+	//for Sync
+	syncOpts *WriteOptions
+
 	cache *Cache
 
 	filter *FilterPolicy
@@ -112,7 +113,7 @@ func (db *DB) initOptions(cfg *config.LevelDBConfig) {
 	opts.SetCache(db.cache)
 
 	//we must use bloomfilter
-	db.filter = NewBloomFilter(defaultFilterBits)
+	db.filter = NewBloomFilter(cfg.BloomFilterBits)
 	opts.SetFilterPolicy(db.filter)
 
 	if !cfg.Compression {
@@ -132,6 +133,9 @@ func (db *DB) initOptions(cfg *config.LevelDBConfig) {
 	db.readOpts = NewReadOptions()
 	db.writeOpts = NewWriteOptions()
 
+	db.syncOpts = NewWriteOptions()
+	db.syncOpts.SetSync(true)
+
 	db.iteratorOpts = NewReadOptions()
 	db.iteratorOpts.SetFillCache(false)
 }
@@ -154,6 +158,7 @@ func (db *DB) Close() error {
 
 	db.readOpts.Close()
 	db.writeOpts.Close()
+	db.syncOpts.Close()
 	db.iteratorOpts.Close()
 
 	return nil
@@ -183,6 +188,18 @@ func (db *DB) NewWriteBatch() driver.IWriteBatch {
 	return wb
 }
 
+//Sync writes an empty batch with sync option, so all data
+//written before in log is flushed to disk
+func (db *DB) Sync() error {
+	w := &WriteBatch{
+		db:     db,
+		wbatch: C.leveldb_writebatch_create(),
+	}
+	defer w.Close()
+
+	return w.commit(db.syncOpts)
+}
+
 func (db *DB) NewIterator() driver.IIterator {
 	it := new(Iterator)
 
@@ -254,6 +271,64 @@ func (db *DB) delete(wo *WriteOptions, key []byte) error {
 	return nil
 }
 
+//CompactRange compacts keys in [min, max], nil means no limit
+func (db *DB) CompactRange(min []byte, max []byte) error {
+	var start, limit *C.char
+	if len(min) != 0 {
+		start = (*C.char)(unsafe.Pointer(&min[0]))
+	}
+	if len(max) != 0 {
+		limit = (*C.char)(unsafe.Pointer(&max[0]))
+	}
+
+	C.leveldb_compact_range(
+		db.db, start, C.size_t(len(min)), limit, C.size_t(len(max)))
+	return nil
+}
+
+//ApproximateSize returns the approximate size of keys in [min, max),
+//the data in memtable is not counted
+func (db *DB) ApproximateSize(min []byte, max []byte) (int64, error) {
+	if max == nil {
+		//empty limit is the smallest key, use the key after the last one
+		it := db.NewIterator()
+		it.Last()
+		if it.Valid() {
+			max = append(append([]byte{}, it.Key()...), 0)
+		}
+		it.Close()
+
+		if max == nil {
+			return 0, nil
+		}
+	}
+
+	start := C.CString(string(min))
+	defer C.leveldb_free(unsafe.Pointer(start))
+	limit := C.CString(string(max))
+	defer C.leveldb_free(unsafe.Pointer(limit))
+
+	startLen := C.size_t(len(min))
+	limitLen := C.size_t(len(max))
+
+	var size C.uint64_t
+	C.leveldb_approximate_sizes(db.db, 1, &start, &startLen, &limit, &limitLen, &size)
+
+	return int64(size), nil
+}
+
+func (db *DB) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Tx:                    false,
+		Snapshot:              true,
+		CheapReverseIteration: false,
+		Compaction:            true,
+		ApproximateSize:       true,
+		NativeBackup:          false,
+		Sync:                  true,
+	}
+}
+
 func (db *DB) Begin() (driver.Tx, error) {
 	return nil, driver.ErrTxSupport
 }
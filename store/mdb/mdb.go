@@ -22,10 +22,9 @@ type MDB struct {
 }
 
 func (s Store) Open(path string, c *config.Config) (driver.IDB, error) {
+	c.LMDB.Adjust()
+
 	mapSize := c.LMDB.MapSize
-	if mapSize <= 0 {
-		mapSize = 500 * 1024 * 1024
-	}
 
 	env, err := mdb.NewEnv()
 	if err != nil {
@@ -257,14 +256,39 @@ func (db MDB) Close() error {
 	return nil
 }
 
+//Sync flushes data to disk, env is opened with NOSYNC
+func (db MDB) Sync() error {
+	return db.env.Sync(1)
+}
+
 func (db MDB) NewIterator() driver.IIterator {
 	return db.iterator(true)
 }
 
+func (db MDB) Backup(dir string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	return db.env.Copy(dir)
+}
+
 func (db MDB) NewWriteBatch() driver.IWriteBatch {
 	return driver.NewWriteBatch(db)
 }
 
+func (db MDB) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Tx:                    true,
+		Snapshot:              true,
+		CheapReverseIteration: true,
+		Compaction:            false,
+		ApproximateSize:       false,
+		NativeBackup:          true,
+		Sync:                  true,
+	}
+}
+
 func (db MDB) Begin() (driver.Tx, error) {
 	return newTx(db)
 }
@@ -0,0 +1,9 @@
+package store
+
+import (
+	"github.com/siddontang/ledisdb/store/memory"
+)
+
+func init() {
+	Register(memory.Store{})
+}
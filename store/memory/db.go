@@ -0,0 +1,158 @@
+package memory
+
+import (
+	"bytes"
+	"github.com/siddontang/ledisdb/config"
+	"github.com/siddontang/ledisdb/store/driver"
+	"sync"
+)
+
+type Store struct {
+}
+
+func (s Store) String() string {
+	return "memory"
+}
+
+//Open returns an empty in-memory db, path is not used,
+//all data is lost after Close
+func (s Store) Open(path string, cfg *config.Config) (driver.IDB, error) {
+	db := new(DB)
+	db.list = newSkiplist()
+
+	return db, nil
+}
+
+func (s Store) Repair(path string, cfg *config.Config) error {
+	return nil
+}
+
+type DB struct {
+	sync.RWMutex
+
+	list *skiplist
+
+	//only one write transaction at the same time
+	txLock sync.Mutex
+}
+
+func (db *DB) Close() error {
+	db.Lock()
+	db.list = newSkiplist()
+	db.Unlock()
+
+	return nil
+}
+
+func (db *DB) Get(key []byte) ([]byte, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	n, ok := db.list.get(key)
+	if !ok {
+		return nil, nil
+	}
+
+	//put replaces value of the node in place
+	return append([]byte{}, n.value...), nil
+}
+
+func (db *DB) Put(key []byte, value []byte) error {
+	key, value = copyKV(key, value)
+
+	db.Lock()
+	db.list.put(key, value)
+	db.Unlock()
+
+	return nil
+}
+
+func (db *DB) Delete(key []byte) error {
+	db.Lock()
+	db.list.delete(key)
+	db.Unlock()
+
+	return nil
+}
+
+func (db *DB) NewIterator() driver.IIterator {
+	return &Iterator{v: db}
+}
+
+func (db *DB) NewWriteBatch() driver.IWriteBatch {
+	return driver.NewWriteBatch(db)
+}
+
+//Capabilities of memory, iterator is not a snapshot, it sees the
+//writes committed while iterating
+func (db *DB) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Tx:                    true,
+		Snapshot:              false,
+		CheapReverseIteration: true,
+		Compaction:            false,
+		ApproximateSize:       true,
+		NativeBackup:          false,
+		Sync:                  false,
+	}
+}
+
+func (db *DB) Begin() (driver.Tx, error) {
+	db.txLock.Lock()
+
+	t := new(Tx)
+	t.db = db
+	t.list = newSkiplist()
+
+	return t, nil
+}
+
+func (db *DB) BatchPut(writes []driver.Write) error {
+	db.Lock()
+	defer db.Unlock()
+
+	for _, w := range writes {
+		if w.Value == nil {
+			db.list.delete(w.Key)
+		} else {
+			db.list.put(copyKV(w.Key, w.Value))
+		}
+	}
+
+	return nil
+}
+
+//ApproximateSize returns the memory size of keys and values in [min, max)
+func (db *DB) ApproximateSize(min []byte, max []byte) (int64, error) {
+	db.RLock()
+	defer db.RUnlock()
+
+	var size int64
+	for n := db.list.seek(min, true, true); n != nil; n = n.next[0] {
+		if max != nil && bytes.Compare(n.key, max) >= 0 {
+			break
+		}
+
+		size += int64(len(n.key) + len(n.value))
+	}
+
+	return size, nil
+}
+
+func (db *DB) seek(key []byte, forward bool, inclusive bool) ([]byte, []byte, bool) {
+	db.RLock()
+	defer db.RUnlock()
+
+	n := db.list.seek(key, forward, inclusive)
+	if n == nil {
+		return nil, nil, false
+	}
+
+	return append([]byte{}, n.key...), append([]byte{}, n.value...), true
+}
+
+//copyKV copies key and value, because caller may reuse them,
+//nil value is saved as empty
+func copyKV(key []byte, value []byte) ([]byte, []byte) {
+	return append([]byte{}, key...), append([]byte{}, value...)
+}
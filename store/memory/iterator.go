@@ -0,0 +1,59 @@
+package memory
+
+type view interface {
+	seek(key []byte, forward bool, inclusive bool) ([]byte, []byte, bool)
+}
+
+//Iterator holds no lock and finds the next key from the current one
+//at every step, so the db can be updated while iterating.
+type Iterator struct {
+	v     view
+	key   []byte
+	value []byte
+	valid bool
+}
+
+func (it *Iterator) set(key []byte, value []byte, valid bool) {
+	it.key, it.value, it.valid = key, value, valid
+}
+
+func (it *Iterator) Close() error {
+	it.set(nil, nil, false)
+	return nil
+}
+
+func (it *Iterator) First() {
+	it.set(it.v.seek(nil, true, true))
+}
+
+func (it *Iterator) Last() {
+	it.set(it.v.seek(nil, false, true))
+}
+
+func (it *Iterator) Seek(key []byte) {
+	it.set(it.v.seek(key, true, true))
+}
+
+func (it *Iterator) Next() {
+	if it.valid {
+		it.set(it.v.seek(it.key, true, false))
+	}
+}
+
+func (it *Iterator) Prev() {
+	if it.valid {
+		it.set(it.v.seek(it.key, false, false))
+	}
+}
+
+func (it *Iterator) Valid() bool {
+	return it.valid
+}
+
+func (it *Iterator) Key() []byte {
+	return it.key
+}
+
+func (it *Iterator) Value() []byte {
+	return it.value
+}
@@ -0,0 +1,185 @@
+package memory
+
+import (
+	"bytes"
+	"math/rand"
+)
+
+const (
+	maxHeight = 12
+	branching = 4
+)
+
+type node struct {
+	key   []byte
+	value []byte
+	next  []*node
+}
+
+//skiplist is an ordered map of key value, not thread safe
+type skiplist struct {
+	head   *node
+	height int
+	rnd    *rand.Rand
+	n      int
+}
+
+func newSkiplist() *skiplist {
+	s := new(skiplist)
+	s.head = &node{next: make([]*node, maxHeight)}
+	s.height = 1
+	s.rnd = rand.New(rand.NewSource(0xdeadbeef))
+	return s
+}
+
+func (s *skiplist) randomHeight() int {
+	h := 1
+	for h < maxHeight && s.rnd.Intn(branching) == 0 {
+		h++
+	}
+	return h
+}
+
+//findGE returns the first node whose key >= key, or nil,
+//if prev is not nil, fills it with the previous node at every level
+func (s *skiplist) findGE(key []byte, prev []*node) *node {
+	x := s.head
+	level := s.height - 1
+	for {
+		next := x.next[level]
+		if next != nil && bytes.Compare(next.key, key) < 0 {
+			x = next
+			continue
+		}
+
+		if prev != nil {
+			prev[level] = x
+		}
+
+		if level == 0 {
+			return next
+		}
+		level--
+	}
+}
+
+//findLT returns the last node whose key < key, or nil
+func (s *skiplist) findLT(key []byte) *node {
+	x := s.head
+	level := s.height - 1
+	for {
+		next := x.next[level]
+		if next != nil && bytes.Compare(next.key, key) < 0 {
+			x = next
+			continue
+		}
+
+		if level == 0 {
+			break
+		}
+		level--
+	}
+
+	if x == s.head {
+		return nil
+	}
+	return x
+}
+
+//findLast returns the last node, or nil if empty
+func (s *skiplist) findLast() *node {
+	x := s.head
+	level := s.height - 1
+	for {
+		next := x.next[level]
+		if next != nil {
+			x = next
+			continue
+		}
+
+		if level == 0 {
+			break
+		}
+		level--
+	}
+
+	if x == s.head {
+		return nil
+	}
+	return x
+}
+
+func (s *skiplist) get(key []byte) (*node, bool) {
+	x := s.findGE(key, nil)
+	if x != nil && bytes.Equal(x.key, key) {
+		return x, true
+	}
+	return nil, false
+}
+
+//put saves key and value in list, caller must not modify them later
+func (s *skiplist) put(key []byte, value []byte) {
+	var prev [maxHeight]*node
+	x := s.findGE(key, prev[:])
+	if x != nil && bytes.Equal(x.key, key) {
+		x.value = value
+		return
+	}
+
+	h := s.randomHeight()
+	if h > s.height {
+		for i := s.height; i < h; i++ {
+			prev[i] = s.head
+		}
+		s.height = h
+	}
+
+	x = &node{key: key, value: value, next: make([]*node, h)}
+	for i := 0; i < h; i++ {
+		x.next[i] = prev[i].next[i]
+		prev[i].next[i] = x
+	}
+
+	s.n++
+}
+
+func (s *skiplist) delete(key []byte) {
+	var prev [maxHeight]*node
+	x := s.findGE(key, prev[:])
+	if x == nil || !bytes.Equal(x.key, key) {
+		return
+	}
+
+	for i := 0; i < len(x.next); i++ {
+		prev[i].next[i] = x.next[i]
+	}
+
+	for s.height > 1 && s.head.next[s.height-1] == nil {
+		s.height--
+	}
+
+	s.n--
+}
+
+//seek returns the nearest node from key, in the direction of forward,
+//key is included only if inclusive
+func (s *skiplist) seek(key []byte, forward bool, inclusive bool) *node {
+	if forward {
+		x := s.findGE(key, nil)
+		if x != nil && !inclusive && bytes.Equal(x.key, key) {
+			x = x.next[0]
+		}
+		return x
+	}
+
+	if key == nil {
+		return s.findLast()
+	}
+
+	if inclusive {
+		if x, ok := s.get(key); ok {
+			return x
+		}
+	}
+	return s.findLT(key)
+}
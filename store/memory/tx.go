@@ -0,0 +1,117 @@
+package memory
+
+import (
+	"bytes"
+	"errors"
+	"github.com/siddontang/ledisdb/store/driver"
+)
+
+var errTxClosed = errors.New("tx is closed")
+
+//Tx saves its writes in its own list, nil value means deleted,
+//and applies them to db when commit.
+type Tx struct {
+	db   *DB
+	list *skiplist
+}
+
+func (t *Tx) Get(key []byte) ([]byte, error) {
+	if n, ok := t.list.get(key); ok {
+		if n.value == nil {
+			return nil, nil
+		}
+		return append([]byte{}, n.value...), nil
+	}
+
+	return t.db.Get(key)
+}
+
+func (t *Tx) Put(key []byte, value []byte) error {
+	t.list.put(copyKV(key, value))
+	return nil
+}
+
+func (t *Tx) Delete(key []byte) error {
+	t.list.put(append([]byte{}, key...), nil)
+	return nil
+}
+
+func (t *Tx) NewIterator() driver.IIterator {
+	return &Iterator{v: t}
+}
+
+func (t *Tx) NewWriteBatch() driver.IWriteBatch {
+	return driver.NewWriteBatch(t)
+}
+
+func (t *Tx) BatchPut(writes []driver.Write) error {
+	for _, w := range writes {
+		if w.Value == nil {
+			t.Delete(w.Key)
+		} else {
+			t.Put(w.Key, w.Value)
+		}
+	}
+	return nil
+}
+
+func (t *Tx) Rollback() error {
+	if t.db == nil {
+		return errTxClosed
+	}
+
+	t.close()
+	return nil
+}
+
+func (t *Tx) Commit() error {
+	if t.db == nil {
+		return errTxClosed
+	}
+
+	t.db.Lock()
+	for n := t.list.head.next[0]; n != nil; n = n.next[0] {
+		if n.value == nil {
+			t.db.list.delete(n.key)
+		} else {
+			t.db.list.put(n.key, n.value)
+		}
+	}
+	t.db.Unlock()
+
+	t.close()
+	return nil
+}
+
+func (t *Tx) close() {
+	t.db.txLock.Unlock()
+	t.db = nil
+	t.list = nil
+}
+
+//seek merges the writes of tx with db, skipping deleted keys
+func (t *Tx) seek(key []byte, forward bool, inclusive bool) ([]byte, []byte, bool) {
+	for {
+		k, v, ok := t.db.seek(key, forward, inclusive)
+		n := t.list.seek(key, forward, inclusive)
+
+		if n == nil {
+			return k, v, ok
+		}
+
+		if ok {
+			c := bytes.Compare(n.key, k)
+			if (forward && c > 0) || (!forward && c < 0) {
+				return k, v, ok
+			}
+		}
+
+		if n.value != nil {
+			return n.key, n.value, true
+		}
+
+		//deleted in tx, go on from it
+		key = n.key
+		inclusive = false
+	}
+}
@@ -0,0 +1,44 @@
+package store
+
+import (
+	"github.com/siddontang/ledisdb/config"
+	"testing"
+)
+
+func newTestMemory() *DB {
+	cfg := new(config.Config)
+	cfg.DBName = "memory"
+	cfg.DataDir = "/tmp/testdb"
+
+	db, err := Open(cfg)
+	if err != nil {
+		println(err.Error())
+		panic(err)
+	}
+
+	return db
+}
+
+func TestMemory(t *testing.T) {
+	db := newTestMemory()
+
+	testStore(db, t)
+
+	db.Close()
+}
+
+func TestMemoryTx(t *testing.T) {
+	db := newTestMemory()
+
+	testTx(db, t)
+
+	db.Close()
+}
+
+func TestMemoryBufferTx(t *testing.T) {
+	db := newTestMemory()
+
+	testBufferTx(db, t)
+
+	db.Close()
+}
@@ -19,8 +19,6 @@ import (
 	"unsafe"
 )
 
-const defaultFilterBits int = 10
-
 type Store struct {
 }
 
@@ -29,13 +27,17 @@ func (s Store) String() string {
 }
 
 func (s Store) Open(path string, cfg *config.Config) (driver.IDB, error) {
+	if err := checkConfig(&cfg.RocksDB); err != nil {
+		return nil, err
+	}
+
 	if err := os.MkdirAll(path, os.ModePerm); err != nil {
 		return nil, err
 	}
 
 	db := new(DB)
 	db.path = path
-	db.cfg = &cfg.LevelDB
+	db.cfg = &cfg.RocksDB
 
 	if err := db.open(); err != nil {
 		return nil, err
@@ -45,9 +47,13 @@ func (s Store) Open(path string, cfg *config.Config) (driver.IDB, error) {
 }
 
 func (s Store) Repair(path string, cfg *config.Config) error {
+	if err := checkConfig(&cfg.RocksDB); err != nil {
+		return err
+	}
+
 	db := new(DB)
 	db.path = path
-	db.cfg = &cfg.LevelDB
+	db.cfg = &cfg.RocksDB
 
 	err := db.open()
 	defer db.Close()
@@ -68,10 +74,16 @@ func (s Store) Repair(path string, cfg *config.Config) error {
 	return nil
 }
 
+func checkConfig(cfg *config.RocksDBConfig) error {
+	cfg.Adjust()
+
+	return cfg.Validate()
+}
+
 type DB struct {
 	path string
 
-	cfg *config.LevelDBConfig
+	cfg *config.RocksDBConfig
 
 	db *C.rocksdb_t
 
@@ -84,6 +96,9 @@ type DB struct {
 	writeOpts    *WriteOptions
 	iteratorOpts *ReadOptions
 
+	//for Sync
+	syncOpts *WriteOptions
+
 	cache *Cache
 
 	filter *FilterPolicy
@@ -104,7 +119,7 @@ func (db *DB) open() error {
 	return nil
 }
 
-func (db *DB) initOptions(cfg *config.LevelDBConfig) {
+func (db *DB) initOptions(cfg *config.RocksDBConfig) {
 	opts := NewOptions()
 
 	opts.SetCreateIfMissing(true)
@@ -112,15 +127,15 @@ func (db *DB) initOptions(cfg *config.LevelDBConfig) {
 	cfg.Adjust()
 
 	db.env = NewDefaultEnv()
-	db.env.SetBackgroundThreads(runtime.NumCPU() * 2)
-	db.env.SetHighPriorityBackgroundThreads(1)
+	db.env.SetBackgroundThreads(cfg.BackgroundThreads)
+	db.env.SetHighPriorityBackgroundThreads(cfg.HighPriorityBackgroundThreads)
 	opts.SetEnv(db.env)
 
 	db.cache = NewLRUCache(cfg.CacheSize)
 	opts.SetCache(db.cache)
 
 	//we must use bloomfilter
-	db.filter = NewBloomFilter(defaultFilterBits)
+	db.filter = NewBloomFilter(cfg.BloomFilterBits)
 	opts.SetFilterPolicy(db.filter)
 
 	if !cfg.Compression {
@@ -132,20 +147,35 @@ func (db *DB) initOptions(cfg *config.LevelDBConfig) {
 	opts.SetBlockSize(cfg.BlockSize)
 
 	opts.SetWriteBufferSize(cfg.WriteBufferSize)
+	opts.SetMaxWriteBufferNumber(cfg.MaxWriteBufferNum)
 
 	opts.SetMaxOpenFiles(cfg.MaxOpenFiles)
 
-	opts.SetMaxBackgroundCompactions(runtime.NumCPU()*2 - 1)
-	opts.SetMaxBackgroundFlushes(1)
+	opts.SetCompactionStyle(compactionStyles[cfg.CompactionStyle])
 
-	opts.SetLevel0SlowdownWritesTrigger(16)
-	opts.SetLevel0StopWritesTrigger(64)
-	opts.SetTargetFileSizeBase(32 * 1024 * 1024)
+	opts.SetMaxBackgroundCompactions(cfg.MaxBackgroundCompactions)
+	opts.SetMaxBackgroundFlushes(cfg.MaxBackgroundFlushes)
+
+	opts.SetLevel0SlowdownWritesTrigger(cfg.Level0SlowdownWritesTrigger)
+	opts.SetLevel0StopWritesTrigger(cfg.Level0StopWritesTrigger)
+	opts.SetTargetFileSizeBase(cfg.TargetFileSizeBase)
+
+	if len(cfg.WALDir) > 0 {
+		opts.SetWALDir(cfg.WALDir)
+	}
+
+	if cfg.WALTTLSeconds > 0 {
+		opts.SetWALTTLSeconds(cfg.WALTTLSeconds)
+	}
 
 	db.opts = opts
 
 	db.readOpts = NewReadOptions()
 	db.writeOpts = NewWriteOptions()
+	db.writeOpts.DisableWAL(cfg.DisableWAL)
+
+	db.syncOpts = NewWriteOptions()
+	db.syncOpts.SetSync(true)
 
 	db.iteratorOpts = NewReadOptions()
 	db.iteratorOpts.SetFillCache(false)
@@ -173,6 +203,7 @@ func (db *DB) Close() error {
 
 	db.readOpts.Close()
 	db.writeOpts.Close()
+	db.syncOpts.Close()
 	db.iteratorOpts.Close()
 
 	return nil
@@ -203,6 +234,23 @@ func (db *DB) NewWriteBatch() driver.IWriteBatch {
 	return wb
 }
 
+//Sync writes an empty batch with sync option, so all data
+//written before in log is flushed to disk
+func (db *DB) Sync() error {
+	if db.cfg.DisableWAL {
+		//no wal to sync, data is saved only after memtable flushed
+		return nil
+	}
+
+	w := &WriteBatch{
+		db:     db,
+		wbatch: C.rocksdb_writebatch_create(),
+	}
+	defer w.Close()
+
+	return w.commit(db.syncOpts)
+}
+
 func (db *DB) NewIterator() driver.IIterator {
 	it := new(Iterator)
 
@@ -271,6 +319,64 @@ func (db *DB) delete(wo *WriteOptions, key []byte) error {
 	return nil
 }
 
+//CompactRange compacts keys in [min, max], nil means no limit
+func (db *DB) CompactRange(min []byte, max []byte) error {
+	var start, limit *C.char
+	if len(min) != 0 {
+		start = (*C.char)(unsafe.Pointer(&min[0]))
+	}
+	if len(max) != 0 {
+		limit = (*C.char)(unsafe.Pointer(&max[0]))
+	}
+
+	C.rocksdb_compact_range(
+		db.db, start, C.size_t(len(min)), limit, C.size_t(len(max)))
+	return nil
+}
+
+//ApproximateSize returns the approximate size of keys in [min, max),
+//the data in memtable is not counted
+func (db *DB) ApproximateSize(min []byte, max []byte) (int64, error) {
+	if max == nil {
+		//empty limit is the smallest key, use the key after the last one
+		it := db.NewIterator()
+		it.Last()
+		if it.Valid() {
+			max = append(append([]byte{}, it.Key()...), 0)
+		}
+		it.Close()
+
+		if max == nil {
+			return 0, nil
+		}
+	}
+
+	start := C.CString(string(min))
+	defer C.free(unsafe.Pointer(start))
+	limit := C.CString(string(max))
+	defer C.free(unsafe.Pointer(limit))
+
+	startLen := C.size_t(len(min))
+	limitLen := C.size_t(len(max))
+
+	var size C.uint64_t
+	C.rocksdb_approximate_sizes(db.db, 1, &start, &startLen, &limit, &limitLen, &size)
+
+	return int64(size), nil
+}
+
+func (db *DB) Capabilities() driver.Capabilities {
+	return driver.Capabilities{
+		Tx:                    false,
+		Snapshot:              true,
+		CheapReverseIteration: false,
+		Compaction:            true,
+		ApproximateSize:       true,
+		NativeBackup:          false,
+		Sync:                  true,
+	}
+}
+
 func (db *DB) Begin() (driver.Tx, error) {
 	return nil, driver.ErrTxSupport
 }
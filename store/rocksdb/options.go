@@ -4,8 +4,13 @@ package rocksdb
 
 // #cgo LDFLAGS: -lrocksdb
 // #include "rocksdb/c.h"
+// #include <stdlib.h>
 import "C"
 
+import (
+	"unsafe"
+)
+
 type CompressionOpt int
 
 const (
@@ -13,6 +18,20 @@ const (
 	SnappyCompression = CompressionOpt(1)
 )
 
+type CompactionStyle int
+
+const (
+	LevelCompactionStyle     = CompactionStyle(0)
+	UniversalCompactionStyle = CompactionStyle(1)
+	FIFOCompactionStyle      = CompactionStyle(2)
+)
+
+var compactionStyles = map[string]CompactionStyle{
+	"level":     LevelCompactionStyle,
+	"universal": UniversalCompactionStyle,
+	"fifo":      FIFOCompactionStyle,
+}
+
 type Options struct {
 	Opt *C.rocksdb_options_t
 }
@@ -141,6 +160,21 @@ func (o *Options) SetMaxBytesForLevelMultiplier(n int) {
 	C.rocksdb_options_set_max_bytes_for_level_multiplier(o.Opt, C.int(n))
 }
 
+func (o *Options) SetCompactionStyle(style CompactionStyle) {
+	C.rocksdb_options_set_compaction_style(o.Opt, C.int(style))
+}
+
+func (o *Options) SetWALDir(dir string) {
+	cdir := C.CString(dir)
+	defer C.free(unsafe.Pointer(cdir))
+
+	C.rocksdb_options_set_wal_dir(o.Opt, cdir)
+}
+
+func (o *Options) SetWALTTLSeconds(n int) {
+	C.rocksdb_options_set_WAL_ttl_seconds(o.Opt, C.uint64_t(n))
+}
+
 func (ro *ReadOptions) Close() {
 	C.rocksdb_readoptions_destroy(ro.Opt)
 }
@@ -160,3 +194,7 @@ func (wo *WriteOptions) Close() {
 func (wo *WriteOptions) SetSync(b bool) {
 	C.rocksdb_writeoptions_set_sync(wo.Opt, boolToUchar(b))
 }
+
+func (wo *WriteOptions) DisableWAL(b bool) {
+	C.rocksdb_writeoptions_disable_WAL(wo.Opt, boolToInt(b))
+}
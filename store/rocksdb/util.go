@@ -20,6 +20,13 @@ func boolToUchar(b bool) C.uchar {
 	return uc
 }
 
+func boolToInt(b bool) C.int {
+	if b {
+		return C.int(1)
+	}
+	return C.int(0)
+}
+
 func ucharToBool(uc C.uchar) bool {
 	if uc == C.uchar(0) {
 		return false
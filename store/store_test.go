@@ -3,6 +3,7 @@ package store
 import (
 	"bytes"
 	"fmt"
+	"github.com/siddontang/ledisdb/store/driver"
 	"testing"
 )
 
@@ -14,6 +15,45 @@ func testStore(db *DB, t *testing.T) {
 	testSimple(db, t)
 	testBatch(db, t)
 	testIterator(db, t)
+	testCapabilities(db, t)
+}
+
+func testCapabilities(db *DB, t *testing.T) {
+	caps := db.Capabilities()
+
+	_, ok := db.db.(driver.ICompacter)
+	if ok != caps.Compaction {
+		t.Fatal("compaction capability mismatch")
+	}
+
+	_, ok = db.db.(driver.ISizer)
+	if ok != caps.ApproximateSize {
+		t.Fatal("approximate size capability mismatch")
+	}
+
+	_, ok = db.db.(driver.IBackuper)
+	if ok != caps.NativeBackup {
+		t.Fatal("native backup capability mismatch")
+	}
+
+	_, ok = db.db.(driver.ISyncer)
+	if ok != caps.Sync {
+		t.Fatal("sync capability mismatch")
+	}
+
+	tx, err := db.Begin()
+	if err == driver.ErrTxSupport {
+		if caps.Tx {
+			t.Fatal("tx capability mismatch")
+		}
+	} else if err != nil {
+		t.Fatal(err)
+	} else {
+		if !caps.Tx {
+			t.Fatal("tx capability mismatch")
+		}
+		tx.Rollback()
+	}
 }
 
 func testSimple(db *DB, t *testing.T) {
@@ -4,6 +4,60 @@ import (
 	"github.com/siddontang/ledisdb/store/driver"
 )
 
-type Tx interface {
-	driver.Tx
+//Tx has the same api of DB for reading, reads in it see its own writes
+type Tx struct {
+	tx driver.Tx
+}
+
+func (tx *Tx) Get(key []byte) ([]byte, error) {
+	return tx.tx.Get(key)
+}
+
+func (tx *Tx) Put(key []byte, value []byte) error {
+	return tx.tx.Put(key, value)
+}
+
+func (tx *Tx) Delete(key []byte) error {
+	return tx.tx.Delete(key)
+}
+
+func (tx *Tx) NewIterator() *Iterator {
+	it := new(Iterator)
+	it.it = tx.tx.NewIterator()
+
+	return it
+}
+
+func (tx *Tx) NewWriteBatch() WriteBatch {
+	return tx.tx.NewWriteBatch()
+}
+
+func (tx *Tx) RangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRangeLimitIterator(tx.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+func (tx *Tx) RevRangeIterator(min []byte, max []byte, rangeType uint8) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(tx.NewIterator(), &Range{min, max, rangeType}, &Limit{0, -1})
+}
+
+//count < 0, unlimit.
+//
+//offset must >= 0, if < 0, will get nothing.
+func (tx *Tx) RangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRangeLimitIterator(tx.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+//count < 0, unlimit.
+//
+//offset must >= 0, if < 0, will get nothing.
+func (tx *Tx) RevRangeLimitIterator(min []byte, max []byte, rangeType uint8, offset int, count int) *RangeLimitIterator {
+	return NewRevRangeLimitIterator(tx.NewIterator(), &Range{min, max, rangeType}, &Limit{offset, count})
+}
+
+func (tx *Tx) Commit() error {
+	return tx.tx.Commit()
+}
+
+func (tx *Tx) Rollback() error {
+	return tx.tx.Rollback()
 }
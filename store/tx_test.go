@@ -1,6 +1,7 @@
 package store
 
 import (
+	"fmt"
 	"testing"
 )
 
@@ -9,6 +10,55 @@ func TestTx(t *testing.T) {
 }
 
 func testTx(db *DB, t *testing.T) {
+	testTxWith(db, db.Begin, t)
+}
+
+func testBufferTx(db *DB, t *testing.T) {
+	testTxWith(db, func() (*Tx, error) { return db.BeginBuffer(), nil }, t)
+
+	//deleted keys are skipped in both directions
+	tx := db.BeginBuffer()
+	tx.Put([]byte("0"), []byte("0"))
+	tx.Put([]byte("3"), []byte("3"))
+	tx.Delete([]byte("2"))
+	tx.Delete([]byte("4"))
+
+	var keys []string
+	it := tx.NewIterator()
+	for it.SeekToFirst(); it.Valid(); it.Next() {
+		keys = append(keys, string(it.Key()))
+	}
+	for it.SeekToLast(); it.Valid(); it.Prev() {
+		keys = append(keys, string(it.Key()))
+	}
+	it.Close()
+
+	if s := fmt.Sprint(keys); s != "[0 1 3 3 1 0]" {
+		t.Fatal(s)
+	}
+
+	if v, err := tx.Get([]byte("2")); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal("must nil")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	if v, err := db.Get([]byte("4")); err != nil {
+		t.Fatal(err)
+	} else if v != nil {
+		t.Fatal("must nil")
+	}
+
+	if err := tx.Commit(); err == nil {
+		t.Fatal("must error")
+	}
+}
+
+func testTxWith(db *DB, begin func() (*Tx, error), t *testing.T) {
 	key1 := []byte("1")
 	key2 := []byte("2")
 	key3 := []byte("3")
@@ -17,7 +67,7 @@ func testTx(db *DB, t *testing.T) {
 	db.Put(key1, []byte("1"))
 	db.Put(key2, []byte("2"))
 
-	tx, err := db.Begin()
+	tx, err := begin()
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -48,7 +98,7 @@ func testTx(db *DB, t *testing.T) {
 		t.Fatal(string(it.Value()))
 	}
 
-	it.First()
+	it.SeekToFirst()
 
 	if !it.Valid() {
 		t.Fatal("must valid")
@@ -72,7 +122,7 @@ func testTx(db *DB, t *testing.T) {
 		t.Fatal(string(it.Value()))
 	}
 
-	it.Last()
+	it.SeekToLast()
 
 	if !it.Valid() {
 		t.Fatal("must valid")
@@ -90,7 +140,7 @@ func testTx(db *DB, t *testing.T) {
 		t.Fatal(string(v))
 	}
 
-	tx, err = db.Begin()
+	tx, err = begin()
 	if err != nil {
 		t.Fatal(err)
 	}